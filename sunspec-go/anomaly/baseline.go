@@ -0,0 +1,254 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// Baseline learns the normal behavior of points and scores the deviation of their values from it.
+//
+// For every point the mean and variance of its value and of its rate of change are learned.
+// Given a period, e.g. a day, the values are additionally learned per phase of the period,
+// so values are scored against those usually observed at the same time of the period.
+// Deviations are scored in standard deviations, which are never considered below the resolution of the point.
+// A baseline is serialized as json, so it is learned once and reused by later runs.
+type Baseline struct {
+	// Paths reference the learned points, e.g. "803.string[*].StrModTmpAvg".
+	Paths []string `json:"paths"`
+	// Threshold is the score in standard deviations above which deviations are reported.
+	Threshold float64 `json:"threshold"`
+	// Period is the length in seconds of the periodic pattern of the values, zero if they are not periodic.
+	Period float64 `json:"period"`
+	// Phases is the number of phases the period is divided into.
+	Phases int `json:"phases"`
+	// Points are the learned profiles by the paths of their points.
+	Points map[string]*Profile `json:"points"`
+
+	mu   sync.Mutex
+	last map[string]sample
+}
+
+// Profile is the learned behavior of a point.
+type Profile struct {
+	// Value are the statistics of the scaled value.
+	Value Stats `json:"value"`
+	// Rate are the statistics of the rate of change per second.
+	Rate Stats `json:"rate"`
+	// Phase are the statistics of the scaled value by phase of the period, if any.
+	Phase []Stats `json:"phase,omitempty"`
+	// Resolution is the smallest change of the scaled value.
+	Resolution float64 `json:"resolution"`
+}
+
+// Stats are the running statistics of a series.
+type Stats struct {
+	// N is the number of samples.
+	N float64 `json:"n"`
+	// Mean is the mean of the samples.
+	Mean float64 `json:"mean"`
+	// M2 is the sum of the squared differences of the samples from their mean.
+	M2 float64 `json:"m2"`
+}
+
+// add adds the sample to the statistics.
+func (s *Stats) add(v float64) {
+	s.N++
+	d := v - s.Mean
+	s.Mean += d / s.N
+	s.M2 += d * (v - s.Mean)
+}
+
+// Deviation returns the standard deviation of the samples.
+func (s Stats) Deviation() float64 {
+	if s.N < 2 {
+		return 0
+	}
+	return math.Sqrt(s.M2 / (s.N - 1))
+}
+
+// score returns the deviation of v from the mean in standard deviations, which are at least min.
+// Statistics of less than two samples score zero.
+func (s Stats) score(v, min float64) float64 {
+	if s.N < 2 {
+		return 0
+	}
+	return math.Abs(v-s.Mean) / math.Max(s.Deviation(), min)
+}
+
+// NewBaseline returns an empty baseline of the points referenced by the paths,
+// reporting deviations above 4 standard deviations.
+func NewBaseline(paths ...string) *Baseline {
+	return &Baseline{Paths: paths, Threshold: 4, Points: make(map[string]*Profile)}
+}
+
+// LoadBaseline reads a baseline from a json file.
+func LoadBaseline(name string) (*Baseline, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var bl Baseline
+	if err := json.Unmarshal(b, &bl); err != nil {
+		return nil, fmt.Errorf("anomaly: invalid baseline file %q: %w", name, err)
+	}
+	if bl.Points == nil {
+		bl.Points = make(map[string]*Profile)
+	}
+	return &bl, nil
+}
+
+// Save writes the baseline to a json file.
+func (bl *Baseline) Save(name string) error {
+	bl.mu.Lock()
+	b, err := json.MarshalIndent(bl, "", "\t")
+	bl.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, b, 0666)
+}
+
+// Learn adds the current values of the points, observed at the time t, to the baseline.
+// The values must not be modified concurrently, see sunspec.Client.View.
+func (bl *Baseline) Learn(d sunspec.Device, t time.Time) error {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	return bl.observe(d, t, func(path string, p sunspec.Point, v float64, rate float64, ok bool) {
+		pr := bl.Points[path]
+		if pr == nil {
+			pr = &Profile{Resolution: 1}
+			if s, ok := p.(sunspec.Scalable); ok {
+				pr.Resolution = math.Pow10(int(s.Factor()))
+			}
+			if bl.Period > 0 && bl.Phases > 0 {
+				pr.Phase = make([]Stats, bl.Phases)
+			}
+			bl.Points[path] = pr
+		}
+		pr.Value.add(v)
+		if ok {
+			pr.Rate.add(rate)
+		}
+		if i := bl.phase(t); i < len(pr.Phase) {
+			pr.Phase[i].add(v)
+		}
+	})
+}
+
+// Score scores the current values of the points, observed at the time t, against the baseline.
+// An alert is returned for every value and rate of change scoring above the threshold.
+// Values are scored against their phase of the period, if learned, and otherwise against all learned values.
+// The values must not be modified concurrently, see sunspec.Client.View.
+func (bl *Baseline) Score(d sunspec.Device, t time.Time) (alerts []Alert, err error) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	err = bl.observe(d, t, func(path string, p sunspec.Point, v float64, rate float64, ok bool) {
+		pr := bl.Points[path]
+		if pr == nil {
+			return
+		}
+		raise := func(invariant string, score float64, format string, args ...interface{}) {
+			alerts = append(alerts, Alert{
+				Time:      t,
+				Invariant: invariant,
+				Paths:     []string{path},
+				Values:    []float64{v},
+				Score:     score,
+				Message:   fmt.Sprintf(format, args...),
+			})
+		}
+		s := pr.Value
+		if i := bl.phase(t); i < len(pr.Phase) && pr.Phase[i].N >= 2 {
+			s = pr.Phase[i]
+		}
+		if score := s.score(v, pr.Resolution); score > bl.Threshold {
+			raise(fmt.Sprintf("|%v - %.6g| <= %v σ", path, s.Mean, bl.Threshold), score,
+				"value %v deviates by %.3g σ from the mean %.6g ± %.3g", v, score, s.Mean, s.Deviation())
+		}
+		if !ok {
+			return
+		}
+		if score := pr.Rate.score(rate, pr.Resolution); score > bl.Threshold {
+			raise(fmt.Sprintf("|d%v/dt - %.6g| <= %v σ", path, pr.Rate.Mean, bl.Threshold), score,
+				"rate of change %.6g per second deviates by %.3g σ from the mean %.6g ± %.3g", rate, score, pr.Rate.Mean, pr.Rate.Deviation())
+		}
+	})
+	return alerts, err
+}
+
+// Monitor returns a report function for the scheduler of the client, learning the baseline from the polls
+// within the training window after the first poll and scoring all later polls.
+// A baseline loaded from a previous run is scored immediately given a zero window.
+// Alerts are reported by calling alert.
+func (bl *Baseline) Monitor(c *sunspec.Client, window time.Duration, alert func(Alert)) func(p sunspec.Poll) {
+	var start time.Time
+	return func(p sunspec.Poll) {
+		if p.Err != nil && len(p.Points) == 0 {
+			return
+		}
+		if start.IsZero() {
+			start = p.Started
+		}
+		t := p.Started.Add(p.Latency)
+		var alerts []Alert
+		c.View(func(d sunspec.Device) (err error) {
+			if p.Started.Before(start.Add(window)) {
+				return bl.Learn(d, t)
+			}
+			alerts, err = bl.Score(d, t)
+			return err
+		})
+		for _, a := range alerts {
+			alert(a)
+		}
+	}
+}
+
+// phase returns the phase of the period at the time t, or the number of phases if the values are not periodic.
+func (bl *Baseline) phase(t time.Time) int {
+	if bl.Period <= 0 || bl.Phases <= 0 {
+		return bl.Phases
+	}
+	period := int64(bl.Period * float64(time.Second))
+	return int(int64(bl.Phases) * ((t.UnixNano()%period + period) % period) / period)
+}
+
+// observe calls fn for every implemented and received point of the paths, with its scaled value and its rate of change
+// since the previous observation. If the rate is unknown, e.g. for the first observation, ok is false.
+// Points not updated since the previous observation are skipped.
+func (bl *Baseline) observe(d sunspec.Device, t time.Time, fn func(path string, p sunspec.Point, v float64, rate float64, ok bool)) error {
+	if bl.last == nil {
+		bl.last = make(map[string]sample)
+	}
+	for _, path := range bl.Paths {
+		pts, err := d.Query(path)
+		if err != nil {
+			return err
+		}
+		for _, p := range pts {
+			if !p.Valid() || p.Err() != nil {
+				continue
+			}
+			v, err := sunspec.Scaled(p)
+			if err != nil {
+				continue
+			}
+			path := sunspec.Path(p)
+			prev, seen := bl.last[path]
+			now := sample{time: t, updated: p.Updated(), value: v}
+			if !now.updated.IsZero() && now.updated.Equal(prev.updated) {
+				continue
+			}
+			bl.last[path] = now
+			dt := t.Sub(prev.time).Seconds()
+			fn(path, p, v, (v-prev.value)/dt, seen && dt > 0)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package anomaly_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TRICERA-energy/sunspec/anomaly"
+)
+
+func TestBaseline(t *testing.T) {
+	d := device(t)
+	t0 := time.Unix(1600000000, 0)
+
+	// the temperature alternates around 30 °C, rising to 40 °C in the second half of every 100 seconds
+	temperature := func(i int) float64 {
+		v := 29 + float64(i%3)
+		if i%100 >= 50 {
+			v += 10
+		}
+		return v
+	}
+	bl := anomaly.NewBaseline("803.ModTmpAvg", "802.SoC")
+	bl.Period, bl.Phases = 100, 2
+	for i := 0; i < 1000; i++ {
+		set(t, d, "803.ModTmpAvg", temperature(i))
+		if err := bl.Learn(d, t0.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	score := func(bl *anomaly.Baseline, i int, v float64) []anomaly.Alert {
+		t.Helper()
+		set(t, d, "803.ModTmpAvg", v)
+		alerts, err := bl.Score(d, t0.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return alerts
+	}
+	// the series continues, so the rate of change is known
+	score(bl, 1000, temperature(1000))
+	for _, c := range []struct {
+		name   string
+		i      int
+		value  float64
+		alerts int
+	}{
+		{"usual", 1001, 30, 0},
+		{"usual rate", 1002, 31, 0},
+		{"usual at the phase", 1050, 40, 0},
+		{"falsified", 1051, -10, 2},
+		{"unusual at the phase", 1110, 40, 1},
+		{"persistent", 1111, 40, 1},
+	} {
+		alerts := score(bl, c.i, c.value)
+		if len(alerts) != c.alerts {
+			t.Errorf("%v: expected %v alerts for %v, got %v", c.name, c.alerts, c.value, alerts)
+		}
+		for _, a := range alerts {
+			if a.Score <= bl.Threshold || a.Paths[0] != "803.ModTmpAvg" {
+				t.Errorf("%v: unexpected alert %v", c.name, a)
+			}
+		}
+	}
+
+	// a saved baseline scores like the original
+	name := filepath.Join(t.TempDir(), "baseline.json")
+	if err := bl.Save(name); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := anomaly.LoadBaseline(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := loaded.Points["803.ModTmpAvg"]; p == nil || p.Value.N != 1000 || len(p.Phase) != 2 {
+		t.Fatalf("expected the learned profile, got %+v", p)
+	}
+	score(loaded, 2000, 30)
+	if alerts := score(loaded, 2001, -10); len(alerts) != 2 {
+		t.Errorf("expected 2 alerts of the loaded baseline, got %v", alerts)
+	}
+	if _, err := anomaly.LoadBaseline(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error loading a missing baseline")
+	}
+}
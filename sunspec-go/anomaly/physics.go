@@ -0,0 +1,282 @@
+// Package anomaly detects anomalous values of sunspec devices, e.g. values falsified in transit.
+//
+// The physics of a battery bank served by the models 802 and 803 is verified by cross checking its points:
+//
+//	ph := anomaly.NewPhysics()
+//	s := c.Scheduler(ph.Monitor(c, func(a anomaly.Alert) { log.Println(a) }))
+//	s.Register(time.Second, c.Model(802), c.Model(803))
+//	s.Run(ctx)
+//
+// Deviations from the usual behavior of points are scored against a Baseline learned from previous values.
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// Alert reports an anomaly of the device.
+type Alert struct {
+	// Time is the time the anomalous values were observed.
+	Time time.Time
+	// Invariant describes the violated relation, e.g. "803.ModTmpMin <= 803.ModTmpAvg <= 803.ModTmpMax".
+	Invariant string
+	// Paths references the involved points.
+	Paths []string
+	// Values are the scaled values of the involved points.
+	Values []float64
+	// Score is the deviation from a learned baseline in standard deviations, zero for physical invariants.
+	Score float64
+	// Message describes the anomaly.
+	Message string
+}
+
+// String returns a human readable representation of the alert.
+func (a Alert) String() string {
+	return fmt.Sprintf("%v [%v]: %v", a.Time.Format(time.RFC3339Nano), a.Invariant, a.Message)
+}
+
+// Physics verifies the physical consistency of a battery bank served by the models 802 and 803:
+//   - the minimum, average and maximum cell voltages, module temperatures, string voltages and currents are ordered
+//   - the values of every string are within the extremes of the bank
+//   - the current of the bank is the sum of the string currents
+//   - the state of charge follows the direction of the current
+//   - the module temperatures change no faster than their rate limit
+//
+// Invariants of points not implemented or not received are skipped.
+type Physics struct {
+	// TmpRate is the maximum rate of change of module temperatures in °C per second.
+	TmpRate float64
+	// Current is the tolerated deviation in A between the current of the bank and the sum of the string currents.
+	// Currents within the deviation are considered idle by the state of charge trend.
+	Current float64
+	// SoC is the tolerated change of the state of charge in % against the direction of the current.
+	SoC float64
+
+	mu   sync.Mutex
+	last map[sunspec.Point]sample
+	soc  sample
+}
+
+// sample is a value observed at a time.
+type sample struct {
+	time    time.Time
+	updated time.Time
+	value   float64
+	current float64
+}
+
+// NewPhysics returns an analyzer with the default tolerances.
+func NewPhysics() *Physics {
+	return &Physics{TmpRate: 0.1, Current: 1, SoC: 0.5}
+}
+
+// Analyze verifies the invariants against the current values of the device, observed at the time t.
+// Rates of change are calculated relative to the values of the previous analysis,
+// so the device should be analyzed after every read, e.g. of a poll or a captured response.
+// The values must not be modified concurrently, see sunspec.Client.View.
+func (ph *Physics) Analyze(d sunspec.Device, t time.Time) []Alert {
+	ph.mu.Lock()
+	defer ph.mu.Unlock()
+	if ph.last == nil {
+		ph.last = make(map[sunspec.Point]sample)
+	}
+	a := &analysis{t: t}
+	base, bank := d.Model(802), d.Model(803)
+	var strs sunspec.Groups
+	if bank != nil {
+		strs = bank.Groups("string")
+	}
+	if base != nil {
+		a.order(base, "CellVMin", "CellVAvg", "CellVMax")
+		for _, s := range strs {
+			a.within(base, "CellVMin", "CellVMax", s, "StrCellVMin", "StrCellVMax")
+		}
+		ph.trend(a, base)
+	}
+	if bank != nil {
+		a.order(bank, "ModTmpMin", "ModTmpAvg", "ModTmpMax")
+		a.order(bank, "StrVMin", "StrVAvg", "StrVMax")
+		a.order(bank, "StrAMin", "StrAAvg", "StrAMax")
+		ph.rate(a, bank, "ModTmpMin", "ModTmpAvg", "ModTmpMax")
+	}
+	for _, s := range strs {
+		a.order(s, "StrCellVMin", "StrCellVAvg", "StrCellVMax")
+		a.order(s, "StrModTmpMin", "StrModTmpAvg", "StrModTmpMax")
+		a.within(bank, "ModTmpMin", "ModTmpMax", s, "StrModTmpMin", "StrModTmpMax")
+		a.within(bank, "StrAMin", "StrAMax", s, "StrA", "StrA")
+		ph.rate(a, s, "StrModTmpMin", "StrModTmpAvg", "StrModTmpMax")
+	}
+	if base != nil && len(strs) > 0 {
+		ph.sum(a, base, strs)
+	}
+	return a.alerts
+}
+
+// Monitor returns a report function for the scheduler of the client, analyzing the device after every poll.
+// Alerts are reported by calling alert.
+func (ph *Physics) Monitor(c *sunspec.Client, alert func(Alert)) func(p sunspec.Poll) {
+	return func(p sunspec.Poll) {
+		if p.Err != nil && len(p.Points) == 0 {
+			return
+		}
+		var alerts []Alert
+		c.View(func(d sunspec.Device) error {
+			alerts = ph.Analyze(d, p.Started.Add(p.Latency))
+			return nil
+		})
+		for _, a := range alerts {
+			alert(a)
+		}
+	}
+}
+
+// analysis collects the alerts of a single analysis.
+type analysis struct {
+	t      time.Time
+	alerts []Alert
+}
+
+// raise reports the violated invariant of the points.
+func (a *analysis) raise(pts []sunspec.Point, vs []float64, invariant string, format string, args ...interface{}) {
+	paths := make([]string, len(pts))
+	for i, p := range pts {
+		paths[i] = sunspec.Path(p)
+	}
+	a.alerts = append(a.alerts, Alert{
+		Time:      a.t,
+		Invariant: invariant,
+		Paths:     paths,
+		Values:    vs,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// values returns the points of the group and their scaled values.
+// If any point is missing, not implemented or outdated, ok is false.
+func values(g sunspec.Group, names ...string) (pts []sunspec.Point, vs []float64, ok bool) {
+	for _, name := range names {
+		p := g.Point(name)
+		if p == nil || !p.Valid() || p.Err() != nil {
+			return nil, nil, false
+		}
+		v, err := sunspec.Scaled(p)
+		if err != nil {
+			return nil, nil, false
+		}
+		pts, vs = append(pts, p), append(vs, v)
+	}
+	return pts, vs, true
+}
+
+// leq compares the values, tolerating the imprecision of floating point scaling.
+func leq(a, b float64) bool {
+	return a <= b+1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// order verifies the minimum <= average <= maximum invariant of the points of the group.
+func (a *analysis) order(g sunspec.Group, min, avg, max string) {
+	pts, vs, ok := values(g, min, avg, max)
+	if !ok || leq(vs[0], vs[1]) && leq(vs[1], vs[2]) {
+		return
+	}
+	a.raise(pts, vs, fmt.Sprintf("%v <= %v <= %v", sunspec.Path(pts[0]), sunspec.Path(pts[1]), sunspec.Path(pts[2])),
+		"average %v is not within the minimum %v and maximum %v", vs[1], vs[0], vs[2])
+}
+
+// within verifies that the extremes of the string are within the extremes of the bank.
+func (a *analysis) within(bank sunspec.Group, min, max string, str sunspec.Group, strMin, strMax string) {
+	b, bv, ok := values(bank, min, max)
+	if !ok {
+		return
+	}
+	s, sv, ok := values(str, strMin, strMax)
+	if !ok {
+		return
+	}
+	if !leq(bv[0], sv[0]) {
+		a.raise([]sunspec.Point{b[0], s[0]}, []float64{bv[0], sv[0]}, sunspec.Path(b[0])+" <= "+sunspec.Path(s[0]),
+			"string value %v is below the minimum %v of the bank", sv[0], bv[0])
+	}
+	if !leq(sv[1], bv[1]) {
+		a.raise([]sunspec.Point{s[1], b[1]}, []float64{sv[1], bv[1]}, sunspec.Path(s[1])+" <= "+sunspec.Path(b[1]),
+			"string value %v is above the maximum %v of the bank", sv[1], bv[1])
+	}
+}
+
+// sum verifies that the current of the bank is the sum of the string currents.
+func (ph *Physics) sum(a *analysis, base sunspec.Group, strs sunspec.Groups) {
+	pts, vs, ok := values(base, "A")
+	if !ok {
+		return
+	}
+	var total float64
+	var names []string
+	for _, s := range strs {
+		p, v, ok := values(s, "StrA")
+		if !ok {
+			return
+		}
+		total += v[0]
+		pts, vs = append(pts, p[0]), append(vs, v[0])
+		names = append(names, sunspec.Path(p[0]))
+	}
+	if math.Abs(vs[0]-total) > ph.Current {
+		a.raise(pts, vs, sunspec.Path(pts[0])+" == "+strings.Join(names, " + "),
+			"current %v A of the bank deviates from the sum %v A of its strings", vs[0], total)
+	}
+}
+
+// trend verifies that the state of charge follows the direction of the current since the previous analysis.
+// Positive currents discharge the bank.
+func (ph *Physics) trend(a *analysis, base sunspec.Group) {
+	pts, vs, ok := values(base, "SoC", "A")
+	if !ok {
+		return
+	}
+	prev, now := ph.soc, sample{time: a.t, updated: pts[0].Updated(), value: vs[0], current: vs[1]}
+	if !now.updated.IsZero() && now.updated.Equal(prev.updated) {
+		return
+	}
+	ph.soc = now
+	if prev.time.IsZero() || !a.t.After(prev.time) {
+		return
+	}
+	current, delta := (prev.current+now.current)/2, now.value-prev.value
+	invariant := "sign(Δ" + sunspec.Path(pts[0]) + ") == -sign(" + sunspec.Path(pts[1]) + ")"
+	switch {
+	case current > ph.Current && delta > ph.SoC:
+		a.raise(pts, vs, invariant, "state of charge rises by %v %% while discharging by %v A", delta, current)
+	case current < -ph.Current && delta < -ph.SoC:
+		a.raise(pts, vs, invariant, "state of charge falls by %v %% while charging by %v A", -delta, -current)
+	case math.Abs(current) <= ph.Current && math.Abs(delta) > ph.SoC:
+		a.raise(pts, vs, invariant, "state of charge changes by %v %% while idle", delta)
+	}
+}
+
+// rate verifies the rate of change of the temperature points of the group since the previous analysis.
+func (ph *Physics) rate(a *analysis, g sunspec.Group, names ...string) {
+	for _, name := range names {
+		pts, vs, ok := values(g, name)
+		if !ok {
+			continue
+		}
+		p, now := pts[0], sample{time: a.t, updated: pts[0].Updated(), value: vs[0]}
+		prev, seen := ph.last[p]
+		if !now.updated.IsZero() && now.updated.Equal(prev.updated) {
+			continue
+		}
+		ph.last[p] = now
+		if dt := a.t.Sub(prev.time).Seconds(); seen && dt > 0 {
+			if r := math.Abs(now.value-prev.value) / dt; r > ph.TmpRate {
+				a.raise(pts, []float64{prev.value, now.value}, fmt.Sprintf("|d%v/dt| <= %v °C/s", sunspec.Path(p), ph.TmpRate),
+					"temperature changes from %v to %v °C at %.3g °C/s", prev.value, now.value, r)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package anomaly_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TRICERA-energy/sunspec"
+	"github.com/TRICERA-energy/sunspec/anomaly"
+)
+
+// battery are reduced definitions of the models 802 and 803 with two strings.
+var battery = []string{
+	`{"id": 802, "group": {"name": "battery", "type": "group", "points": [
+		{"name": "ID", "type": "uint16", "size": 1, "value": 802},
+		{"name": "L", "type": "uint16", "size": 1},
+		{"name": "SoC", "type": "uint16", "size": 1},
+		{"name": "CellVMax", "type": "uint16", "size": 1},
+		{"name": "CellVMin", "type": "uint16", "size": 1},
+		{"name": "CellVAvg", "type": "uint16", "size": 1},
+		{"name": "A", "type": "int16", "size": 1}]}}`,
+	`{"id": 803, "group": {"name": "lithium_ion_bank", "type": "group", "points": [
+		{"name": "ID", "type": "uint16", "size": 1, "value": 803},
+		{"name": "L", "type": "uint16", "size": 1},
+		{"name": "ModTmpMax", "type": "int16", "size": 1},
+		{"name": "ModTmpMin", "type": "int16", "size": 1},
+		{"name": "ModTmpAvg", "type": "int16", "size": 1},
+		{"name": "StrAMax", "type": "int16", "size": 1},
+		{"name": "StrAMin", "type": "int16", "size": 1},
+		{"name": "StrAAvg", "type": "int16", "size": 1}],
+		"groups": [{"name": "string", "type": "group", "count": 2, "points": [
+			{"name": "StrA", "type": "int16", "size": 1},
+			{"name": "StrCellVMax", "type": "uint16", "size": 1},
+			{"name": "StrCellVMin", "type": "uint16", "size": 1},
+			{"name": "StrCellVAvg", "type": "uint16", "size": 1},
+			{"name": "StrModTmpMax", "type": "int16", "size": 1},
+			{"name": "StrModTmpMin", "type": "int16", "size": 1},
+			{"name": "StrModTmpAvg", "type": "int16", "size": 1}]}]}}`,
+}
+
+func device(t *testing.T) sunspec.Device {
+	t.Helper()
+	var d sunspec.Models
+	adr := uint16(40002)
+	for _, s := range battery {
+		var def sunspec.ModelDef
+		if err := json.Unmarshal([]byte(s), &def); err != nil {
+			t.Fatal(err)
+		}
+		m, err := def.Instance(adr, func(pts []sunspec.Point) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, adr = append(d, m), adr+m.Quantity()
+	}
+	// a bank of 50 % discharging by 20 A at 30 °C
+	for path, v := range map[string]float64{
+		"802.SoC": 50, "802.CellVMax": 3400, "802.CellVMin": 3200, "802.CellVAvg": 3300, "802.A": 20,
+		"803.ModTmpMax": 31, "803.ModTmpMin": 29, "803.ModTmpAvg": 30, "803.StrAMax": 10, "803.StrAMin": 10, "803.StrAAvg": 10,
+		"803.string[*].StrA": 10, "803.string[*].StrCellVMax": 3350, "803.string[*].StrCellVMin": 3250, "803.string[*].StrCellVAvg": 3300,
+		"803.string[*].StrModTmpMax": 31, "803.string[*].StrModTmpMin": 29, "803.string[*].StrModTmpAvg": 30,
+	} {
+		set(t, d, path, v)
+	}
+	return d
+}
+
+// set assigns the scaled value to all points referenced by the path.
+func set(t *testing.T, d sunspec.Device, path string, v float64) {
+	t.Helper()
+	pts, err := d.Query(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pts {
+		if err := p.(interface{ SetValue(v float64) error }).SetValue(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPhysics(t *testing.T) {
+	t0 := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		name       string
+		changes    map[string]float64
+		invariants []string
+	}{
+		{"consistent", map[string]float64{"802.SoC": 49, "803.ModTmpAvg": 31, "803.ModTmpMax": 32}, nil},
+		{"falsified temperature", map[string]float64{"803.ModTmpAvg": -10}, []string{
+			"803.ModTmpMin <= 803.ModTmpAvg <= 803.ModTmpMax",
+			"|d803.ModTmpAvg/dt| <= 0.1 °C/s",
+		}},
+		{"cell voltages", map[string]float64{"803.string[1].StrCellVMin": 3320, "803.string[1].StrCellVMax": 3500}, []string{
+			"803.string[1].StrCellVMax <= 802.CellVMax",
+			"803.string[1].StrCellVMin <= 803.string[1].StrCellVAvg <= 803.string[1].StrCellVMax",
+		}},
+		{"string currents", map[string]float64{"802.A": 30}, []string{
+			"802.A == 803.string[0].StrA + 803.string[1].StrA",
+		}},
+		{"charge while discharging", map[string]float64{"802.SoC": 55}, []string{
+			"sign(Δ802.SoC) == -sign(802.A)",
+		}},
+	} {
+		d, ph := device(t), anomaly.NewPhysics()
+		if alerts := ph.Analyze(d, t0); len(alerts) != 0 {
+			t.Fatalf("%v: expected no alerts for the initial state, got %v", c.name, alerts)
+		}
+		for path, v := range c.changes {
+			set(t, d, path, v)
+		}
+		alerts := ph.Analyze(d, t0.Add(10*time.Second))
+		var invariants []string
+		for _, a := range alerts {
+			invariants = append(invariants, a.Invariant)
+		}
+		if strings.Join(invariants, "\n") != strings.Join(c.invariants, "\n") {
+			t.Errorf("%v: expected the violated invariants %q, got %v", c.name, c.invariants, alerts)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package sunspec
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/GoAethereal/cancel"
 	"github.com/GoAethereal/modbus"
@@ -11,7 +14,11 @@ import (
 type Client struct {
 	client
 	Device
-	logger Logger
+	logger        Logger
+	limits        Limits
+	subscriptions subscriptions
+	// values guards the point values against concurrent reads, writes and views.
+	values *sync.RWMutex
 }
 
 var _ Device = (*Client)(nil)
@@ -19,8 +26,10 @@ var _ Device = (*Client)(nil)
 // Scan analyses the server retrieving its device.
 // The process uses the given definition as reference.
 func (c *Client) Scan(ctx cancel.Context, defs ...Definition) (err error) {
-	c.Device, err = c.scan(ctx, defs)
-	return err
+	if c.Device, err = c.scan(ctx, defs); err != nil {
+		return err
+	}
+	return c.limits.apply(c.Device)
 }
 
 // Read requests all point values in the given address range from the server.
@@ -29,28 +38,165 @@ func (c *Client) Read(ctx cancel.Context, idx ...Index) (Points, error) {
 	if err != nil {
 		return nil, err
 	}
-	return c.read(ctx, pts...)
+	pts, err = c.read(ctx, pts...)
+	c.notify(pts)
+	return pts, err
+}
+
+// View allows reading the point values of the device consistently.
+// The function fn is called while no read is changing any value.
+// Values should only be accessed within a view, if the client is read concurrently, e.g. by a scheduler.
+func (c *Client) View(fn func(d Device) error) error {
+	c.values.RLock()
+	defer c.values.RUnlock()
+	return fn(c)
+}
+
+// Update allows changing the point values of the device locally, e.g. before writing them to the server.
+// The function fn is called exclusively, no read, write or view is accessing the values meanwhile.
+func (c *Client) Update(fn func(d Device) error) error {
+	c.values.Lock()
+	defer c.values.Unlock()
+	return fn(c)
 }
 
 // Write sends all point values in the given address range to the server.
 // Read-Only points are silently skipped.
+// If any value violates its point´s constraints nothing is sent and a *ConstraintError is returned.
 func (c *Client) Write(ctx cancel.Context, idx ...Index) (Points, error) {
-	pts, err := collect(c, idx...)
+	pts, _, err := c.writable(idx)
+	if err != nil {
+		return nil, err
+	}
+	c.values.RLock()
+	err = pts.check()
+	c.values.RUnlock()
 	if err != nil {
 		return nil, err
 	}
-	// filter out writable points ignore read only
-	var i int
-	for _, p := range pts {
+	return c.write(ctx, pts...)
+}
+
+// WriteResult is the detailed outcome of a write to the server.
+type WriteResult struct {
+	// Accepted contains all points acknowledged by the server.
+	Accepted Points
+	// Skipped contains all read-only points in the given address range.
+	Skipped Points
+	// Rejected contains all points refused by the server, e.g. by a modbus exception.
+	Rejected []Rejection
+	// Mismatches contains all accepted points whose read back value differs from the sent one.
+	// Only given if the write was verified.
+	Mismatches []Mismatch
+}
+
+// Rejection describes a point refused by the server.
+type Rejection struct {
+	Point Point
+	Err   error
+}
+
+// Mismatch describes a point whose value read back from the server differs from the sent value.
+type Mismatch struct {
+	Point    Point
+	Sent     interface{}
+	Received interface{}
+}
+
+// WriteReport sends all point values in the given address range to the server, like Write.
+// Transactions refused by the server do not stop the remaining ones, instead their points are
+// reported as rejected. Transactions containing values violating their point´s constraints
+// are not sent at all, but rejected with a *ConstraintError. If verify is set the accepted points are read back from the server
+// and compared to the sent values. Afterwards the points hold the values as read from the server.
+// An error is only returned if the write could not be attempted or the connection failed.
+// A range of read-only points only is reported as skipped, without sending anything.
+func (c *Client) WriteReport(ctx cancel.Context, verify bool, idx ...Index) (*WriteResult, error) {
+	pts, skipped, err := c.writable(idx)
+	if len(pts) == 0 && len(skipped) > 0 {
+		return &WriteResult{Skipped: skipped}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	col, err := chunks(123, pts)
+	if err != nil {
+		return nil, err
+	}
+	res := &WriteResult{Skipped: skipped}
+	for _, chunk := range col {
+		c.values.RLock()
+		err := chunk.check()
+		c.values.RUnlock()
+		if err != nil {
+			for _, p := range chunk {
+				res.Rejected = append(res.Rejected, Rejection{Point: p, Err: err})
+			}
+			continue
+		}
+		if _, err := c.write(ctx, chunk...); err != nil {
+			var ex modbus.Exception
+			if !errors.As(err, &ex) {
+				return res, err
+			}
+			for _, p := range chunk {
+				res.Rejected = append(res.Rejected, Rejection{Point: p, Err: err})
+			}
+			continue
+		}
+		res.Accepted = append(res.Accepted, chunk...)
+	}
+	if !verify || len(res.Accepted) == 0 {
+		return res, nil
+	}
+	// keep the sent values for comparison
+	c.values.RLock()
+	sent := make([]byte, 2*res.Accepted.Quantity())
+	err = res.Accepted.encode(sent)
+	values := make([]interface{}, len(res.Accepted))
+	for i, p := range res.Accepted {
+		values[i] = value(p)
+	}
+	c.values.RUnlock()
+	if err != nil {
+		return res, err
+	}
+	read, err := c.read(ctx, res.Accepted...)
+	c.notify(read)
+	if err != nil {
+		return res, err
+	}
+	c.values.RLock()
+	defer c.values.RUnlock()
+	received := make([]byte, len(sent))
+	if err := res.Accepted.encode(received); err != nil {
+		return res, err
+	}
+	for i, p := range res.Accepted {
+		if n := 2 * int(p.Quantity()); !bytes.Equal(sent[:n], received[:n]) {
+			res.Mismatches = append(res.Mismatches, Mismatch{Point: p, Sent: values[i], Received: value(p)})
+		}
+		sent, received = sent[2*p.Quantity():], received[2*p.Quantity():]
+	}
+	return res, nil
+}
+
+// writable collects all points in the given address range, separating the writable from the read-only points.
+func (c *Client) writable(idx []Index) (pts, skipped Points, err error) {
+	col, err := collect(c, idx...)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range col {
 		if p.Writable() {
-			pts[i] = p
-			i++
+			pts = append(pts, p)
+		} else {
+			skipped = append(skipped, p)
 		}
 	}
 	if len(pts) == 0 {
-		return nil, errors.New("sunspec: no writable points for given index")
+		return nil, skipped, errors.New("sunspec: no writable points for given index")
 	}
-	return c.write(ctx, pts[:i]...)
+	return pts, skipped, nil
 }
 
 type client interface {
@@ -61,6 +207,7 @@ type client interface {
 	scan(ctx cancel.Context, defs []Definition) (Device, error)
 	read(ctx cancel.Context, pts ...Point) (Points, error)
 	write(ctx cancel.Context, pts ...Point) (Points, error)
+	relay(ctx cancel.Context, code byte, req []byte) ([]byte, error)
 }
 
 var _ client = (*mbClient)(nil)
@@ -68,16 +215,21 @@ var _ client = (*mbClient)(nil)
 type mbClient struct {
 	mb     *modbus.Client
 	logger Logger
+	values *sync.RWMutex
+	// mu serializes the transactions, as the connection does not support concurrent requests.
+	mu sync.Mutex
 }
 
-func newModbusClient(endpoint string, l Logger) *mbClient {
+func newModbusClient(endpoint string, unit uint8, l Logger, values *sync.RWMutex) *mbClient {
 	return &mbClient{
 		mb: (modbus.Config{
 			Mode:     "tcp",
 			Kind:     "tcp",
 			Endpoint: endpoint,
+			Unit:     unit,
 		}).Client(),
 		logger: l,
+		values: values,
 	}
 }
 
@@ -141,41 +293,115 @@ func (c *mbClient) marker(ctx cancel.Context) (uint16, error) {
 }
 
 // read attempts to request the data for all given points from the modbus endpoint.
+// Points which could not be read are marked as outdated.
 func (c *mbClient) read(ctx cancel.Context, pts ...Point) (Points, error) {
-	return c.execute(125, pts, func(pts Points) error {
+	// decoded counts the points of the failing chunk which were received nonetheless
+	var decoded int
+	res, err := c.execute(125, pts, func(pts Points) error {
+		decoded = 0
+		c.mu.Lock()
 		res, err := c.mb.ReadHoldingRegisters(ctx, pts.address(), pts.Quantity())
+		c.mu.Unlock()
 		if err != nil {
 			return err
 		}
-		return pts.decode(res)
+		c.values.Lock()
+		defer c.values.Unlock()
+		decoded, err = pts.decode(res)
+		return err
 	})
+	if err != nil {
+		c.values.Lock()
+		Points(pts[len(res)+decoded:]).fail(err)
+		c.values.Unlock()
+	}
+	return res, err
 }
 
 // write attempts to send the point values of all given points to the modbus endpoint
 func (c *mbClient) write(ctx cancel.Context, pts ...Point) (Points, error) {
 	return c.execute(123, pts, func(pts Points) error {
 		req := make([]byte, 2*pts.Quantity())
-		if err := pts.encode(req); err != nil {
+		c.values.RLock()
+		err := pts.encode(req)
+		c.values.RUnlock()
+		if err != nil {
 			return err
 		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
 		return c.mb.WriteMultipleRegisters(ctx, pts.address(), req)
 	})
 }
 
 // execute calls back cmd for all given points.
 // The input collection is split in regards to their modbus continuity limited by the given register limit.
-// 	ToDo: still needs handling for sync groups
+// Points of a sync group are never split, they are always transferred in a single transaction.
 func (c *mbClient) execute(limit uint16, pts Points, cmd func(pts Points) error) (Points, error) {
-	for i, j, l := 1, 0, len(pts); j < l; j = i {
-		for _, p := range pts[i:] {
-			if ceil(pts[i-1]) != p.Address() || ceil(p)-pts[j].Address() > limit {
+	col, err := chunks(limit, pts)
+	if err != nil {
+		return nil, err
+	}
+	var n int
+	for _, chunk := range col {
+		if err := cmd(chunk); err != nil {
+			return pts[:n], err
+		}
+		n += len(chunk)
+	}
+	return pts, nil
+}
+
+// relay sends the raw request of the function code to the modbus endpoint and returns its raw response.
+func (c *mbClient) relay(ctx cancel.Context, code byte, req []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mb.Request(ctx, code, req)
+}
+
+// chunks splits the collection in regards to their modbus continuity limited by the given register limit.
+// Points of a sync group are never split.
+// The resulting chunks are consecutive sub-slices of the collection.
+func chunks(limit uint16, pts Points) ([]Points, error) {
+	bounds, err := units(limit, pts)
+	if err != nil {
+		return nil, err
+	}
+	var col []Points
+	for i, j, l := 1, 0, len(bounds)-1; j < l; j, i = i, i+1 {
+		first, last := pts[bounds[j]], pts[bounds[i]-1]
+		for _, b := range bounds[i+1:] {
+			p := pts[b-1]
+			if ceil(last) != pts[bounds[i]].Address() || ceil(p)-first.Address() > limit {
 				break
 			}
+			last = p
 			i++
 		}
-		if err := cmd(pts[j:i]); err != nil {
-			return pts[:j], err
+		col = append(col, pts[bounds[j]:bounds[i]])
+	}
+	return col, nil
+}
+
+// units splits the collection into indivisible units, returning the starting position of each
+// unit followed by the length of the collection.
+// All immediate points of a sync group form a single unit, any other point a unit on its own.
+// An error is returned if a sync group is not continuous or exceeds the register limit.
+func units(limit uint16, pts Points) ([]int, error) {
+	bounds := make([]int, 0, len(pts)+1)
+	for i, p := range pts {
+		if i > 0 {
+			if g := p.Origin(); g != nil && g.Atomic() && g == pts[i-1].Origin() {
+				if ceil(pts[i-1]) != p.Address() {
+					return nil, fmt.Errorf("sunspec: sync group %q is not continuous", g.Name())
+				}
+				if ceil(p)-pts[bounds[len(bounds)-1]].Address() > limit {
+					return nil, fmt.Errorf("sunspec: sync group %q exceeds the limit of %v registers", g.Name(), limit)
+				}
+				continue
+			}
 		}
+		bounds = append(bounds, i)
 	}
-	return pts, nil
+	return append(bounds, len(pts)), nil
 }
@@ -0,0 +1,96 @@
+package sunspec
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// block is a model with a sync group of 123 registers, the limit of a single write, followed by two points.
+const block = `{"id": 64010, "group": {"name": "block", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "P", "type": "uint16", "size": 1},
+	{"name": "Q", "type": "uint16", "size": 1}],
+	"groups": [
+		{"name": "sync", "type": "sync", "points": [
+			{"name": "S1", "type": "string", "size": 60},
+			{"name": "S2", "type": "uint16", "size": 1},
+			{"name": "S3", "type": "string", "size": 62}]},
+		{"name": "tail", "type": "group", "points": [
+			{"name": "T", "type": "uint16", "size": 1},
+			{"name": "U", "type": "uint16", "size": 1}]}]}}`
+
+func TestChunks(t *testing.T) {
+	var def ModelDef
+	if err := json.Unmarshal([]byte(block), &def); err != nil {
+		t.Fatal(err)
+	}
+	m, err := def.Instance(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := collect(Models{m}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]Point)
+	for _, p := range all {
+		byName[p.Name()] = p
+	}
+
+	testCases := []struct {
+		name   string
+		limit  uint16
+		points []string
+		want   [][]string
+		fail   bool
+	}{
+		{name: "empty", limit: 125},
+		{name: "single", limit: 125, points: []string{"P"}, want: [][]string{{"P"}}},
+		{name: "read limit", limit: 125, points: []string{"ID", "L", "P", "Q", "S1", "S2", "S3", "T", "U"},
+			want: [][]string{{"ID", "L", "P", "Q"}, {"S1", "S2", "S3", "T", "U"}}},
+		{name: "write limit", limit: 123, points: []string{"ID", "L", "P", "Q", "S1", "S2", "S3", "T", "U"},
+			want: [][]string{{"ID", "L", "P", "Q"}, {"S1", "S2", "S3"}, {"T", "U"}}},
+		{name: "gaps", limit: 125, points: []string{"ID", "L", "Q", "T", "U"},
+			want: [][]string{{"ID", "L"}, {"Q"}, {"T", "U"}}},
+		{name: "sync group at the limit", limit: 124, points: []string{"Q", "S1", "S2", "S3"},
+			want: [][]string{{"Q", "S1", "S2", "S3"}}},
+		{name: "sync group not split", limit: 123, points: []string{"Q", "S1", "S2", "S3"},
+			want: [][]string{{"Q"}, {"S1", "S2", "S3"}}},
+		{name: "partial sync group", limit: 125, points: []string{"S1", "S2"},
+			want: [][]string{{"S1", "S2"}}},
+		{name: "sync group exceeds the limit", limit: 122, points: []string{"S1", "S2", "S3"}, fail: true},
+		{name: "sync group not continuous", limit: 125, points: []string{"S1", "S3"}, fail: true},
+	}
+
+	for _, tc := range testCases {
+		var pts Points
+		for _, name := range tc.points {
+			pts = append(pts, byName[name])
+		}
+		col, err := chunks(tc.limit, pts)
+		switch {
+		case tc.fail && err == nil:
+			t.Fatalf("%v: chunked %v with limit %v; want error", tc.name, tc.points, tc.limit)
+		case !tc.fail && err != nil:
+			t.Fatalf("%v: refused %v with limit %v: %v", tc.name, tc.points, tc.limit, err)
+		case tc.fail:
+			continue
+		}
+		var got [][]string
+		for _, chunk := range col {
+			if chunk.Quantity() > tc.limit {
+				t.Fatalf("%v: chunk of %v registers exceeds the limit %v", tc.name, chunk.Quantity(), tc.limit)
+			}
+			var names []string
+			for _, p := range chunk {
+				names = append(names, p.Name())
+			}
+			got = append(got, names)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%v: chunked %v with limit %v into %v; want %v", tc.name, tc.points, tc.limit, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package sunspec_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// setpoints is a model of writable points separated by read-only points.
+const setpoints = `{"id": 64090, "group": {"name": "setpoints", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64090},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "A", "type": "uint16", "size": 1, "access": "RW"},
+	{"name": "St", "type": "uint16", "size": 1},
+	{"name": "B", "type": "uint16", "size": 1, "access": "RW"},
+	{"name": "Mn", "type": "string", "size": 2},
+	{"name": "C", "type": "uint16", "size": 1, "access": "RW", "max": 100}]}}`
+
+func TestWriteReport(t *testing.T) {
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	def := definition(t, setpoints)
+
+	// the server refuses writes of A and increments the written values of B
+	endpoint := free(t)
+	s := sunspec.Config{Endpoint: endpoint}.Server()
+	s.OnWrite("64090.B", sunspec.WriteHook{Validate: func(c sunspec.Change) error {
+		p := c.Point.(sunspec.Uint16)
+		return p.Set(p.Get() + 1)
+	}})
+	start(t, ctx, s, endpoint, ingest, def)
+	if err := s.InjectFault(sunspec.Fault{Name: "busy", Kind: sunspec.FaultException, Path: "64090.A", Writes: true, Exception: modbus.SlaveDeviceBusy}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := sunspec.Config{Endpoint: endpoint}.Client()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if err := c.Scan(ctx, def); err != nil {
+		t.Fatal(err)
+	}
+	m := c.Model(64090)
+	if err := c.Update(func(d sunspec.Device) error {
+		m := d.Model(64090)
+		m.Point("A").(sunspec.Uint16).Set(1)
+		m.Point("B").(sunspec.Uint16).Set(5)
+		return m.Point("C").(sunspec.Uint16).Set(200)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the transaction of B is sent after the one of A was refused, C violates its limit
+	res, err := c.WriteReport(ctx, true, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := names(res.Skipped); names != "ID L St Mn" {
+		t.Errorf("expected the read-only points to be skipped, got %v", names)
+	}
+	if names := names(res.Accepted); names != "B" {
+		t.Errorf("expected B to be accepted, got %v", names)
+	}
+	var ce *sunspec.ConstraintError
+	switch {
+	case len(res.Rejected) != 2:
+		t.Fatalf("expected A and C to be rejected, got %v", res.Rejected)
+	case res.Rejected[0].Point.Name() != "A" || !errors.Is(res.Rejected[0].Err, modbus.SlaveDeviceBusy):
+		t.Errorf("expected A to be rejected by exception %v, got %v", modbus.SlaveDeviceBusy, res.Rejected[0])
+	case res.Rejected[1].Point.Name() != "C" || !errors.As(res.Rejected[1].Err, &ce):
+		t.Errorf("expected C to be rejected by its constraint, got %v", res.Rejected[1])
+	}
+	switch {
+	case len(res.Mismatches) != 1:
+		t.Fatalf("expected the mismatch of B, got %v", res.Mismatches)
+	case res.Mismatches[0].Point.Name() != "B" || res.Mismatches[0].Sent != 5.0 || res.Mismatches[0].Received != 6.0:
+		t.Errorf("expected B to be sent as 5 and received as 6, got %+v", res.Mismatches[0])
+	}
+	if err := s.View(func(d sunspec.Device) error {
+		if a, b := d.Model(64090).Point("A").(sunspec.Uint16).Get(), d.Model(64090).Point("B").(sunspec.Uint16).Get(); a != 0 || b != 6 {
+			t.Errorf("expected the served values 0 and 6, got %v and %v", a, b)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// without verification nothing is read back
+	if err := c.Update(func(d sunspec.Device) error { return d.Model(64090).Point("B").(sunspec.Uint16).Set(5) }); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = c.WriteReport(ctx, false, m.Point("B")); err != nil {
+		t.Fatal(err)
+	}
+	if names(res.Accepted) != "B" || res.Mismatches != nil {
+		t.Errorf("expected B to be accepted without verification, got %+v", res)
+	}
+
+	// ranges of read-only points are reported as skipped
+	if res, err = c.WriteReport(ctx, false, m.Point("St")); err != nil {
+		t.Fatal(err)
+	}
+	if names(res.Skipped) != "St" || res.Accepted != nil || res.Rejected != nil {
+		t.Errorf("expected St to be skipped, got %+v", res)
+	}
+}
+
+// names returns the space separated names of the points.
+func names(pts sunspec.Points) string {
+	var s string
+	for i, p := range pts {
+		if i > 0 {
+			s += " "
+		}
+		s += p.Name()
+	}
+	return s
+}
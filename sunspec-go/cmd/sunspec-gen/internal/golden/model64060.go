@@ -0,0 +1,143 @@
+// Code generated by sunspec-gen. DO NOT EDIT.
+
+package golden
+
+import (
+	"fmt"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// NewModel64060 binds the typed representation of model 64060 to the instantiated model m.
+func NewModel64060(m sunspec.Model) (*Model64060, error) {
+	if m == nil || m.ID() == nil || m.ID().Get() != 64060 {
+		return nil, fmt.Errorf("sunspec-gen: model is not of id 64060")
+	}
+	t := &Model64060{Model: m}
+	if err := t.bind(m); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Model64060 is the typed representation of model 64060 (Test Inverter).
+type Model64060 struct {
+	sunspec.Model
+	ID sunspec.Uint16
+	L  sunspec.Uint16
+	// State
+	St  sunspec.Enum16
+	Evt sunspec.Bitfield16
+	// Watts [W]
+	W    sunspec.Int16
+	W_SF sunspec.Sunssf
+	N    sunspec.Uint16
+	// Controls
+	Ctl    Model64060Ctl
+	Module []Model64060Module
+}
+
+// Symbols of the points in Model64060.
+const (
+	// Off
+	Model64060StOff uint16 = 1
+	// On Grid
+	Model64060StOnGrid    uint16 = 2
+	Model64060EvtOverTemp int    = 0
+	// Ground fault detected.
+	Model64060EvtGroundFault int = 1
+)
+
+// bind assigns all fields from the points and groups of g.
+func (t *Model64060) bind(g sunspec.Group) error {
+	if x, ok := g.Point("ID").(sunspec.Uint16); ok {
+		t.ID = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.Uint16", "ID", g.Name())
+	}
+	if x, ok := g.Point("L").(sunspec.Uint16); ok {
+		t.L = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.Uint16", "L", g.Name())
+	}
+	if x, ok := g.Point("St").(sunspec.Enum16); ok {
+		t.St = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.Enum16", "St", g.Name())
+	}
+	if x, ok := g.Point("Evt").(sunspec.Bitfield16); ok {
+		t.Evt = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.Bitfield16", "Evt", g.Name())
+	}
+	if x, ok := g.Point("W").(sunspec.Int16); ok {
+		t.W = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.Int16", "W", g.Name())
+	}
+	if x, ok := g.Point("W_SF").(sunspec.Sunssf); ok {
+		t.W_SF = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.Sunssf", "W_SF", g.Name())
+	}
+	if x, ok := g.Point("N").(sunspec.Uint16); ok {
+		t.N = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.Uint16", "N", g.Name())
+	}
+	if x := g.Group("ctl"); x == nil {
+		return fmt.Errorf("sunspec-gen: group %q lacks the group %q", g.Name(), "ctl")
+	} else if err := t.Ctl.bind(x); err != nil {
+		return err
+	}
+	t.Module = nil
+	for _, g := range g.Groups("module") {
+		var x Model64060Module
+		if err := x.bind(g); err != nil {
+			return err
+		}
+		t.Module = append(t.Module, x)
+	}
+	return nil
+}
+
+// Model64060Ctl is the typed representation of the group Model64060Ctl (Controls).
+type Model64060Ctl struct {
+	sunspec.Group
+	WMax sunspec.Uint16
+}
+
+// bind assigns all fields from the points and groups of g.
+func (t *Model64060Ctl) bind(g sunspec.Group) error {
+	t.Group = g
+	if x, ok := g.Point("WMax").(sunspec.Uint16); ok {
+		t.WMax = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.Uint16", "WMax", g.Name())
+	}
+	return nil
+}
+
+// Model64060Module is the typed representation of the group Model64060Module.
+type Model64060Module struct {
+	sunspec.Group
+	// [C]
+	Tmp sunspec.Int16
+	Id  sunspec.String
+}
+
+// bind assigns all fields from the points and groups of g.
+func (t *Model64060Module) bind(g sunspec.Group) error {
+	t.Group = g
+	if x, ok := g.Point("Tmp").(sunspec.Int16); ok {
+		t.Tmp = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.Int16", "Tmp", g.Name())
+	}
+	if x, ok := g.Point("Id").(sunspec.String); ok {
+		t.Id = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type sunspec.String", "Id", g.Name())
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+// Command sunspec-gen generates typed go representations of sunspec models.
+//
+// The generator reads one or more model definitions (json) and emits a go type for each model,
+// holding its points as fields of their corresponding sunspec type.
+// Repeating groups are represented as slices and the symbols of enumerated and bitfield points as constants.
+// The types are bound to an instantiated sunspec.Model, for example:
+//
+//	sunspec-gen -pkg battery -o model803.go model803.json
+//
+//	m, err := battery.NewModel803(c.Model(803))
+//	soc := m.String[0].StrSoC.Value()
+//
+// All definitions given in a single run are written into one file.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+var (
+	pkg = flag.String("pkg", "models", "package name of the generated file")
+	out = flag.String("o", "", "output file, defaults to stdout")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: sunspec-gen [flags] model.json...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f := file{Package: *pkg}
+	for _, name := range flag.Args() {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		var def sunspec.ModelDef
+		// unmarshal a model schema into it´s go definition
+		if err := json.Unmarshal(b, &def); err != nil {
+			log.Fatalln(name+":", err)
+		}
+		f.Models = append(f.Models, newModel(def))
+	}
+
+	src, err := f.generate()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0666); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// file is the content of a generated go file.
+type file struct {
+	Package string
+	Models  []model
+}
+
+// model is the generated representation of a model definition.
+type model struct {
+	ID    uint16
+	Label string
+	// Types contains the model type itself followed by the types of all its sub-groups.
+	Types []*typ
+}
+
+// typ is the generated representation of a group definition.
+type typ struct {
+	Name    string
+	Comment string
+	Fields  []field
+	Consts  []constant
+}
+
+// field is a member of a generated type, referencing a point or sub-group.
+type field struct {
+	Name     string
+	Ref      string
+	Type     string
+	Comment  string
+	Group    bool
+	Repeated bool
+}
+
+// constant is a symbol of an enumerated or bitfield point.
+type constant struct {
+	Name    string
+	Type    string
+	Value   uint32
+	Comment string
+}
+
+// newModel derives the generated representation from the model definition.
+func newModel(def sunspec.ModelDef) model {
+	m := model{ID: def.Id, Label: def.Label}
+	if m.Label == "" {
+		m.Label = def.Group.Label
+	}
+	var walk func(name string, def sunspec.GroupDef) *typ
+	walk = func(name string, def sunspec.GroupDef) *typ {
+		t := &typ{Name: name, Comment: comment(def.Label, def.Description)}
+		m.Types = append(m.Types, t)
+		// the embedded sunspec types are reserved
+		names := map[string]bool{"Model": true, "Group": true}
+		for _, p := range def.Points {
+			f := field{
+				Name:     identifier(p.Name, names),
+				Ref:      p.Name,
+				Type:     "sunspec." + kind(p.Type),
+				Comment:  comment(p.Label, p.Description),
+				Repeated: repeated(p.Count),
+			}
+			if p.Units != "" {
+				f.Comment = strings.TrimSpace(f.Comment + " [" + p.Units + "]")
+			}
+			t.Fields = append(t.Fields, f)
+			for _, s := range p.Symbols {
+				c := constant{
+					Name:    name + f.Name + camel(s.Name),
+					Value:   s.Value,
+					Comment: comment(s.Label, s.Description),
+				}
+				switch p.Type {
+				case "enum16":
+					c.Type = "uint16"
+				case "enum32":
+					c.Type = "uint32"
+				default:
+					// symbols of bitfields are referencing the bit position
+					c.Type = "int"
+				}
+				t.Consts = append(t.Consts, c)
+			}
+		}
+		for _, g := range def.Groups {
+			f := field{
+				Name:     identifier(g.Name, names),
+				Ref:      g.Name,
+				Comment:  comment(g.Label, g.Description),
+				Group:    true,
+				Repeated: repeated(g.Count),
+			}
+			f.Type = walk(name+f.Name, g).Name
+			t.Fields = append(t.Fields, f)
+		}
+		return t
+	}
+	walk(fmt.Sprintf("Model%v", def.Id), def.Group)
+	return m
+}
+
+// generate renders the file into formatted go source code.
+func (f file) generate() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, f); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// kind returns the name of the sunspec interface representing the given point type.
+func kind(t string) string {
+	switch t {
+	case "ipv6addr":
+		return "Ipv6addr"
+	case "eui48":
+		return "Eui48"
+	}
+	for _, prefix := range []string{"bitfield", "float", "int", "uint", "acc", "enum"} {
+		if strings.HasPrefix(t, prefix) {
+			return strings.Title(prefix) + t[len(prefix):]
+		}
+	}
+	return strings.Title(t)
+}
+
+// repeated determines whether the count describes more than a single occurrence.
+func repeated(c interface{}) bool {
+	switch v := c.(type) {
+	case nil:
+		return false
+	case float64:
+		return v != 1
+	}
+	return true
+}
+
+// identifier converts the sunspec name into a unique exported go identifier.
+func identifier(name string, names map[string]bool) string {
+	id := strings.Title(name)
+	if id == "" || id[0] >= '0' && id[0] <= '9' {
+		id = "P" + id
+	}
+	for names[id] {
+		id += "_"
+	}
+	names[id] = true
+	return id
+}
+
+// camel converts a symbol name like NO_FAILURE into camel case like NoFailure.
+func camel(name string) string {
+	var b strings.Builder
+	for _, s := range strings.Split(name, "_") {
+		if s != "" {
+			b.WriteString(strings.ToUpper(s[:1]) + strings.ToLower(s[1:]))
+		}
+	}
+	return b.String()
+}
+
+// comment joins the label and description into a single line.
+func comment(label, desc string) string {
+	s := strings.TrimSpace(strings.TrimSpace(label) + " - " + strings.TrimSpace(desc))
+	s = strings.Trim(s, "- ")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var tmpl = template.Must(template.New("file").Parse(`// Code generated by sunspec-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"fmt"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+{{range $m := .Models}}{{$root := index .Types 0}}
+// New{{$root.Name}} binds the typed representation of model {{.ID}} to the instantiated model m.
+func New{{$root.Name}}(m sunspec.Model) (*{{$root.Name}}, error) {
+	if m == nil || m.ID() == nil || m.ID().Get() != {{.ID}} {
+		return nil, fmt.Errorf("sunspec-gen: model is not of id {{.ID}}")
+	}
+	t := &{{$root.Name}}{Model: m}
+	if err := t.bind(m); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+{{range $i, $t := .Types}}
+// {{.Name}} is the typed representation of {{if eq $i 0}}model {{$m.ID}}{{if $m.Label}} ({{$m.Label}}){{end}}{{else}}the group {{.Name}}{{if .Comment}} ({{.Comment}}){{end}}{{end}}.
+type {{.Name}} struct {
+	{{if eq $i 0}}sunspec.Model{{else}}sunspec.Group{{end}}
+{{range .Fields}}{{if .Comment}}	// {{.Comment}}
+{{end}}	{{.Name}} {{if .Repeated}}[]{{end}}{{.Type}}
+{{end}}}
+{{if .Consts}}
+// Symbols of the points in {{.Name}}.
+const (
+{{range .Consts}}{{if .Comment}}	// {{.Comment}}
+{{end}}	{{.Name}} {{.Type}} = {{.Value}}
+{{end}})
+{{end}}
+// bind assigns all fields from the points and groups of g.
+func (t *{{.Name}}) bind(g sunspec.Group) error {
+{{- if ne $i 0}}
+	t.Group = g
+{{- end}}
+{{- range .Fields}}
+{{- if .Group}}
+{{- if .Repeated}}
+	t.{{.Name}} = nil
+	for _, g := range g.Groups({{printf "%q" .Ref}}) {
+		var x {{.Type}}
+		if err := x.bind(g); err != nil {
+			return err
+		}
+		t.{{.Name}} = append(t.{{.Name}}, x)
+	}
+{{- else}}
+	if x := g.Group({{printf "%q" .Ref}}); x == nil {
+		return fmt.Errorf("sunspec-gen: group %q lacks the group %q", g.Name(), {{printf "%q" .Ref}})
+	} else if err := t.{{.Name}}.bind(x); err != nil {
+		return err
+	}
+{{- end}}
+{{- else}}
+{{- if .Repeated}}
+	t.{{.Name}} = nil
+	for _, p := range g.Points({{printf "%q" .Ref}}) {
+		x, ok := p.({{.Type}})
+		if !ok {
+			return fmt.Errorf("sunspec-gen: point %q in group %q is not of type {{.Type}}", {{printf "%q" .Ref}}, g.Name())
+		}
+		t.{{.Name}} = append(t.{{.Name}}, x)
+	}
+{{- else}}
+	if x, ok := g.Point({{printf "%q" .Ref}}).({{.Type}}); ok {
+		t.{{.Name}} = x
+	} else {
+		return fmt.Errorf("sunspec-gen: point %q in group %q is missing or not of type {{.Type}}", {{printf "%q" .Ref}}, g.Name())
+	}
+{{- end}}
+{{- end}}
+{{- end}}
+	return nil
+}
+{{end}}{{end}}`))
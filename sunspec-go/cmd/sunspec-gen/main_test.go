@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"go/format"
+	"os"
+	"testing"
+
+	"github.com/TRICERA-energy/sunspec"
+	"github.com/TRICERA-energy/sunspec/cmd/sunspec-gen/internal/golden"
+)
+
+var update = flag.Bool("update", false, "update the golden file")
+
+// inverter is a model of enumerated and bitfield symbols, a fixed and a repeating group.
+const inverter = `{"id": 64060, "label": "Test Inverter", "group": {"name": "inverter", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64060},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "St", "type": "enum16", "size": 1, "label": "State", "symbols": [
+		{"name": "OFF", "value": 1, "label": "Off"},
+		{"name": "ON_GRID", "value": 2, "label": "On Grid"}]},
+	{"name": "Evt", "type": "bitfield16", "size": 1, "symbols": [
+		{"name": "OVER_TEMP", "value": 0},
+		{"name": "GROUND_FAULT", "value": 1, "desc": "Ground fault detected."}]},
+	{"name": "W", "type": "int16", "size": 1, "sf": "W_SF", "units": "W", "label": "Watts"},
+	{"name": "W_SF", "type": "sunssf", "size": 1},
+	{"name": "N", "type": "uint16", "size": 1}],
+	"groups": [
+		{"name": "ctl", "type": "group", "label": "Controls", "points": [
+			{"name": "WMax", "type": "uint16", "size": 1, "access": "RW"}]},
+		{"name": "module", "type": "group", "count": "N", "points": [
+			{"name": "Tmp", "type": "int16", "size": 1, "units": "C"},
+			{"name": "Id", "type": "string", "size": 4}]}]}}`
+
+func TestGenerate(t *testing.T) {
+	var def sunspec.ModelDef
+	if err := json.Unmarshal([]byte(inverter), &def); err != nil {
+		t.Fatal(err)
+	}
+	src, err := file{Package: "golden", Models: []model{newModel(def)}}.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted, err := format.Source(src); err != nil || !bytes.Equal(formatted, src) {
+		t.Errorf("expected the generated code to be formatted, got error %v", err)
+	}
+
+	// the golden file is compiled as package, verifying the generated code
+	name := "internal/golden/model64060.go"
+	if *update {
+		if err := os.WriteFile(name, src, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Fatalf("generated code differs from %v, run the test with -update to inspect:\n%s", name, src)
+	}
+
+	// binding the generated type to an instance of the model
+	m, err := def.Instance(40002, func(pts []sunspec.Point) error {
+		if n, ok := pts[len(pts)-1].(sunspec.Uint16); ok && n.Name() == "N" {
+			n.Set(2)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := golden.NewModel64060(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x.Module) != 2 {
+		t.Fatalf("expected 2 bound modules, got %v", len(x.Module))
+	}
+	x.St.Set(golden.Model64060StOnGrid)
+	if m.Point("St").(sunspec.Enum16).Get() != 2 {
+		t.Error("expected the bound point to be the point of the model")
+	}
+	if x.Module[1].Group != m.Groups("module")[1] || x.Module[1].Tmp != m.Groups("module")[1].Point("Tmp") {
+		t.Error("expected the bound group to be the group of the model")
+	}
+	if x.Ctl.WMax != m.Group("ctl").Point("WMax") {
+		t.Error("expected the bound fixed group to be the group of the model")
+	}
+	if golden.Model64060EvtGroundFault != 1 {
+		t.Errorf("expected the symbol to be the bit position 1, got %v", golden.Model64060EvtGroundFault)
+	}
+	if _, err := golden.NewModel64060(nil); err == nil {
+		t.Error("expected an error binding no model")
+	}
+}
@@ -0,0 +1,109 @@
+// Command sunspec-ids detects intrusions in captured modbus traffic of a sunspec device.
+//
+// The capture is read in the pcap format, either from a file or as a live stream from the standard input:
+//
+//	tcpdump -i eth0 -U -w - tcp port 502 | sunspec-ids -target 172.16.238.10:502 model802.json model803.json
+//
+// The device is scanned using the given model definitions (json), so the values in transit are decoded into its points.
+// Without target only the protocol is verified. Every alert is written to the standard output.
+// The flag -physics additionally verifies the physical consistency of the battery models 802 and 803
+// after every response, see anomaly.Physics.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/TRICERA-energy/sunspec"
+	"github.com/TRICERA-energy/sunspec/anomaly"
+	"github.com/TRICERA-energy/sunspec/ids"
+)
+
+var (
+	input   = flag.String("r", "-", "pcap file to read, - for the standard input")
+	target  = flag.String("target", "", "endpoint of the device to scan")
+	unit    = flag.Uint("unit", 0, "modbus unit id of the device")
+	port    = flag.Uint("port", 502, "tcp port of the device")
+	codes   = flag.String("codes", "3,16", "comma separated function codes clients are allowed to request")
+	physics = flag.Bool("physics", false, "verify the physical consistency of the decoded battery values")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: sunspec-ids [flags] model.json...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *unit > 0xFF || *port > 0xFFFF || *physics && *target == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var allowed []byte
+	for _, s := range strings.Split(*codes, ",") {
+		c, err := strconv.ParseUint(strings.TrimSpace(s), 0, 8)
+		if err != nil {
+			log.Fatalln("invalid function code:", s)
+		}
+		allowed = append(allowed, byte(c))
+	}
+
+	var d sunspec.Device
+	if *target != "" {
+		var defs []sunspec.Definition
+		for _, name := range flag.Args() {
+			b, err := os.ReadFile(name)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			var def sunspec.ModelDef
+			if err := json.Unmarshal(b, &def); err != nil {
+				log.Fatalln(name+":", err)
+			}
+			defs = append(defs, &def)
+		}
+		c := sunspec.Config{Endpoint: *target, Unit: uint8(*unit)}.Client()
+		if err := c.Connect(); err != nil {
+			log.Fatalln(err)
+		}
+		err := c.Scan(cancel.New(), defs...)
+		c.Disconnect()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		d = c.Device
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	e := ids.NewEngine(d, func(a ids.Alert) { fmt.Println(a) })
+	e.Allow(allowed...)
+	consume := e.Consume
+	if *physics {
+		ph := anomaly.NewPhysics()
+		consume = func(f ids.Frame) {
+			e.Consume(f)
+			if f.Response {
+				for _, a := range ph.Analyze(d, f.Time) {
+					fmt.Println(a)
+				}
+			}
+		}
+	}
+	if err := ids.ReadPcap(r, uint16(*port), consume); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,122 @@
+// Command sunspec-proxy relays modbus requests to a sunspec device, manipulating the values of points in transit.
+//
+// The proxy scans the device using the given model definitions (json), so the rules reference points by path.
+// Rules are given in the textual representation parsed by sunspec.ParseRule, for example:
+//
+//	sunspec-proxy -listen :502 -target 172.16.238.10:502 -rule "803.ModTmpAvg := -10" model802.json model803.json
+//
+// The rules are applied in the order given. Alternatively they are read from a file, one rule per line,
+// which is reloaded whenever it is modified:
+//
+//	sunspec-proxy -target 172.16.238.10:502 -rules attack.rules model802.json model803.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+var (
+	listen = flag.String("listen", ":502", "endpoint the proxy listens on")
+	target = flag.String("target", "", "endpoint of the device")
+	unit   = flag.Uint("unit", 0, "modbus unit id of the device")
+	file   = flag.String("rules", "", "file of rules, reloaded when modified")
+	rules  ruleList
+)
+
+// ruleList collects the rules given by repeated flags.
+type ruleList []sunspec.Rule
+
+func (l *ruleList) String() string {
+	col := make([]string, len(*l))
+	for i, r := range *l {
+		col[i] = r.String()
+	}
+	return strings.Join(col, "; ")
+}
+
+func (l *ruleList) Set(s string) error {
+	r, err := sunspec.ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, r)
+	return nil
+}
+
+func main() {
+	flag.Var(&rules, "rule", "rule applied to the relayed values, may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: sunspec-proxy [flags] model.json...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *target == "" || *unit > 0xFF || *file != "" && len(rules) > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var defs []sunspec.Definition
+	for _, name := range flag.Args() {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		var def sunspec.ModelDef
+		if err := json.Unmarshal(b, &def); err != nil {
+			log.Fatalln(name+":", err)
+		}
+		defs = append(defs, &def)
+	}
+
+	p := sunspec.NewProxy(sunspec.Config{Endpoint: *listen, Logger: logger{}}, sunspec.Config{Endpoint: *target, Unit: uint8(*unit)})
+	if err := p.SetRules(rules...); err != nil {
+		log.Fatalln(err)
+	}
+	ctx := cancel.New()
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		ctx.Cancel()
+	}()
+	if *file != "" {
+		// the initial rules are loaded before serving, so that no request is relayed without them
+		loaded, err := sunspec.LoadRules(*file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := p.SetRules(loaded...); err != nil {
+			log.Fatalln(err)
+		}
+		go func() {
+			if err := p.Watch(ctx, *file, time.Second); err != nil {
+				log.Fatalln(err)
+			}
+		}()
+	}
+	log.Println("relaying", *listen, "to", *target)
+	if err := p.Serve(ctx, defs...); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// logger writes the manipulations of the proxy to the standard logger.
+type logger struct{}
+
+func (logger) Debug(args ...interface{}) {}
+
+func (logger) Error(args ...interface{}) { log.Println(append([]interface{}{"error:"}, args...)...) }
+
+func (logger) Warn(args ...interface{}) { log.Println(append([]interface{}{"warn:"}, args...)...) }
+
+func (logger) Info(args ...interface{}) { log.Println(append([]interface{}{"info:"}, args...)...) }
@@ -0,0 +1,380 @@
+// Command sunspec-sim serves multiple simulated sunspec devices described by a config file.
+//
+// The config (json) lists the devices of a site. Each device is served on its endpoint under its modbus unit id,
+// using the given model definitions and initial values, and is driven by the attached simulators:
+//
+//	{
+//		"devices": [
+//			{
+//				"name": "storage", "endpoint": ":502", "unit": 1,
+//				"models": ["examples/basic/model802.json", "examples/basic/model803.json"],
+//				"values": {"802.SoCMax": 95},
+//				"simulators": [{"type": "battery", "options": {"Strings": 4}}]
+//			},
+//			{
+//				"name": "pv", "endpoint": ":502", "unit": 2,
+//				"models": ["examples/basic/model701.json", "examples/basic/model704.json"],
+//				"simulators": [{"type": "inverter", "options": {"WRtg": 20000}}]
+//			},
+//			{
+//				"name": "site", "endpoint": ":503",
+//				"models": ["examples/basic/model203.json"],
+//				"simulators": [{"type": "meter", "meters": ["pv", "storage"]}]
+//			}
+//		]
+//	}
+//
+// Devices sharing an endpoint are served by a gateway dispatching the requests by their unit id,
+// a device of its own endpoint answers requests of any unit id.
+// The options of a simulator set the exported fields of the battery, inverter or meter of the sim package,
+// a meter meters the power flows of the devices given by name.
+// Unless a scenario is given, the simulators are stepped in real time:
+//
+//	sunspec-sim site.json
+//	sunspec-sim -scenario day.json -speed 60 site.json
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/TRICERA-energy/sunspec"
+	"github.com/TRICERA-energy/sunspec/sim"
+)
+
+var (
+	interval = flag.Duration("interval", time.Second, "interval of the simulation steps without a scenario")
+	scenario = flag.String("scenario", "", "json scenario file played against the devices")
+	speed    = flag.Float64("speed", 1, "ratio of the simulated to the real time of the scenario, 0 plays as fast as possible")
+	verbose  = flag.Bool("v", false, "log the handled requests")
+)
+
+// config is the site of simulated devices.
+type config struct {
+	Devices []deviceConfig `json:"devices"`
+}
+
+// deviceConfig describes a simulated device.
+type deviceConfig struct {
+	// Name references the device by scenarios and meters.
+	Name string `json:"name"`
+	// Endpoint is the address the device is served on.
+	Endpoint string `json:"endpoint"`
+	// Unit is the modbus unit id of the device.
+	Unit uint8 `json:"unit"`
+	// Models are the files of the served model definitions.
+	Models []string `json:"models"`
+	// Values are the initial values of the points by their path, numbers or strings.
+	Values map[string]interface{} `json:"values"`
+	// Simulators are stepped in the given order.
+	Simulators []simConfig `json:"simulators"`
+}
+
+// simConfig describes a simulator attached to a device.
+type simConfig struct {
+	// Type is one of "battery", "inverter" or "meter".
+	Type string `json:"type"`
+	// Options set the exported fields of the simulator.
+	Options json.RawMessage `json:"options"`
+	// Meters are the names of the devices metered by a meter.
+	Meters []string `json:"meters"`
+}
+
+// device is a served device with its simulators.
+type device struct {
+	deviceConfig
+	defs   []sunspec.Definition
+	server *sunspec.Server
+	sims   []sim.Simulator
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: sunspec-sim [flags] config.json\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	b, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var cfg config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		log.Fatalln(flag.Arg(0)+":", err)
+	}
+	devices, err := load(cfg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	ctx := cancel.New()
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		ctx.Cancel()
+	}()
+
+	var l sunspec.Logger = logger{debug: *verbose}
+	errs := make(chan error, len(devices)+1)
+	serve(ctx, l, devices, errs)
+	for _, d := range devices {
+		if err := initialize(ctx, d, errs); err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("serving", d.Name, "on", d.Endpoint, "unit", d.Unit)
+	}
+	go func() { errs <- simulate(ctx, l, devices) }()
+
+	select {
+	case <-ctx.Done():
+	case err := <-errs:
+		ctx.Cancel()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// load reads the model definitions of the devices and instantiates their simulators.
+func load(cfg config) ([]*device, error) {
+	var devices []*device
+	byName := make(map[string]*device)
+	for _, dc := range cfg.Devices {
+		if _, ok := byName[dc.Name]; ok {
+			return nil, fmt.Errorf("duplicate device %q", dc.Name)
+		}
+		d := &device{deviceConfig: dc}
+		for _, name := range dc.Models {
+			b, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			var def sunspec.ModelDef
+			if err := json.Unmarshal(b, &def); err != nil {
+				return nil, fmt.Errorf("%v: %w", name, err)
+			}
+			d.defs = append(d.defs, &def)
+		}
+		devices, byName[dc.Name] = append(devices, d), d
+	}
+	// the simulators are instantiated once all devices are known, as meters may reference any of them
+	for _, d := range devices {
+		for _, sc := range d.Simulators {
+			s, err := newSimulator(sc, byName)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", d.Name, err)
+			}
+			if err := s.Prepare(d.defs...); err != nil {
+				return nil, fmt.Errorf("%v: %w", d.Name, err)
+			}
+			d.sims = append(d.sims, s)
+		}
+	}
+	return devices, nil
+}
+
+// newSimulator instantiates the configured simulator.
+func newSimulator(sc simConfig, byName map[string]*device) (s sim.Simulator, err error) {
+	switch sc.Type {
+	case "battery":
+		s = sim.NewBattery()
+	case "inverter":
+		s = sim.NewInverter()
+	case "meter":
+		m := sim.NewMeter()
+		for _, name := range sc.Meters {
+			d, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("meter references unknown device %q", name)
+			}
+			m.Flows = append(m.Flows, flow(d))
+		}
+		s = m
+	default:
+		return nil, fmt.Errorf("unknown simulator %q", sc.Type)
+	}
+	if len(sc.Options) > 0 {
+		if err := json.Unmarshal(sc.Options, s); err != nil {
+			return nil, fmt.Errorf("invalid options of the %v: %w", sc.Type, err)
+		}
+	}
+	return s, nil
+}
+
+// flow returns the summed power flows of the device´s simulators.
+// The device´s simulators may be instantiated after the meter, so they are looked up on every call.
+func flow(d *device) sim.Flow {
+	return func() (w, vars float64) {
+		for _, s := range d.sims {
+			switch s := s.(type) {
+			case *sim.Battery:
+				w += s.Power()
+			case *sim.Inverter:
+				p, q := s.Power()
+				w, vars = w+p, vars+q
+			}
+		}
+		return w, vars
+	}
+}
+
+// serve starts serving all devices, passing their errors to errs.
+// Devices sharing an endpoint are served by a gateway.
+func serve(ctx cancel.Context, l sunspec.Logger, devices []*device, errs chan<- error) {
+	shared := make(map[string]int)
+	for _, d := range devices {
+		shared[d.Endpoint]++
+	}
+	gateways := make(map[string]*sunspec.Gateway)
+	for _, d := range devices {
+		cfg := sunspec.Config{Endpoint: d.Endpoint, Unit: d.Unit, Logger: l}
+		if shared[d.Endpoint] == 1 {
+			d.server = cfg.Server()
+		} else {
+			gw, ok := gateways[d.Endpoint]
+			if !ok {
+				gw = sunspec.NewGateway(cfg)
+				gateways[d.Endpoint] = gw
+				go func() { errs <- gw.Serve(ctx) }()
+			}
+			d.server = gw.Server(cfg)
+		}
+		for _, s := range d.sims {
+			s.Attach(d.server)
+		}
+		go func(d *device) { errs <- d.server.Serve(ctx, handler, d.defs...) }(d)
+	}
+}
+
+// handler ingests the written values.
+func handler(ctx cancel.Context, req sunspec.Request) error {
+	defer req.Flush()
+	return req.Ingest()
+}
+
+// initialize sets the initial values of the device once it is serving.
+// It gives up on the first error of the served devices, as the device may never be serving.
+func initialize(ctx cancel.Context, d *device, errs <-chan error) error {
+	for {
+		err := d.server.Update(func(dev sunspec.Device) error {
+			for path, v := range d.Values {
+				pts, err := dev.Query(path)
+				if err != nil {
+					return err
+				}
+				for _, p := range pts {
+					if err := assign(p, v); err != nil {
+						return fmt.Errorf("%v: %w", path, err)
+					}
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, sunspec.ErrNotServing) {
+			return fmt.Errorf("%v: %w", d.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if err == nil {
+				err = errors.New("stopped serving")
+			}
+			return err
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// assign sets the point to the numeric (scaled) or string value.
+func assign(p sunspec.Point, v interface{}) error {
+	if s, ok := v.(string); ok {
+		if p, ok := p.(sunspec.String); ok {
+			return p.Set(s)
+		}
+		return fmt.Errorf("can not set a numeric point to %q", s)
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return fmt.Errorf("invalid value %v", v)
+	}
+	switch p := p.(type) {
+	case interface{ SetValue(v float64) error }:
+		return p.SetValue(f)
+	case sunspec.Enum16:
+		return p.Set(uint16(f))
+	case sunspec.Enum32:
+		return p.Set(uint32(f))
+	case sunspec.Bitfield16:
+		return p.Set(uint16(f))
+	case sunspec.Bitfield32:
+		return p.Set(uint32(f))
+	case sunspec.Bitfield64:
+		return p.Set(uint64(f))
+	}
+	return fmt.Errorf("can not set the point to %v", f)
+}
+
+// simulate steps the simulators of all devices, either by the scenario or in real time.
+func simulate(ctx cancel.Context, l sunspec.Logger, devices []*device) error {
+	if *scenario != "" {
+		sc, err := sim.LoadScenario(*scenario)
+		if err != nil {
+			return err
+		}
+		e := sim.NewEngine(sc, *speed)
+		e.Logger = l
+		for _, d := range devices {
+			e.Add(d.Name, d.server, d.sims...)
+		}
+		err = e.Run(ctx)
+		if err == nil {
+			log.Println("scenario finished")
+			<-ctx.Done()
+		}
+		return err
+	}
+	errs := make(chan error, len(devices))
+	for _, d := range devices {
+		go func(d *device) { errs <- sim.Run(ctx, d.server, *interval, d.sims...) }(d)
+	}
+	for range devices {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logger writes the messages of the servers to the standard logger.
+type logger struct {
+	debug bool
+}
+
+func (l logger) Debug(args ...interface{}) {
+	if l.debug {
+		log.Println(append([]interface{}{"debug:"}, args...)...)
+	}
+}
+
+func (l logger) Error(args ...interface{}) { log.Println(append([]interface{}{"error:"}, args...)...) }
+
+func (l logger) Warn(args ...interface{}) { log.Println(append([]interface{}{"warn:"}, args...)...) }
+
+func (l logger) Info(args ...interface{}) { log.Println(append([]interface{}{"info:"}, args...)...) }
@@ -1,5 +1,7 @@
 package sunspec
 
+import "sync"
+
 // Config is the configuration for a client or server.
 type Config struct {
 	// Endpoint specifics the sunspec host and is mandatory.
@@ -8,6 +10,16 @@ type Config struct {
 	Endpoint string
 	// Logger can be optionally defined.
 	Logger Logger
+	// Limits optionally constrain the values of writable points,
+	// complementing the limits declared by the model definitions.
+	Limits Limits
+	// Store optionally persists the values served by a server.
+	// It is ignored by clients.
+	Store Store
+	// Unit is the modbus unit identifier of the device.
+	// Clients address it by their requests, servers of a gateway serve the requests addressed to it.
+	// Servers of their own endpoint serve the requests of any unit.
+	Unit uint8
 }
 
 // logger returns the optional logger.
@@ -21,10 +33,11 @@ func (o *Config) logger() Logger {
 
 // Client instantiates a new client from the given configuration.
 func (o Config) Client() *Client {
-	return &Client{client: newModbusClient(o.Endpoint, o.logger()), logger: o.logger()}
+	values := new(sync.RWMutex)
+	return &Client{client: newModbusClient(o.Endpoint, o.Unit, o.logger(), values), logger: o.logger(), limits: o.Limits, values: values}
 }
 
 // Server instantiates a new server from the given configuration.
 func (o Config) Server() *Server {
-	return &Server{server: newModbusServer(o.Endpoint, o.logger()), logger: o.logger()}
+	return &Server{server: newModbusServer(o.Endpoint, o.logger()), logger: o.logger(), limits: o.Limits, store: o.Store}
 }
@@ -0,0 +1,108 @@
+package sunspec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Limit constrains the scaled value of a writable point.
+// The bounds are given in the point´s engineering unit, omitted bounds are not checked.
+type Limit struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// Limits assigns limits to the points referenced by their path, e.g. "704.WMaxLimPct".
+// It serves as companion to the model definitions, which often lack any limits.
+type Limits map[string]Limit
+
+// LoadLimits reads the limits from a json file, for instance:
+//
+//	{"704.WMaxLimPct": {"min": 0, "max": 100}}
+func LoadLimits(name string) (Limits, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var l Limits
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, fmt.Errorf("sunspec: invalid limits file %q: %w", name, err)
+	}
+	return l, nil
+}
+
+// apply assigns the limits to all referenced points of the device.
+// Limits given by the model definition are overwritten.
+func (l Limits) apply(d Device) error {
+	for path, lim := range l {
+		pts, err := d.Query(path)
+		if err != nil {
+			return err
+		}
+		for _, p := range pts {
+			p.constrain(lim)
+		}
+	}
+	return nil
+}
+
+// ConstraintError is returned for point values violating the point´s constraints.
+type ConstraintError struct {
+	// Point is the violating point.
+	Point Point
+	// Value is the rejected value.
+	Value interface{}
+	// Reason describes the violated constraint.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("sunspec: value %v of point %q %v", e.Value, e.Point.Name(), e.Reason)
+}
+
+// Check verifies that the point´s current value satisfies its constraints.
+// Enumerated values must be a declared symbol and numeric values must be within the point´s limit.
+// Unimplemented values are always accepted.
+func Check(p Point) error {
+	if !p.Valid() {
+		return nil
+	}
+	var symbols Symbols
+	var v uint32
+	switch p := p.(type) {
+	case *tEnum16:
+		symbols, v = p.symbols, uint32(p.Get())
+	case *tEnum32:
+		symbols, v = p.symbols, p.Get()
+	}
+	if _, ok := symbols[v]; len(symbols) > 0 && !ok {
+		return &ConstraintError{Point: p, Value: v, Reason: "is not an enumerated symbol"}
+	}
+	lim := p.Limit()
+	if lim.Min == nil && lim.Max == nil {
+		return nil
+	}
+	f, err := Scaled(p)
+	if err != nil {
+		return nil
+	}
+	if lim.Min != nil && f < *lim.Min {
+		return &ConstraintError{Point: p, Value: f, Reason: fmt.Sprintf("is below the minimum of %v", *lim.Min)}
+	}
+	if lim.Max != nil && f > *lim.Max {
+		return &ConstraintError{Point: p, Value: f, Reason: fmt.Sprintf("is above the maximum of %v", *lim.Max)}
+	}
+	return nil
+}
+
+// check verifies the constraints of all points, returning the first violation.
+func (pts Points) check() error {
+	for _, p := range pts {
+		if err := Check(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
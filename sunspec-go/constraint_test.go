@@ -0,0 +1,48 @@
+package sunspec_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+func TestCheck(t *testing.T) {
+	min, max := 0.0, 100.0
+	symbols := []sunspec.SymbolDef{{Name: "ON", Value: 1}, {Name: "OFF", Value: 2}}
+	testCases := []struct {
+		def   sunspec.PointDef
+		value float64
+		fail  bool
+	}{
+		{def: sunspec.PointDef{Type: "uint16", ScaleFactor: int16(-1), Max: &max}, value: 100},
+		{def: sunspec.PointDef{Type: "uint16", ScaleFactor: int16(-1), Max: &max}, value: 100.1, fail: true},
+		{def: sunspec.PointDef{Type: "int16", Min: &min}, value: -1, fail: true},
+		{def: sunspec.PointDef{Type: "int16"}, value: -1},
+		{def: sunspec.PointDef{Type: "enum16", Symbols: symbols}, value: 2},
+		{def: sunspec.PointDef{Type: "enum16", Symbols: symbols}, value: 3, fail: true},
+		{def: sunspec.PointDef{Type: "enum16", Symbols: symbols}, value: 0xFFFF},
+		{def: sunspec.PointDef{Type: "enum16"}, value: 3},
+	}
+
+	for _, tc := range testCases {
+		tc.def.Name = "P"
+		p := tc.def.Instance(0, nil)
+		switch p := p.(type) {
+		case sunspec.Enum16:
+			p.Set(uint16(tc.value))
+		case interface{ SetValue(v float64) error }:
+			if err := p.SetValue(tc.value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := sunspec.Check(p)
+		var ce *sunspec.ConstraintError
+		switch {
+		case tc.fail && !errors.As(err, &ce):
+			t.Fatalf("%v accepted value %v; want constraint error, got %v", tc.def.Type, tc.value, err)
+		case !tc.fail && err != nil:
+			t.Fatalf("%v refused value %v: %v", tc.def.Type, tc.value, err)
+		}
+	}
+}
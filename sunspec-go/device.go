@@ -9,6 +9,10 @@ type Device interface {
 	// Models returns all models from the device.
 	// If ids are omitted all models are returned.
 	Models(ids ...uint16) Models
+	// Query returns all points referenced by the path, e.g. "803.string[*].StrSoC".
+	Query(path string) (Points, error)
+	// Value returns the scaled value of the single point referenced by the path.
+	Value(path string) (float64, error)
 }
 
 // collect retrieves all the distinct points in a given address range.
@@ -22,7 +26,7 @@ func collect(d Device, idx ...Index) (Points, error) {
 			}
 			if err := iterate(m, func(g Group) error {
 				switch {
-				case idx.Address() > ceil(g.Points().index()):
+				case !intersect(idx, g.Points().index()):
 				case idx.Address() <= g.Address() && ceil(idx) >= ceil(g.Points().index()):
 					pts = append(pts, g.Points()...)
 				case g.Atomic():
@@ -48,3 +52,40 @@ func collect(d Device, idx ...Index) (Points, error) {
 	}
 	return pts, nil
 }
+
+// Decode sets the points of the device fully contained by the register values, which start at the address,
+// e.g. to follow the values of recorded or relayed traffic. The decoded points are returned.
+// Points failing to decode are marked as outdated and the first error is returned.
+func Decode(d Device, address uint16, values []byte) (pts Points, err error) {
+	idx := index{address: address, quantity: uint16(len(values) / 2)}
+	for _, p := range contained(d, idx) {
+		if e := p.decode(values[2*int(p.Address()-address):]); e != nil {
+			p.track(e, false)
+			if err == nil {
+				err = e
+			}
+			continue
+		}
+		p.track(nil, p.Valid())
+		pts = append(pts, p)
+	}
+	return pts, err
+}
+
+// contained returns the points of the device fully contained by the index.
+func contained(d Device, idx Index) (pts Points) {
+	for _, m := range d.Models() {
+		if !intersect(idx, m) {
+			continue
+		}
+		iterate(m, func(g Group) error {
+			for _, pt := range g.Points() {
+				if pt.Address() >= idx.Address() && ceil(pt) <= ceil(idx) {
+					pts = append(pts, pt)
+				}
+			}
+			return nil
+		})
+	}
+	return pts
+}
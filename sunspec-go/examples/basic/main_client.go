@@ -62,75 +62,84 @@ func main() {
 }
 
 // Client instantiates a new sunspec client.
-// First scanning then polling the server every second.
+// First scanning then polling the server every five seconds.
+// The web page is only rendered if one of the displayed values changes.
 func Client() {
 
 	fmt.Printf("Modbus Client Start\n")
 
-	// continuously read the entire model from the server
-	for {
+	// create a new client requesting data from the server
+	c := (sunspec.Config{Endpoint: endpoint}).Client()
 
-		// create a new client requesting data from the server
-		c := (sunspec.Config{Endpoint: endpoint}).Client()
+	// attempt to connect to the server
+	if err := c.Connect(); err != nil {
+		fmt.Printf("Client Stop\n")
+		logger.Fatalln(err)
+	}
+	defer c.Disconnect()
 
-		// attempt to connect to the server
-		if err := c.Connect(); err != nil {
-			fmt.Printf("Client Stop\n")
-			logger.Fatalln(err)
-		}else{
-			defer c.Disconnect()
+	// scan the endpoint retrieving all models
+	if err := c.Scan(ctx, defs...); err != nil {
+		logger.Fatalln("Read error:", err)
+	}
 
-			// scan the endpoint retrieving all models
-			if err := c.Scan(ctx, defs...); err != nil {
-				logger.Fatalln("Read error:", err)
-			}
+	// get the original content of the web page
+	content, err := ioutil.ReadFile("examples/basic/static/unittest_battery.html") // the file is inside the local directory
+	if err != nil {
+		logger.Fatalln(err)
+	}
 
-			// get the original content of the web page
-			content, err := ioutil.ReadFile("examples/basic/static/unittest_battery.html")     // the file is inside the local directory
-			if err != nil {
-				logger.Fatalln(err)
-			}
-			content_present := string(content)
+	// current values of the variables displayed on the web page
+	var mu sync.Mutex
+	values := map[string]interface{}{}
 
-			for _, m := range c.Models() {
-				if _, err := c.Read(ctx, m); err != nil {
-					logger.Fatalln(err)
-				}
-				// fmt.Printf("Read Model %v:\n", m.ID())
+	// variables displayed on the web page
+	paths := map[string]string{
+		"MYVOLT": "803.StrVAvg",   // the battery voltage
+		"MYCURR": "803.StrAAvg",   // the battery amperage
+		"MYTEMP": "803.ModTmpAvg", // the battery temperature
+	}
 
-				for _, p := range m.Points() {
-					// fmt.Printf("\t%v\t- current value: %v\n", p.Name(), p)
-					// replace variables received from the server
-					switch p.Name() {
-					case "StrVAvg":
-						// Replace the battery voltage
-						content_present = strings.Replace(content_present, "MYVOLT", fmt.Sprintf("%v",p), 1)
-  					// fmt.Printf("Content: %v", content_present)
-					case "StrAAvg":
-						// Replace the battery amperage
-						content_present = strings.Replace(content_present, "MYCURR", fmt.Sprintf("%v",p), 1)
-					  // fmt.Printf("Content: %v", content_present)
-					case "ModTmpAvg":
-						// Replace the battery amperage
-						content_present = strings.Replace(content_present, "MYTEMP", fmt.Sprintf("%v",p), 1)
-						// fmt.Printf("Content: %v", content_present)
-					}
-				}
+	// replace variables received from the server, whenever they change
+	for placeholder, path := range paths {
+		placeholder := placeholder
+		if _, err := c.Subscribe(path, sunspec.Subscription{}, func(e sunspec.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			values[placeholder] = e.New
+			content_present := string(content)
+			for placeholder, v := range values {
+				content_present = strings.Replace(content_present, placeholder, fmt.Sprintf("%v", v), 1)
 			}
-
 			// write the web page to serve
 			if err := os.WriteFile("examples/basic/static/index.html", []byte(content_present), 0666); err != nil {
 				logger.Fatalln(err)
 			}
+		}); err != nil {
+			logger.Println(err)
 		}
-		time.Sleep(5 * time.Second)
 	}
 
+	// continuously read the entire model from the server
+	s := c.Scheduler(func(p sunspec.Poll) {
+		if p.Err != nil {
+			logger.Println("Read error:", p.Err)
+			// the displayed values are outdated
+			for _, path := range paths {
+				if pts, err := c.Query(path); err == nil && pts[0].Quality() != sunspec.QualityGood {
+					logger.Println(path, "is", pts[0].Quality(), "since", pts[0].Updated())
+				}
+			}
+		}
+	})
+	for _, m := range c.Models() {
+		s.Register(5*time.Second, m)
+	}
+	logger.Println(s.Run(ctx))
+
 	fmt.Printf("Client Stop\n")
 }
 
-
-
 var models = [][]byte{
 	[]byte(`{
     "group": {
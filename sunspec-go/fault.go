@@ -0,0 +1,311 @@
+package sunspec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/GoAethereal/modbus"
+)
+
+// FaultKind defines how a fault manipulates the server´s response.
+type FaultKind int
+
+const (
+	// FaultException responds with the fault´s modbus exception instead of handling the request.
+	FaultException FaultKind = iota
+	// FaultDelay delays the response by the fault´s delay.
+	FaultDelay
+	// FaultDrop never responds to the request.
+	FaultDrop
+	// FaultFlip inverts the bits of the fault´s mask in every affected register of read responses.
+	FaultFlip
+	// FaultNotImplemented responds the not implemented value of every affected point in read responses.
+	FaultNotImplemented
+	// FaultReplace responds the fault´s value for every affected register in read responses,
+	// e.g. to change the id or length of a model mid-session.
+	FaultReplace
+)
+
+// String returns a human readable representation of the kind.
+func (k FaultKind) String() string {
+	switch k {
+	case FaultException:
+		return "exception"
+	case FaultDelay:
+		return "delay"
+	case FaultDrop:
+		return "drop"
+	case FaultFlip:
+		return "flip"
+	case FaultNotImplemented:
+		return "not implemented"
+	case FaultReplace:
+		return "replace"
+	}
+	return "unknown"
+}
+
+// Fault manipulates the server´s responses to requests intersecting the affected registers.
+// The registers are given by the path of the points, e.g. "802.SoC", or by their address range.
+type Fault struct {
+	// Name identifies the fault, e.g. for toggling it at runtime.
+	Name string
+	// Kind is the manipulation of the response.
+	Kind FaultKind
+	// Path references the affected points. If empty, the address range is used instead.
+	Path string
+	// Address and Quantity give the affected address range. A quantity of zero affects all registers.
+	Address, Quantity uint16
+	// Reads and Writes restrict the fault to read or write requests. If neither is set, both are affected.
+	Reads, Writes bool
+	// Exception is responded by FaultException, a slave device failure if not given.
+	Exception modbus.Exception
+	// Delay is the delay of FaultDelay.
+	Delay time.Duration
+	// Mask are the bits inverted by FaultFlip.
+	Mask uint16
+	// Value is the register value responded by FaultReplace.
+	Value uint16
+	// After and For schedule the fault relative to the start of serving.
+	// The fault is active after the given duration for the given duration, or indefinitely if For is zero.
+	After, For time.Duration
+	// Disabled deactivates the fault regardless of its schedule.
+	Disabled bool
+}
+
+// faults are the faults injected into the responses of a server.
+type faults struct {
+	mu      sync.Mutex
+	device  Device
+	started time.Time
+	col     []*fault
+}
+
+// fault is an injected fault with its resolved registers.
+type fault struct {
+	Fault
+	idx []Index
+}
+
+// InjectFault adds the fault to the server, replacing any previous fault of the same name.
+// Faults may be injected before and while serving.
+func (s *Server) InjectFault(f Fault) error {
+	s.faults.mu.Lock()
+	defer s.faults.mu.Unlock()
+	// an exception of zero would be responded as regular response, acknowledging writes that are not applied
+	if f.Kind == FaultException && f.Exception == 0 {
+		f.Exception = modbus.SlaveDeviceFailure
+	}
+	x := &fault{Fault: f}
+	if d := s.faults.device; d != nil {
+		if err := x.resolve(d); err != nil {
+			return err
+		}
+	}
+	for i, y := range s.faults.col {
+		if y.Name == f.Name {
+			s.faults.col[i] = x
+			s.logger.Info("replaced fault", f.Name)
+			return nil
+		}
+	}
+	s.faults.col = append(s.faults.col, x)
+	s.logger.Info("injected fault", f.Name)
+	return nil
+}
+
+// ToggleFault enables or disables the named fault.
+func (s *Server) ToggleFault(name string, enabled bool) error {
+	s.faults.mu.Lock()
+	defer s.faults.mu.Unlock()
+	for _, f := range s.faults.col {
+		if f.Name == name {
+			f.Disabled = !enabled
+			s.logger.Info("toggled fault", name, "enabled:", enabled)
+			return nil
+		}
+	}
+	return fmt.Errorf("sunspec: no fault named %q", name)
+}
+
+// RemoveFault removes the named fault from the server.
+func (s *Server) RemoveFault(name string) {
+	s.faults.mu.Lock()
+	defer s.faults.mu.Unlock()
+	for i, f := range s.faults.col {
+		if f.Name == name {
+			s.faults.col = append(s.faults.col[:i], s.faults.col[i+1:]...)
+			s.logger.Info("removed fault", name)
+			return
+		}
+	}
+}
+
+// Faults returns all faults injected into the server.
+func (s *Server) Faults() []Fault {
+	s.faults.mu.Lock()
+	defer s.faults.mu.Unlock()
+	col := make([]Fault, len(s.faults.col))
+	for i, f := range s.faults.col {
+		col[i] = f.Fault
+	}
+	return col
+}
+
+// start resolves the registers of all faults and starts their schedule.
+func (fs *faults) start(d Device) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.device, fs.started = d, time.Now()
+	for _, f := range fs.col {
+		if err := f.resolve(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve determines the registers affected by the fault.
+func (f *fault) resolve(d Device) error {
+	switch {
+	case f.Path != "":
+		pts, err := d.Query(f.Path)
+		if err != nil {
+			return fmt.Errorf("sunspec: invalid fault %q: %w", f.Name, err)
+		}
+		f.idx = nil
+		for _, p := range pts {
+			f.idx = append(f.idx, p)
+		}
+	case f.Quantity == 0:
+		f.idx = []Index{index{address: 0, quantity: math.MaxUint16}}
+	default:
+		f.idx = []Index{index{address: f.Address, quantity: f.Quantity}}
+	}
+	return nil
+}
+
+// active returns the faults applying to the request at the current time.
+func (fs *faults) active(write bool, idx Index) (col []*fault) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	elapsed := time.Since(fs.started)
+	for _, f := range fs.col {
+		switch {
+		case f.Disabled,
+			elapsed < f.After,
+			f.For > 0 && elapsed >= f.After+f.For,
+			write && f.Reads && !f.Writes,
+			!write && f.Writes && !f.Reads:
+			continue
+		}
+		for _, i := range f.idx {
+			if intersect(i, idx) {
+				col = append(col, f)
+				break
+			}
+		}
+	}
+	return col
+}
+
+// before applies the faults preventing or delaying the handling of a request.
+// If the request must not be handled, ok is false and the exception is responded.
+// Dropped requests are held until the context is canceled, so that they are never responded in time.
+func (fs *faults) before(ctx cancel.Context, l Logger, col []*fault, idx Index) (ex modbus.Exception, ok bool) {
+	for _, f := range col {
+		switch f.Kind {
+		case FaultDelay:
+			l.Info("fault", f.Name, "delays request at address", idx.Address(), "by", f.Delay)
+			select {
+			case <-ctx.Done():
+				return modbus.SlaveDeviceFailure, false
+			case <-time.After(f.Delay):
+			}
+		case FaultDrop:
+			l.Info("fault", f.Name, "drops request at address", idx.Address())
+			<-ctx.Done()
+			return modbus.SlaveDeviceFailure, false
+		case FaultException:
+			l.Info("fault", f.Name, "responds exception", f.Exception, "to request at address", idx.Address())
+			return f.Exception, false
+		}
+	}
+	return 0, true
+}
+
+// after applies the faults manipulating the response res of a read request for the given points.
+func (fs *faults) after(l Logger, col []*fault, pts Points, res []byte) {
+	if len(pts) == 0 {
+		return
+	}
+	base := pts[0].Address()
+	for _, f := range col {
+		if f.Kind != FaultFlip && f.Kind != FaultNotImplemented && f.Kind != FaultReplace {
+			continue
+		}
+		l.Info("fault", f.Name, "manipulates", f.Kind, "response at address", base)
+		for _, i := range f.idx {
+			for _, p := range pts {
+				if !intersect(i, p) {
+					continue
+				}
+				if f.Kind == FaultNotImplemented {
+					off := int(p.Address() - base)
+					sentinel(p, res[2*off:2*(off+int(p.Quantity()))])
+					continue
+				}
+				// only the registers of the point within the fault´s range are affected
+				for a := maxUint16(i.Address(), p.Address()); a < minUint16(ceil(i), ceil(p)); a++ {
+					r := res[2*int(a-base):]
+					if f.Kind == FaultFlip {
+						binary.BigEndian.PutUint16(r, binary.BigEndian.Uint16(r)^f.Mask)
+					} else {
+						binary.BigEndian.PutUint16(r, f.Value)
+					}
+				}
+			}
+		}
+	}
+}
+
+// sentinel puts the value signaling an unimplemented point of the given type into the buffer.
+func sentinel(p Point, buf []byte) {
+	fill := func(b byte) {
+		for i := range buf {
+			buf[i] = b
+		}
+	}
+	// the type interfaces overlap (e.g. every point satisfies Pad), so the implementations are matched instead
+	switch p.(type) {
+	case *tInt16, *tInt32, *tInt64, *tSunssf, *tPad:
+		fill(0)
+		buf[0] = 0x80
+	case *tFloat32:
+		binary.BigEndian.PutUint32(buf, 0x7FC00000)
+	case *tFloat64:
+		binary.BigEndian.PutUint64(buf, 0x7FF8000000000000)
+	case *tAcc16, *tAcc32, *tAcc64, *tCount, *tString, *tIpaddr, *tIpv6addr:
+		fill(0)
+	default:
+		fill(0xFF)
+	}
+}
+
+func maxUint16(a, b uint16) uint16 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minUint16(a, b uint16) uint16 {
+	if a < b {
+		return a
+	}
+	return b
+}
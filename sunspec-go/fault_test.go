@@ -0,0 +1,125 @@
+package sunspec_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+func TestFaults(t *testing.T) {
+	def := definition(t, pair)
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	endpoint, s := serve(t, ctx, sunspec.Config{}, def)
+
+	faults := []sunspec.Fault{
+		{Name: "exception", Kind: sunspec.FaultException, Path: "64001.sync.A", Writes: true, Exception: modbus.SlaveDeviceBusy},
+		{Name: "flip", Kind: sunspec.FaultFlip, Path: "64001.sync.B", Mask: 0x0101, Disabled: true},
+		{Name: "delay", Kind: sunspec.FaultDelay, Path: "64001.sync.A", Reads: true, Delay: 100 * time.Millisecond, Disabled: true},
+		{Name: "length", Kind: sunspec.FaultReplace, Path: "64001.L", Value: 7, After: time.Hour},
+	}
+	for _, f := range faults {
+		if err := s.InjectFault(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := sunspec.Config{Endpoint: endpoint}.Client()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if err := c.Scan(ctx, def); err != nil {
+		t.Fatal(err)
+	}
+	m, sync := c.Model(64001), c.Model(64001).Group("sync")
+	a, b := sync.Point("A").(sunspec.Uint16), sync.Point("B").(sunspec.Uint16)
+
+	// writes of A are refused by the exception, reads are not affected
+	a.Set(1)
+	if _, err := c.Write(ctx, sync); !errors.Is(err, modbus.SlaveDeviceBusy) {
+		t.Errorf("expected exception %v, got %v", modbus.SlaveDeviceBusy, err)
+	}
+	if _, err := c.Read(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Length().Get() == 7 {
+		t.Error("scheduled fault was active before its time")
+	}
+
+	// enabled at runtime, the bits of B are flipped
+	if err := s.Update(func(d sunspec.Device) error { return set(d, 0x0010) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ToggleFault("flip", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Read(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if a.Get() != 0x0010 || b.Get() != 0x0111 {
+		t.Errorf("expected values %#x and %#x, got %#x and %#x", 0x0010, 0x0111, a.Get(), b.Get())
+	}
+
+	// delayed reads
+	s.RemoveFault("flip")
+	s.ToggleFault("delay", true)
+	start := time.Now()
+	if _, err := c.Read(ctx, sync); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("expected a delay of at least 100ms, got %v", d)
+	}
+	if _, err := c.Read(ctx, m.Length()); err != nil {
+		t.Fatal(err)
+	}
+	if b.Get() != 0x0010 {
+		t.Errorf("expected the removed fault to be inactive, got %#x", b.Get())
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("expected reads of other points not to be delayed, got %v", d)
+	}
+
+	// the sentinel of not implemented values
+	if err := s.InjectFault(sunspec.Fault{Name: "nan", Kind: sunspec.FaultNotImplemented, Path: "64001.sync.B"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Read(ctx, sync); err != nil {
+		t.Fatal(err)
+	}
+	if b.Valid() {
+		t.Errorf("expected B to be not implemented, got %#x", b.Get())
+	}
+	if len(s.Faults()) != 4 {
+		t.Errorf("expected 4 faults, got %v", len(s.Faults()))
+	}
+	if err := s.ToggleFault("unknown", true); err == nil {
+		t.Error("expected an error toggling an unknown fault")
+	}
+
+	// an exception fault without exception responds a slave device failure
+	if err := s.InjectFault(sunspec.Fault{Name: "exception", Kind: sunspec.FaultException, Path: "64001.sync.*"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Read(ctx, sync); !errors.Is(err, modbus.SlaveDeviceFailure) {
+		t.Errorf("expected exception %v, got %v", modbus.SlaveDeviceFailure, err)
+	}
+	a.Set(2)
+	if _, err := c.Write(ctx, sync); !errors.Is(err, modbus.SlaveDeviceFailure) {
+		t.Errorf("expected exception %v, got %v", modbus.SlaveDeviceFailure, err)
+	}
+	s.RemoveFault("exception")
+	if err := s.View(func(d sunspec.Device) error {
+		if v := d.Model(64001).Group("sync").Point("A").(sunspec.Uint16).Get(); v == 2 {
+			t.Error("expected the refused write not to be applied")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
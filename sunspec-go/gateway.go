@@ -0,0 +1,98 @@
+package sunspec
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/GoAethereal/modbus"
+)
+
+// Gateway serves multiple servers on a single endpoint, each addressed by its modbus unit identifier.
+// Requests for units not being served are answered by the exception GatewayTargetDeviceFailedToRespond.
+//
+//	gw := sunspec.NewGateway(sunspec.Config{Endpoint: ":502"})
+//	battery := gw.Server(sunspec.Config{Unit: 1})
+//	inverter := gw.Server(sunspec.Config{Unit: 2})
+//	go battery.Serve(ctx, handler, batteryDefs...)
+//	go inverter.Serve(ctx, handler, inverterDefs...)
+//	gw.Serve(ctx)
+type Gateway struct {
+	mb    *modbus.Server
+	units *dispatcher
+}
+
+// dispatcher dispatches the requests to the handlers of their unit.
+type dispatcher struct {
+	mu       sync.RWMutex
+	logger   Logger
+	handlers map[uint8]modbus.Handler
+}
+
+var _ modbus.UnitHandler = (*dispatcher)(nil)
+
+// NewGateway instantiates a new gateway listening on the configured endpoint.
+func NewGateway(o Config) *Gateway {
+	return &Gateway{
+		mb: (modbus.Config{
+			Mode:     "tcp",
+			Kind:     "tcp",
+			Endpoint: o.Endpoint,
+		}).Server(),
+		units: &dispatcher{logger: o.logger(), handlers: make(map[uint8]modbus.Handler)},
+	}
+}
+
+// Server instantiates a new server of the gateway, serving the requests addressed to the configured unit.
+// The endpoint of the configuration is ignored.
+func (gw *Gateway) Server(o Config) *Server {
+	return &Server{server: &unitServer{units: gw.units, unit: o.Unit, logger: o.logger()}, logger: o.logger(), limits: o.Limits, store: o.Store}
+}
+
+// Serve starts listening for requests and dispatches them to the servers of the gateway until the context is canceled.
+// Servers may start and stop serving at any time.
+func (gw *Gateway) Serve(ctx cancel.Context) error {
+	return gw.mb.Serve(ctx, gw.units)
+}
+
+// Handle dispatches the request to the unit 0.
+func (u *dispatcher) Handle(ctx cancel.Context, code byte, req []byte) (res []byte, ex modbus.Exception) {
+	return u.HandleUnit(ctx, 0, code, req)
+}
+
+// HandleUnit dispatches the request to the handler of the unit.
+func (u *dispatcher) HandleUnit(ctx cancel.Context, unit, code byte, req []byte) (res []byte, ex modbus.Exception) {
+	u.mu.RLock()
+	h, ok := u.handlers[unit]
+	u.mu.RUnlock()
+	if !ok {
+		u.logger.Debug("received modbus request for unit", unit, "not served by the gateway")
+		return nil, modbus.GatewayTargetDeviceFailedToRespond
+	}
+	return h.Handle(ctx, code, req)
+}
+
+var _ server = (*unitServer)(nil)
+
+// unitServer serves a device as unit of a gateway.
+type unitServer struct {
+	units  *dispatcher
+	unit   uint8
+	logger Logger
+}
+
+func (s *unitServer) serve(ctx cancel.Context, d Device, g *guard, f *faults, intercept func(pts Points, values []byte) error, handler func(ctx cancel.Context, req Request) error) error {
+	s.units.mu.Lock()
+	if _, ok := s.units.handlers[s.unit]; ok {
+		s.units.mu.Unlock()
+		return fmt.Errorf("sunspec: the unit %v is already served by the gateway", s.unit)
+	}
+	s.units.handlers[s.unit] = mux(s.logger, d, g, f, intercept, handler)
+	s.units.mu.Unlock()
+
+	<-ctx.Done()
+	s.units.mu.Lock()
+	delete(s.units.handlers, s.unit)
+	s.units.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,51 @@
+package sunspec_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+func TestGateway(t *testing.T) {
+	def := definition(t, pair)
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	endpoint := free(t)
+
+	gw := sunspec.NewGateway(sunspec.Config{Endpoint: endpoint})
+	servers := []*sunspec.Server{gw.Server(sunspec.Config{Unit: 1}), gw.Server(sunspec.Config{Unit: 2})}
+	for _, s := range servers {
+		go s.Serve(ctx, ingest, def)
+	}
+	go gw.Serve(ctx)
+
+	// every unit serves its own values
+	for i, s := range servers {
+		v := uint16(i + 1)
+		wait(t, func() error { return s.Update(func(d sunspec.Device) error { return set(d, v) }) })
+	}
+	for _, unit := range []uint8{1, 2} {
+		c := sunspec.Config{Endpoint: endpoint, Unit: unit}.Client()
+		wait(t, c.Connect)
+		defer c.Disconnect()
+		if err := c.Scan(ctx, def); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := c.Value("64001.sync.A"); err != nil || v != float64(unit) {
+			t.Errorf("expected unit %v to serve %v, got %v (%v)", unit, unit, v, err)
+		}
+	}
+
+	// units not being served are answered by the gateway
+	c := (modbus.Config{Mode: "tcp", Kind: "tcp", Endpoint: endpoint, Unit: 3}).Client()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if _, err := c.ReadHoldingRegisters(ctx, 40000, 2); !errors.Is(err, modbus.GatewayTargetDeviceFailedToRespond) {
+		t.Errorf("expected exception %v, got %v", modbus.GatewayTargetDeviceFailedToRespond, err)
+	}
+}
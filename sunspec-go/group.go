@@ -36,6 +36,29 @@ type GroupDef struct {
 	Comments    []string    `json:"comments,omitempty"`
 }
 
+// size returns the number of registers (2-byte/words) of a single instance of the group.
+// Occurrences of points and sub-groups are resolved using count.
+func (def *GroupDef) size(count func(c interface{}) uint16) uint16 {
+	var l uint16
+	for _, p := range def.Points {
+		l += count(p.Count) * p.Size
+	}
+	for _, g := range def.Groups {
+		l += count(g.Count) * g.size(count)
+	}
+	return l
+}
+
+// dynamic returns the positions of all immediate sub-groups whose count is referencing a point.
+func (def *GroupDef) dynamic() (pos []int) {
+	for i, g := range def.Groups {
+		if _, ok := g.Count.(string); ok {
+			pos = append(pos, i)
+		}
+	}
+	return pos
+}
+
 // iterate executes callback recursively for group g and all its sub-groups.
 // The function immediately stops if the callback returns an error.
 func iterate(g Group, callback func(g Group) error) error {
@@ -43,7 +66,9 @@ func iterate(g Group, callback func(g Group) error) error {
 		return err
 	}
 	for _, g := range g.Groups() {
-		iterate(g, callback)
+		if err := iterate(g, callback); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -70,7 +95,12 @@ func (g *group) Name() string { return g.name }
 func (g *group) Atomic() bool { return g.atomic }
 
 // Origin returns the group´s parent container.
-func (g *group) Origin() Group { return g.origin }
+func (g *group) Origin() Group {
+	if g.origin == nil {
+		return nil
+	}
+	return g.origin
+}
 
 // Point returns the first immediate point identified by name.
 func (g *group) Point(name string) Point { return g.points.Point(name) }
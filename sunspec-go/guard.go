@@ -0,0 +1,43 @@
+package sunspec
+
+import "sync"
+
+// guard synchronizes the access to the point values of a collection of models.
+// Every model is guarded by its own read-write lock, so that requests of different models do not block each other.
+type guard struct {
+	models Models
+	locks  []sync.RWMutex
+}
+
+// newGuard returns a guard for the given models, which must be ordered by their address.
+func newGuard(mls Models) *guard {
+	return &guard{models: mls, locks: make([]sync.RWMutex, len(mls))}
+}
+
+// lock acquires the locks of all models intersecting the index, exclusively if write is set.
+// The locks are always acquired in ascending order of the model addresses, preventing deadlocks.
+// Thus all points of the index are consistent while being locked.
+// The returned function releases the acquired locks.
+func (g *guard) lock(idx Index, write bool) (unlock func()) {
+	var held []*sync.RWMutex
+	for i, m := range g.models {
+		if idx == nil || intersect(idx, m) {
+			l := &g.locks[i]
+			if write {
+				l.Lock()
+			} else {
+				l.RLock()
+			}
+			held = append(held, l)
+		}
+	}
+	return func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			if write {
+				held[i].Unlock()
+			} else {
+				held[i].RUnlock()
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package sunspec_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// pair is a model holding two values in a sync group, which must always be equal.
+const pair = `{"id": 64001, "group": {"name": "pair", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64001},
+	{"name": "L", "type": "uint16", "size": 1}],
+	"groups": [{"name": "sync", "type": "sync", "points": [
+		{"name": "A", "type": "uint16", "size": 1, "access": "RW"},
+		{"name": "B", "type": "uint16", "size": 1, "access": "RW"}]}]}}`
+
+// wait calls fn every 10ms until it succeeds, failing the test after 50 retries.
+func wait(t *testing.T, fn func() error) {
+	t.Helper()
+	for retry := 0; ; retry++ {
+		err := fn()
+		if err == nil {
+			return
+		} else if retry == 50 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// free returns a local endpoint whose port is currently not in use.
+func free(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// ingest is a handler accepting all requests.
+func ingest(ctx cancel.Context, req sunspec.Request) error {
+	defer req.Flush()
+	return req.Ingest()
+}
+
+// serve starts a server of the configuration on a free local endpoint, serving the models of the definitions.
+// It returns once the server accepts connections.
+func serve(t *testing.T, ctx cancel.Context, cfg sunspec.Config, defs ...sunspec.Definition) (string, *sunspec.Server) {
+	t.Helper()
+	cfg.Endpoint = free(t)
+	s := cfg.Server()
+	start(t, ctx, s, cfg.Endpoint, ingest, defs...)
+	return cfg.Endpoint, s
+}
+
+// start serves the models of the definitions by the server listening on the endpoint, passing the requests to the handler.
+// Unlike serve, the server may be prepared before, e.g. by registering write hooks.
+// It returns once the server accepts connections.
+func start(t *testing.T, ctx cancel.Context, s *sunspec.Server, endpoint string, handler func(ctx cancel.Context, req sunspec.Request) error, defs ...sunspec.Definition) {
+	t.Helper()
+	go s.Serve(ctx, handler, defs...)
+	wait(t, func() error { return s.View(func(sunspec.Device) error { return nil }) })
+	wait(t, func() error {
+		conn, err := net.Dial("tcp", endpoint)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	})
+}
+
+// definition returns the definition of the json encoded model.
+func definition(t *testing.T, model string) *sunspec.ModelDef {
+	t.Helper()
+	var def sunspec.ModelDef
+	if err := json.Unmarshal([]byte(model), &def); err != nil {
+		t.Fatal(err)
+	}
+	return &def
+}
+
+// consistent verifies that both values of the pair are equal.
+func consistent(d sunspec.Device) error {
+	g := d.Model(64001).Group("sync")
+	a, b := g.Point("A").(sunspec.Uint16).Get(), g.Point("B").(sunspec.Uint16).Get()
+	if a != b {
+		return fmt.Errorf("inconsistent pair %v != %v", a, b)
+	}
+	return nil
+}
+
+// set assigns v to both values of the pair.
+func set(d sunspec.Device, v uint16) error {
+	g := d.Model(64001).Group("sync")
+	g.Point("A").(sunspec.Uint16).Set(v)
+	return g.Point("B").(sunspec.Uint16).Set(v)
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	def := definition(t, pair)
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	endpoint, s := serve(t, ctx, sunspec.Config{}, def)
+
+	clients := make([]*sunspec.Client, 3)
+	for i := range clients {
+		c := sunspec.Config{Endpoint: endpoint}.Client()
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Disconnect()
+		if err := c.Scan(ctx, def); err != nil {
+			t.Fatal(err)
+		}
+		clients[i] = c
+	}
+
+	errs := make(chan error, 16)
+	var wg sync.WaitGroup
+	run := func(fn func(i int) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if err := fn(i); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	// external updates of the server
+	run(func(i int) error {
+		return s.Update(func(d sunspec.Device) error { return set(d, uint16(i)) })
+	})
+	run(func(i int) error { return s.View(consistent) })
+	// concurrent clients writing and reading the pair
+	for n, c := range clients {
+		c, n := c, n
+		m := c.Model(64001)
+		run(func(i int) error {
+			if err := c.Update(func(d sunspec.Device) error { return set(d, uint16(1000*n+i)) }); err != nil {
+				return err
+			}
+			_, err := c.Write(ctx, m.Group("sync"))
+			return err
+		})
+		run(func(i int) error {
+			if _, err := c.Read(ctx, m.Group("sync")); err != nil {
+				return err
+			}
+			return c.View(consistent)
+		})
+	}
+	// a scheduler polling concurrently to the explicit reads
+	sched := clients[0].Scheduler(nil)
+	sched.Register(time.Millisecond, clients[0].Model(64001))
+	done := cancel.New()
+	go sched.Run(done)
+
+	wg.Wait()
+	done.Cancel()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
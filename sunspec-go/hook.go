@@ -0,0 +1,126 @@
+package sunspec
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GoAethereal/modbus"
+)
+
+// Change describes the change of a point´s value by a client´s write request.
+// Values are represented as for subscriptions: numeric values as scaled float64,
+// bitfields as their active states ([]string), all others as their string representation
+// and unimplemented values as nil.
+type Change struct {
+	// Point is the written point.
+	Point Point
+	// Old is the value before the request.
+	Old interface{}
+	// New is the value proposed, respectively applied, by the request.
+	New interface{}
+}
+
+// WriteHook intercepts the client´s writes of points served by a server.
+type WriteHook struct {
+	// Validate is called before the request is handled, while the point holds the proposed value.
+	// Returning an error vetoes the complete request. A modbus.Exception is passed on to the client,
+	// any other error is reported as IllegalDataValue. The proposed value may be transformed by
+	// changing the point´s value, which is then passed to the request handler instead.
+	Validate func(c Change) error
+	// Apply is called after the request was successfully handled, e.g. to trigger device behavior.
+	// It is called while the models of the request are locked, so it may change any point
+	// within those models, but must not call View or Update of the server.
+	Apply func(c Change)
+}
+
+// hook is a registered write hook referencing its points by path.
+type hook struct {
+	path string
+	WriteHook
+}
+
+// OnWrite registers the hook for all points referenced by the path,
+// e.g. "704.WMaxLimPct" or all immediate points of a model "704.*".
+// Hooks must be registered before serving, the path is resolved when the models are instantiated.
+// Multiple hooks of a point are called in the order of their registration.
+func (s *Server) OnWrite(path string, h WriteHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook{path: path, WriteHook: h})
+}
+
+// resolve assigns the registered hooks to their points.
+func (s *Server) resolve() error {
+	s.intercepts = make(map[Point][]WriteHook)
+	for _, h := range s.hooks {
+		pts, err := s.Query(h.path)
+		if err != nil {
+			return fmt.Errorf("sunspec: invalid write hook: %w", err)
+		}
+		for _, p := range pts {
+			s.intercepts[p] = append(s.intercepts[p], h.WriteHook)
+		}
+	}
+	return nil
+}
+
+// intercept validates the values proposed by a write request for the given points.
+// The values are passed to the validating hooks and checked against the point´s constraints.
+// Transformations of the hooks are put back into values, the points keep their previous values.
+func (s *Server) intercept(pts Points, values []byte) error {
+	backup := make([]byte, len(values))
+	if err := pts.encode(backup); err != nil {
+		return err
+	}
+	defer pts.decode(backup)
+	old := s.snapshot(pts)
+	if _, err := pts.decode(values); err != nil {
+		return err
+	}
+	for _, p := range pts {
+		for _, h := range s.intercepts[p] {
+			if h.Validate == nil {
+				continue
+			}
+			if err := h.Validate(Change{Point: p, Old: old[p], New: value(p)}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := pts.check(); err != nil {
+		return err
+	}
+	return pts.encode(values)
+}
+
+// applied calls the applying hooks of all given points after a successful write.
+// The old values must have been taken by a snapshot before the request was handled.
+func (s *Server) applied(pts Points, old map[Point]interface{}) {
+	for _, p := range pts {
+		for _, h := range s.intercepts[p] {
+			if h.Apply != nil {
+				h.Apply(Change{Point: p, Old: old[p], New: value(p)})
+			}
+		}
+	}
+}
+
+// snapshot returns the current values of all hooked points.
+func (s *Server) snapshot(pts Points) map[Point]interface{} {
+	col := make(map[Point]interface{})
+	for _, p := range pts {
+		if len(s.intercepts[p]) > 0 {
+			col[p] = value(p)
+		}
+	}
+	return col
+}
+
+// exception returns the modbus exception reported for an error refusing a write request.
+func exception(err error) modbus.Exception {
+	var ex modbus.Exception
+	if errors.As(err, &ex) {
+		return ex
+	}
+	return modbus.IllegalDataValue
+}
@@ -0,0 +1,130 @@
+package sunspec_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+func TestWriteHooks(t *testing.T) {
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	def := definition(t, pair)
+
+	// A vetoes odd values by exception and other errors, B is limited to 50
+	var mu sync.Mutex
+	var applied []sunspec.Change
+	changes := func() []sunspec.Change {
+		mu.Lock()
+		defer mu.Unlock()
+		col := applied
+		applied = nil
+		return col
+	}
+	endpoint := free(t)
+	s := sunspec.Config{Endpoint: endpoint}.Server()
+	s.OnWrite("64001.sync.A", sunspec.WriteHook{
+		Validate: func(c sunspec.Change) error {
+			switch v := c.New.(float64); {
+			case v == 99:
+				return errors.New("refused")
+			case int(v)%2 == 1:
+				return modbus.SlaveDeviceBusy
+			}
+			return nil
+		},
+	})
+	s.OnWrite("64001.sync.B", sunspec.WriteHook{
+		Validate: func(c sunspec.Change) error {
+			if p := c.Point.(sunspec.Uint16); p.Get() > 50 {
+				return p.Set(50)
+			}
+			return nil
+		},
+	})
+	s.OnWrite("64001.sync.*", sunspec.WriteHook{
+		Apply: func(c sunspec.Change) {
+			// the change is already applied to the point
+			if v := float64(c.Point.(sunspec.Uint16).Get()); v != c.New {
+				t.Errorf("expected %v to hold the applied value %v, got %v", c.Point.Name(), c.New, v)
+			}
+			mu.Lock()
+			applied = append(applied, c)
+			mu.Unlock()
+		},
+	})
+	// the handler fails requests writing 42 after ingesting them
+	start(t, ctx, s, endpoint, func(ctx cancel.Context, req sunspec.Request) error {
+		defer req.Flush()
+		if err := req.Ingest(); err != nil {
+			return err
+		}
+		for _, p := range req.Points() {
+			if p.Name() == "A" && p.(sunspec.Uint16).Get() == 42 {
+				return errors.New("failed")
+			}
+		}
+		return nil
+	}, def)
+
+	c := sunspec.Config{Endpoint: endpoint}.Client()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if err := c.Scan(ctx, def); err != nil {
+		t.Fatal(err)
+	}
+	g := c.Model(64001).Group("sync")
+	a, b := g.Point("A").(sunspec.Uint16), g.Point("B").(sunspec.Uint16)
+	served := func() (a, b uint16) {
+		t.Helper()
+		if err := s.View(func(d sunspec.Device) error {
+			g := d.Model(64001).Group("sync")
+			a, b = g.Point("A").(sunspec.Uint16).Get(), g.Point("B").(sunspec.Uint16).Get()
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return a, b
+	}
+
+	// the transformed value is applied, reporting the old and new values
+	a.Set(2)
+	b.Set(80)
+	if _, err := c.Write(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := served(); a != 2 || b != 50 {
+		t.Errorf("expected the served values 2 and 50, got %v and %v", a, b)
+	}
+	if applied := changes(); len(applied) != 2 || applied[0].Old != 0.0 || applied[0].New != 2.0 || applied[1].Old != 0.0 || applied[1].New != 50.0 {
+		t.Errorf("expected the changes of A from 0 to 2 and B from 0 to 50, got %+v", applied)
+	}
+
+	// vetoes refuse the complete request with their exception
+	for _, tc := range []struct {
+		a  uint16
+		ex modbus.Exception
+	}{
+		{a: 3, ex: modbus.SlaveDeviceBusy},
+		{a: 99, ex: modbus.IllegalDataValue},
+		{a: 42, ex: modbus.SlaveDeviceFailure},
+	} {
+		a.Set(tc.a)
+		b.Set(10)
+		if _, err := c.Write(ctx, g); !errors.Is(err, tc.ex) {
+			t.Errorf("expected writing %v to be refused by exception %v, got %v", tc.a, tc.ex, err)
+		}
+		if a, b := served(); a != 2 || b != 50 {
+			t.Errorf("expected the refused write of %v to keep the served values 2 and 50, got %v and %v", tc.a, a, b)
+		}
+		if applied := changes(); applied != nil {
+			t.Errorf("expected the refused write of %v not to be applied, got %+v", tc.a, applied)
+		}
+	}
+}
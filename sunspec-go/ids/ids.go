@@ -0,0 +1,417 @@
+// Package ids detects intrusions in the modbus traffic of sunspec devices.
+//
+// An engine consumes the application data units exchanged with a device, e.g. read from a capture,
+// tapped from a proxy or mirrored from a switch. It verifies the protocol and decodes the register values
+// in transit into the points of the scanned device, raising alerts for violations:
+//
+//	c := sunspec.Config{Endpoint: "battery:502"}.Client()
+//	c.Connect()
+//	c.Scan(ctx, defs...)
+//	c.Disconnect()
+//	e := ids.NewEngine(c.Device, func(a ids.Alert) { log.Println(a) })
+//	ids.ReadPcap(os.Stdin, 502, e.Consume)
+package ids
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// AlertKind classifies the detected violation.
+type AlertKind int
+
+const (
+	// AlertMalformed reports a unit not conforming to the modbus-TCP framing.
+	AlertMalformed AlertKind = iota
+	// AlertViolation reports a protocol violation, e.g. a response without request or an invalid quantity.
+	AlertViolation
+	// AlertFunctionCode reports a request of a function code not allowed by the engine.
+	AlertFunctionCode
+	// AlertUnmapped reports a request of registers outside the sunspec map of the device.
+	AlertUnmapped
+	// AlertOutOfRange reports a value violating the constraints of its point.
+	AlertOutOfRange
+	// AlertReadOnly reports a write to a point not writable by clients.
+	AlertReadOnly
+	// AlertException reports an exception responded by the device.
+	AlertException
+)
+
+// String returns a human readable representation of the kind.
+func (k AlertKind) String() string {
+	switch k {
+	case AlertMalformed:
+		return "malformed"
+	case AlertViolation:
+		return "violation"
+	case AlertFunctionCode:
+		return "function code"
+	case AlertUnmapped:
+		return "unmapped"
+	case AlertOutOfRange:
+		return "out of range"
+	case AlertReadOnly:
+		return "read only"
+	case AlertException:
+		return "exception"
+	}
+	return fmt.Sprintf("AlertKind(%d)", int(k))
+}
+
+// Alert describes a detected violation.
+type Alert struct {
+	// Time is the time the offending unit was captured.
+	Time time.Time
+	Kind AlertKind
+	// Flow identifies the connection of the unit.
+	Flow string
+	Unit uint8
+	Code byte
+	// Address is the starting address of the request, if any.
+	Address uint16
+	// Path references the offending point, if any.
+	Path string
+	// Value is the offending value of the point, if any.
+	Value interface{}
+	// Message describes the violation.
+	Message string
+}
+
+// String returns a human readable representation of the alert.
+func (a Alert) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v [%v] %v unit %v code %#02x", a.Time.Format(time.RFC3339Nano), a.Kind, a.Flow, a.Unit, a.Code)
+	if a.Address != 0 {
+		fmt.Fprintf(&b, " address %v", a.Address)
+	}
+	if a.Path != "" {
+		fmt.Fprintf(&b, " %v", a.Path)
+		if a.Value != nil {
+			fmt.Fprintf(&b, " = %v", a.Value)
+		}
+	}
+	b.WriteString(": " + a.Message)
+	return b.String()
+}
+
+// Frame is a modbus-TCP application data unit in transit.
+type Frame struct {
+	// Time is the time the unit was captured.
+	Time time.Time
+	// Flow identifies the connection between the client and the device.
+	// Requests and responses of one connection must share the flow.
+	Flow string
+	// Response is true for units sent by the device.
+	Response bool
+	ADU      []byte
+}
+
+// maxPending is the number of outstanding requests tracked per flow.
+// Exceeding requests evict the oldest, whose responses are then reported without request.
+const maxPending = 64
+
+// Engine detects intrusions in the consumed traffic.
+// The engine is safe for concurrent use.
+type Engine struct {
+	mu      sync.Mutex
+	device  sunspec.Device
+	alert   func(Alert)
+	codes   map[byte]bool
+	pending map[string]map[uint16]request
+	// first and end delimit the registers of the sunspec map, from the marker to the end model
+	first, end uint32
+}
+
+// request is a request awaiting its response.
+type request struct {
+	time time.Time
+	unit uint8
+	code byte
+	pdu  []byte
+}
+
+// NewEngine returns an engine decoding the traffic into the points of the device, calling alert for every violation.
+// The device, typically the one of a scanned client, is updated by the consumed values and must not be used concurrently.
+// Without device only the protocol is verified.
+// The function codes reading and writing multiple holding registers are allowed, as used by sunspec clients.
+func NewEngine(d sunspec.Device, alert func(Alert)) *Engine {
+	e := &Engine{
+		device:  d,
+		alert:   alert,
+		codes:   map[byte]bool{0x03: true, 0x10: true},
+		pending: make(map[string]map[uint16]request),
+	}
+	if d != nil {
+		if mls := d.Models(); len(mls) > 0 {
+			last := mls[len(mls)-1]
+			e.first = uint32(mls[0].Address()) - 2
+			e.end = uint32(last.Address()) + 2 + uint32(last.Length().Get()) + 2
+		}
+	}
+	return e
+}
+
+// Allow replaces the function codes clients are allowed to request.
+func (e *Engine) Allow(codes ...byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.codes = make(map[byte]bool, len(codes))
+	for _, c := range codes {
+		e.codes[c] = true
+	}
+}
+
+// Consume inspects the unit, raising alerts for all detected violations.
+func (e *Engine) Consume(f Frame) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	adu := f.ADU
+	a := Alert{Time: f.Time, Flow: f.Flow}
+	if len(adu) >= 8 {
+		a.Unit, a.Code = adu[6], adu[7]
+	}
+	switch {
+	case len(adu) < 8:
+		e.raise(a, AlertMalformed, "unit of %v bytes is shorter than the minimum of 8", len(adu))
+	case binary.BigEndian.Uint16(adu[2:]) != 0:
+		e.raise(a, AlertMalformed, "protocol identifier %v is not modbus", binary.BigEndian.Uint16(adu[2:]))
+	case int(binary.BigEndian.Uint16(adu[4:])) != len(adu)-6:
+		e.raise(a, AlertMalformed, "length %v does not match the %v bytes following", binary.BigEndian.Uint16(adu[4:]), len(adu)-6)
+	case f.Response:
+		e.response(a, binary.BigEndian.Uint16(adu), adu[8:])
+	default:
+		e.request(a, binary.BigEndian.Uint16(adu), adu[8:])
+	}
+}
+
+// Tap consumes the request and response of a proxy, see sunspec.Proxy.Tap.
+// A nil response, e.g. of a dropped request, is not consumed.
+func (e *Engine) Tap(req, res []byte) {
+	now := time.Now()
+	e.Consume(Frame{Time: now, Flow: "proxy", ADU: req})
+	if res != nil {
+		e.Consume(Frame{Time: now, Flow: "proxy", Response: true, ADU: res})
+	}
+}
+
+// raise reports the alert of the kind.
+func (e *Engine) raise(a Alert, kind AlertKind, format string, args ...interface{}) {
+	a.Kind, a.Message = kind, fmt.Sprintf(format, args...)
+	e.alert(a)
+}
+
+// request inspects the protocol data unit of a request and tracks it for its response.
+func (e *Engine) request(a Alert, tid uint16, pdu []byte) {
+	if a.Code >= 0x80 {
+		e.raise(a, AlertMalformed, "request of exception code")
+		return
+	}
+	pending := e.pending[a.Flow]
+	if pending == nil {
+		pending = make(map[uint16]request)
+		e.pending[a.Flow] = pending
+	}
+	if _, ok := pending[tid]; ok {
+		e.raise(a, AlertViolation, "transaction id %v is reused while pending", tid)
+	}
+	if len(pending) >= maxPending {
+		var oldest uint16
+		for id, r := range pending {
+			if o, ok := pending[oldest]; !ok || r.time.Before(o.time) {
+				oldest = id
+			}
+		}
+		delete(pending, oldest)
+	}
+	pending[tid] = request{time: a.Time, unit: a.Unit, code: a.Code, pdu: append([]byte(nil), pdu...)}
+
+	if !e.codes[a.Code] {
+		e.raise(a, AlertFunctionCode, "function code %#02x is not allowed", a.Code)
+	}
+	if len(pdu) >= 2 {
+		a.Address = binary.BigEndian.Uint16(pdu)
+	}
+	if msg := malformed(a.Code, pdu); msg != "" {
+		e.raise(a, AlertViolation, "%v", msg)
+		return
+	}
+	switch a.Code {
+	case 0x03, 0x06, 0x10:
+	default:
+		return
+	}
+	quantity := uint16(1)
+	if a.Code != 0x06 {
+		quantity = binary.BigEndian.Uint16(pdu[2:])
+	}
+	if !e.mapped(a.Address, quantity) {
+		e.raise(a, AlertUnmapped, "registers %v to %v are not mapped by the device", a.Address, uint32(a.Address)+uint32(quantity)-1)
+		return
+	}
+	switch a.Code {
+	case 0x06:
+		e.write(a, a.Address, pdu[2:4])
+	case 0x10:
+		e.write(a, a.Address, pdu[5:])
+	}
+}
+
+// response inspects the protocol data unit of a response against its request.
+func (e *Engine) response(a Alert, tid uint16, pdu []byte) {
+	req, ok := e.pending[a.Flow][tid]
+	if !ok {
+		e.raise(a, AlertViolation, "response to transaction id %v without request", tid)
+		return
+	}
+	delete(e.pending[a.Flow], tid)
+	if len(req.pdu) >= 2 {
+		a.Address = binary.BigEndian.Uint16(req.pdu)
+	}
+	switch {
+	case a.Unit != req.unit:
+		e.raise(a, AlertViolation, "response of unit %v to a request of unit %v", a.Unit, req.unit)
+		return
+	case a.Code&0x7F != req.code:
+		e.raise(a, AlertViolation, "response of function code %#02x to a request of %#02x", a.Code&0x7F, req.code)
+		return
+	case a.Code >= 0x80 && len(pdu) != 1:
+		e.raise(a, AlertMalformed, "exception response of %v bytes", len(pdu))
+		return
+	case a.Code >= 0x80:
+		e.raise(a, AlertException, "%v", modbus.Exception(pdu[0]))
+		return
+	case malformed(req.code, req.pdu) != "":
+		return
+	}
+	switch req.code {
+	case 0x01, 0x02:
+		if n := (binary.BigEndian.Uint16(req.pdu[2:]) + 7) / 8; len(pdu) != 1+int(n) || pdu[0] != byte(n) {
+			e.raise(a, AlertViolation, "response of %v bytes to a request of %v bytes", len(pdu)-1, n)
+		}
+	case 0x03, 0x04:
+		n := 2 * int(binary.BigEndian.Uint16(req.pdu[2:]))
+		if len(pdu) != 1+n || int(pdu[0]) != n {
+			e.raise(a, AlertViolation, "response of %v bytes to a request of %v bytes", len(pdu)-1, n)
+			return
+		}
+		if req.code == 0x03 && e.mapped(a.Address, uint16(n/2)) {
+			e.read(a, a.Address, pdu[1:])
+		}
+	case 0x05, 0x06:
+		if string(pdu) != string(req.pdu) {
+			e.raise(a, AlertViolation, "response % x does not echo the request % x", pdu, req.pdu)
+		}
+	case 0x0F, 0x10:
+		if string(pdu) != string(req.pdu[:4]) {
+			e.raise(a, AlertViolation, "response % x does not echo the request % x", pdu, req.pdu[:4])
+		}
+	}
+}
+
+// malformed verifies the protocol data unit of a request of a public function code.
+// A description of the violation is returned, if any.
+func malformed(code byte, pdu []byte) string {
+	bounds := func(n int, max uint16) string {
+		if len(pdu) < n {
+			return fmt.Sprintf("request of %v bytes is too short", len(pdu))
+		}
+		if q := binary.BigEndian.Uint16(pdu[2:]); q < 1 || q > max {
+			return fmt.Sprintf("quantity %v is not within 1 and %v", q, max)
+		}
+		return ""
+	}
+	switch code {
+	case 0x01, 0x02, 0x03, 0x04:
+		max := map[byte]uint16{0x01: 2000, 0x02: 2000, 0x03: 125, 0x04: 125}[code]
+		if msg := bounds(4, max); msg != "" || len(pdu) == 4 {
+			return msg
+		}
+		return fmt.Sprintf("request of %v bytes exceeds 4 bytes", len(pdu))
+	case 0x05:
+		if len(pdu) != 4 {
+			return fmt.Sprintf("request of %v bytes is not 4 bytes", len(pdu))
+		}
+		if v := binary.BigEndian.Uint16(pdu[2:]); v != 0x0000 && v != 0xFF00 {
+			return fmt.Sprintf("coil value %#04x is neither on nor off", v)
+		}
+	case 0x06:
+		if len(pdu) != 4 {
+			return fmt.Sprintf("request of %v bytes is not 4 bytes", len(pdu))
+		}
+	case 0x0F, 0x10:
+		max, size := uint16(1968), func(q uint16) int { return int(q+7) / 8 }
+		if code == 0x10 {
+			max, size = 123, func(q uint16) int { return 2 * int(q) }
+		}
+		if msg := bounds(5, max); msg != "" {
+			return msg
+		}
+		n := size(binary.BigEndian.Uint16(pdu[2:]))
+		if int(pdu[4]) != n || len(pdu) != 5+n {
+			return fmt.Sprintf("byte count %v and %v values do not match the quantity of %v bytes", pdu[4], len(pdu)-5, n)
+		}
+	}
+	return ""
+}
+
+// mapped returns whether the registers are within the sunspec map, including the marker and the end model.
+// Without device all registers are considered mapped.
+func (e *Engine) mapped(address, quantity uint16) bool {
+	return e.end == 0 || uint32(address) >= e.first && uint32(address)+uint32(quantity) <= e.end
+}
+
+// write decodes the written values, alerting for points not writable or violating their constraints.
+// Registers outside the models, i.e. the marker and the end model, are never writable.
+func (e *Engine) write(a Alert, address uint16, values []byte) {
+	if e.end == 0 {
+		return
+	}
+	mls := e.device.Models()
+	if q := uint32(len(values) / 2); uint32(address) < uint32(mls[0].Address()) || uint32(address)+q > e.end-2 {
+		e.raise(a, AlertReadOnly, "registers %v to %v outside the models are not writable", address, uint32(address)+q-1)
+	}
+	for _, p := range e.decode(a, address, values) {
+		if !p.Writable() {
+			e.point(a, p, AlertReadOnly, "point is not writable")
+		}
+	}
+}
+
+// read decodes the read values, alerting for points violating their constraints.
+func (e *Engine) read(a Alert, address uint16, values []byte) {
+	if e.end != 0 {
+		e.decode(a, address, values)
+	}
+}
+
+// decode sets the points contained by the values, alerting for values not decodable or violating their constraints.
+// The decoded points are returned in the order of their address.
+func (e *Engine) decode(a Alert, address uint16, values []byte) sunspec.Points {
+	pts, err := sunspec.Decode(e.device, address, values)
+	if err != nil {
+		e.raise(a, AlertOutOfRange, "%v", err)
+	}
+	sort.SliceStable(pts, func(i, j int) bool { return pts[i].Address() < pts[j].Address() })
+	for _, p := range pts {
+		if err := sunspec.Check(p); err != nil {
+			e.point(a, p, AlertOutOfRange, err.Error())
+		}
+	}
+	return pts
+}
+
+// point reports the alert of the kind for the point.
+func (e *Engine) point(a Alert, p sunspec.Point, kind AlertKind, msg string) {
+	a.Path = sunspec.Path(p)
+	if v, err := sunspec.Scaled(p); err == nil {
+		a.Value = v
+	}
+	e.raise(a, kind, "%v", msg)
+}
@@ -0,0 +1,183 @@
+package ids_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TRICERA-energy/sunspec"
+	"github.com/TRICERA-energy/sunspec/ids"
+)
+
+// thermostat is a model of a read only temperature and a writable setpoint, instanced at 40002.
+const thermostat = `{"id": 64002, "group": {"name": "thermostat", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64002},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "Tmp", "type": "int16", "size": 1, "min": -40, "max": 80},
+	{"name": "SetPt", "type": "int16", "size": 1, "access": "RW", "min": 5, "max": 30}]}}`
+
+func device(t *testing.T) sunspec.Device {
+	t.Helper()
+	var def sunspec.ModelDef
+	if err := json.Unmarshal([]byte(thermostat), &def); err != nil {
+		t.Fatal(err)
+	}
+	m, err := def.Instance(40002, func(pts []sunspec.Point) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sunspec.Models{m}
+}
+
+// adu decodes the hex representation of an application data unit.
+func adu(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEngine(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		frames []string // requests and responses (prefixed by "<") in the order consumed
+		kinds  []ids.AlertKind
+		path   string
+	}{
+		{"read", []string{"0001 0000 0006 01 03 9c40 0008", "<0001 0000 0013 01 03 10 5375 6e53 fa02 0002 0019 0014 ffff 0000"}, nil, ""},
+		{"write", []string{"0001 0000 0009 01 10 9c45 0001 02 0014", "<0001 0000 0006 01 10 9c45 0001"}, nil, ""},
+		{"short", []string{"0001 0000 0001 01"}, []ids.AlertKind{ids.AlertMalformed}, ""},
+		{"protocol", []string{"0001 0001 0006 01 03 9c40 0001"}, []ids.AlertKind{ids.AlertMalformed}, ""},
+		{"length", []string{"0001 0000 0009 01 03 9c40 0001"}, []ids.AlertKind{ids.AlertMalformed}, ""},
+		{"unrequested", []string{"<0001 0000 0005 01 03 02 0019"}, []ids.AlertKind{ids.AlertViolation}, ""},
+		{"reused", []string{"0001 0000 0006 01 03 9c44 0001", "0001 0000 0006 01 03 9c44 0001"}, []ids.AlertKind{ids.AlertViolation}, ""},
+		{"unit", []string{"0001 0000 0006 01 03 9c44 0001", "<0001 0000 0005 02 03 02 0019"}, []ids.AlertKind{ids.AlertViolation}, ""},
+		{"quantity", []string{"0001 0000 0006 01 03 9c44 007e"}, []ids.AlertKind{ids.AlertViolation}, ""},
+		{"byte count", []string{"0001 0000 0006 01 03 9c44 0002", "<0001 0000 0005 01 03 02 0019"}, []ids.AlertKind{ids.AlertViolation}, ""},
+		{"echo", []string{"0001 0000 0009 01 10 9c45 0001 02 0014", "<0001 0000 0006 01 10 9c45 0002"}, []ids.AlertKind{ids.AlertViolation}, ""},
+		{"function code", []string{"0001 0000 0006 01 06 9c45 0014"}, []ids.AlertKind{ids.AlertFunctionCode}, ""},
+		{"unmapped", []string{"0001 0000 0006 01 03 9c46 0004"}, []ids.AlertKind{ids.AlertUnmapped}, ""},
+		{"exception", []string{"0001 0000 0006 01 03 9c44 0001", "<0001 0000 0003 01 83 02"}, []ids.AlertKind{ids.AlertException}, ""},
+		{"temperature", []string{"0001 0000 0006 01 03 9c44 0001", "<0001 0000 0005 01 03 02 ff9c"}, []ids.AlertKind{ids.AlertOutOfRange}, "64002.Tmp"},
+		{"setpoint", []string{"0001 0000 0009 01 10 9c45 0001 02 0064"}, []ids.AlertKind{ids.AlertOutOfRange}, "64002.SetPt"},
+		{"read only", []string{"0001 0000 0009 01 10 9c44 0001 02 0019"}, []ids.AlertKind{ids.AlertReadOnly}, "64002.Tmp"},
+		{"marker", []string{"0001 0000 0009 01 10 9c40 0001 02 0000"}, []ids.AlertKind{ids.AlertReadOnly}, ""},
+	} {
+		var alerts []ids.Alert
+		e := ids.NewEngine(device(t), func(a ids.Alert) { alerts = append(alerts, a) })
+		for _, s := range c.frames {
+			f := ids.Frame{Time: time.Now(), Flow: "test", Response: strings.HasPrefix(s, "<")}
+			f.ADU = adu(t, strings.TrimPrefix(s, "<"))
+			e.Consume(f)
+		}
+		if len(alerts) != len(c.kinds) {
+			t.Errorf("%v: expected alerts %v, got %v", c.name, c.kinds, alerts)
+			continue
+		}
+		for i, a := range alerts {
+			if a.Kind != c.kinds[i] || a.Path != c.path {
+				t.Errorf("%v: expected a %v alert of %q, got %v", c.name, c.kinds[i], c.path, a)
+			}
+		}
+	}
+}
+
+// capture returns a pcap of ethernet frames holding the tcp segments.
+func capture(segments ...segment) []byte {
+	var b bytes.Buffer
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr, 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:], 1)
+	b.Write(hdr)
+	for i, s := range segments {
+		tcp := make([]byte, 20, 20+len(s.payload))
+		binary.BigEndian.PutUint16(tcp, s.sport)
+		binary.BigEndian.PutUint16(tcp[2:], s.dport)
+		binary.BigEndian.PutUint32(tcp[4:], s.seq)
+		tcp[12], tcp[13] = 5<<4, s.flags
+		tcp = append(tcp, s.payload...)
+		ip := make([]byte, 20, 20+len(tcp))
+		ip[0], ip[8], ip[9] = 0x45, 64, 6
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+		copy(ip[12:], []byte{10, 0, 0, 1})
+		copy(ip[16:], []byte{10, 0, 0, 2})
+		if s.sport == 502 {
+			copy(ip[12:], []byte{10, 0, 0, 2})
+			copy(ip[16:], []byte{10, 0, 0, 1})
+		}
+		frame := append(make([]byte, 12), 0x08, 0x00)
+		frame = append(frame, append(ip, tcp...)...)
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec, uint32(1600000000+i))
+		binary.LittleEndian.PutUint32(rec[8:], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(rec[12:], uint32(len(frame)))
+		b.Write(rec)
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+type segment struct {
+	sport, dport uint16
+	seq          uint32
+	flags        byte
+	payload      []byte
+}
+
+func TestReadPcap(t *testing.T) {
+	write := adu(t, "0001 0000 0009 01 10 9c44 0001 02 0019")
+	read := adu(t, "0002 0000 0006 01 03 9c44 0002")
+	res := adu(t, "0002 0000 0007 01 03 04 0019 0014")
+	data := capture(
+		segment{sport: 50000, dport: 502, seq: 99, flags: 0x02},
+		// the write is split, its second segment captured first and then retransmitted
+		segment{sport: 50000, dport: 502, seq: 105, payload: write[5:]},
+		segment{sport: 50000, dport: 502, seq: 100, payload: write[:5]},
+		segment{sport: 50000, dport: 502, seq: 105, payload: write[5:]},
+		segment{sport: 50000, dport: 502, seq: 100 + uint32(len(write)), payload: read},
+		// the response is captured mid-connection
+		segment{sport: 502, dport: 50000, seq: 7000, payload: res},
+		segment{sport: 8080, dport: 50001, seq: 1, payload: []byte("ignored")},
+	)
+	var frames []ids.Frame
+	if err := ids.ReadPcap(bytes.NewReader(data), 502, func(f ids.Frame) { frames = append(frames, f) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("expected 3 frames, got %v", frames)
+	}
+	for i, expect := range [][]byte{write, read, res} {
+		if f := frames[i]; !bytes.Equal(f.ADU, expect) || f.Flow != "10.0.0.1:50000-10.0.0.2:502" || f.Response != (i == 2) {
+			t.Errorf("expected % x, got %+v", expect, f)
+		}
+	}
+	if frames[0].Time.Unix() != 1600000002 {
+		t.Errorf("expected the time of the completing segment, got %v", frames[0].Time)
+	}
+
+	// the capture is inspected by the engine
+	var alerts []ids.Alert
+	e := ids.NewEngine(device(t), func(a ids.Alert) { alerts = append(alerts, a) })
+	if err := ids.ReadPcap(bytes.NewReader(data), 502, e.Consume); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Kind != ids.AlertReadOnly {
+		t.Errorf("expected a read only alert, got %v", alerts)
+	}
+
+	if err := ids.ReadPcap(bytes.NewReader(data[:len(data)-3]), 502, func(ids.Frame) {}); err == nil {
+		t.Error("expected an error for a truncated capture")
+	}
+	if err := ids.ReadPcap(strings.NewReader("not a capture at all...."), 502, func(ids.Frame) {}); err == nil {
+		t.Error("expected an error for an invalid capture")
+	}
+}
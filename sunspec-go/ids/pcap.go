@@ -0,0 +1,260 @@
+package ids
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// maxSnap limits the size of a captured packet.
+const maxSnap = 1 << 18
+
+// maxSegments is the number of out of order segments buffered per direction.
+// Exceeding segments resynchronize the stream at the segment, discarding the missing data.
+const maxSegments = 64
+
+// link types of the captured packets
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkSLL      = 113
+	linkSLL2     = 276
+)
+
+// ReadPcap reads the modbus-TCP traffic of the capture in the classic pcap format, calling fn for every application data unit.
+// The segments of each connection are reassembled, so units spanning several segments are preserved.
+// Units sent from the port are responses of the device, units sent to it are requests.
+// Reading continues until the end of the capture, so live captures are consumed as they are written, e.g. by
+//
+//	tcpdump -i eth0 -U -w - tcp port 502
+func ReadPcap(r io.Reader, port uint16, fn func(Frame)) error {
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return fmt.Errorf("ids: invalid pcap header: %w", err)
+	}
+	var order binary.ByteOrder
+	nano := false
+	switch {
+	case binary.LittleEndian.Uint32(hdr[:]) == 0xa1b2c3d4:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[:]) == 0xa1b2c3d4:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr[:]) == 0xa1b23c4d:
+		order, nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[:]) == 0xa1b23c4d:
+		order, nano = binary.BigEndian, true
+	default:
+		return errors.New("ids: invalid pcap magic number")
+	}
+	link := order.Uint32(hdr[20:]) & 0x0FFFFFFF
+	switch link {
+	case linkNull, linkEthernet, linkRaw, linkSLL, linkSLL2:
+	default:
+		return fmt.Errorf("ids: unsupported pcap link type %v", link)
+	}
+	a := &assembler{port: port, fn: fn, streams: make(map[string]*stream)}
+	var rec [16]byte
+	for {
+		if _, err := io.ReadFull(r, rec[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("ids: truncated pcap record: %w", err)
+		}
+		n := order.Uint32(rec[8:])
+		if n > maxSnap {
+			return fmt.Errorf("ids: pcap record of %v bytes exceeds the maximum of %v", n, maxSnap)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("ids: truncated pcap record: %w", err)
+		}
+		frac := time.Duration(order.Uint32(rec[4:]))
+		if !nano {
+			frac *= time.Microsecond
+		}
+		t := time.Unix(int64(order.Uint32(rec[:])), int64(frac))
+		if ip := network(link, data); ip != nil {
+			a.packet(t, ip)
+		}
+	}
+}
+
+// network returns the ip packet of the link layer frame, or nil for other protocols.
+func network(link uint32, data []byte) []byte {
+	var proto uint16
+	switch link {
+	case linkNull:
+		if len(data) < 4 {
+			return nil
+		}
+		// the address family is given in the byte order of the capturing host
+		family := binary.LittleEndian.Uint32(data)
+		if family > 0xFFFF {
+			family = binary.BigEndian.Uint32(data)
+		}
+		switch family {
+		case 2:
+			proto = 0x0800
+		case 24, 28, 30:
+			proto = 0x86DD
+		}
+		data = data[4:]
+	case linkEthernet:
+		if len(data) < 14 {
+			return nil
+		}
+		proto, data = binary.BigEndian.Uint16(data[12:]), data[14:]
+		for (proto == 0x8100 || proto == 0x88A8) && len(data) >= 4 {
+			proto, data = binary.BigEndian.Uint16(data[2:]), data[4:]
+		}
+	case linkRaw:
+		return data
+	case linkSLL:
+		if len(data) < 16 {
+			return nil
+		}
+		proto, data = binary.BigEndian.Uint16(data[14:]), data[16:]
+	case linkSLL2:
+		if len(data) < 20 {
+			return nil
+		}
+		proto, data = binary.BigEndian.Uint16(data), data[20:]
+	}
+	if proto != 0x0800 && proto != 0x86DD {
+		return nil
+	}
+	return data
+}
+
+// assembler reassembles the tcp streams of the captured packets.
+type assembler struct {
+	port    uint16
+	fn      func(Frame)
+	streams map[string]*stream
+}
+
+// stream is a direction of a tcp connection.
+type stream struct {
+	synced   bool
+	next     uint32
+	buf      []byte
+	segments map[uint32][]byte
+}
+
+// packet processes the ip packet.
+// Fragmented packets and ipv6 extension headers are not supported and skipped.
+func (a *assembler) packet(t time.Time, ip []byte) {
+	var src, dst net.IP
+	var seg []byte
+	switch {
+	case len(ip) >= 20 && ip[0]>>4 == 4:
+		ihl, total := int(ip[0]&0x0F)*4, int(binary.BigEndian.Uint16(ip[2:]))
+		if ip[9] != 6 || ihl < 20 || total < ihl || total > len(ip) || binary.BigEndian.Uint16(ip[6:])&0x3FFF != 0 {
+			return
+		}
+		src, dst, seg = net.IP(ip[12:16]), net.IP(ip[16:20]), ip[ihl:total]
+	case len(ip) >= 40 && ip[0]>>4 == 6:
+		total := 40 + int(binary.BigEndian.Uint16(ip[4:]))
+		if ip[6] != 6 || total > len(ip) {
+			return
+		}
+		src, dst, seg = net.IP(ip[8:24]), net.IP(ip[24:40]), ip[40:total]
+	default:
+		return
+	}
+	if len(seg) < 20 || int(seg[12]>>4)*4 < 20 || int(seg[12]>>4)*4 > len(seg) {
+		return
+	}
+	sport, dport := binary.BigEndian.Uint16(seg), binary.BigEndian.Uint16(seg[2:])
+	from, to := endpoint(src, sport), endpoint(dst, dport)
+	var f Frame
+	switch a.port {
+	case dport:
+		f = Frame{Time: t, Flow: from + "-" + to}
+	case sport:
+		f = Frame{Time: t, Flow: to + "-" + from, Response: true}
+	default:
+		return
+	}
+	key := from + ">" + to
+	s := a.streams[key]
+	if s == nil {
+		s = &stream{segments: make(map[uint32][]byte)}
+		a.streams[key] = s
+	}
+	seq, flags, payload := binary.BigEndian.Uint32(seg[4:]), seg[13], seg[int(seg[12]>>4)*4:]
+	if flags&0x02 != 0 {
+		// a new connection starts after the sequence number of its syn
+		*s = stream{synced: true, next: seq + 1, segments: make(map[uint32][]byte)}
+	}
+	if len(payload) > 0 {
+		s.receive(seq, payload)
+		s.extract(f, a.fn)
+	}
+	if flags&0x05 != 0 {
+		delete(a.streams, key)
+	}
+}
+
+// endpoint returns the textual representation of the address and port.
+func endpoint(ip net.IP, port uint16) string {
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+}
+
+// receive adds the payload of the segment to the stream in the order of the sequence numbers.
+// Captures starting mid-connection are synchronized with their first segment.
+func (s *stream) receive(seq uint32, payload []byte) {
+	if !s.synced {
+		s.synced, s.next = true, seq
+	}
+	switch d := int32(seq - s.next); {
+	case d > 0:
+		if len(s.segments) < maxSegments {
+			s.segments[seq] = append([]byte(nil), payload...)
+			return
+		}
+		// the missing data is lost, so the stream continues at the segment
+		s.buf, s.segments = nil, make(map[uint32][]byte)
+		s.next = seq
+	case d < 0:
+		// retransmitted data is only added past the received data
+		if int(-d) >= len(payload) {
+			return
+		}
+		payload = payload[-d:]
+	}
+	s.buf = append(s.buf, payload...)
+	s.next += uint32(len(payload))
+	for {
+		next, ok := s.segments[s.next]
+		if !ok {
+			break
+		}
+		delete(s.segments, s.next)
+		s.buf = append(s.buf, next...)
+		s.next += uint32(len(next))
+	}
+}
+
+// extract calls fn for every complete application data unit of the stream, as framed by the length of its header.
+// A length exceeding the maximum unit discards the stream, as its framing is lost.
+func (s *stream) extract(f Frame, fn func(Frame)) {
+	for len(s.buf) >= 6 {
+		n := 6 + int(binary.BigEndian.Uint16(s.buf[4:]))
+		if n < 8 || n > 260 {
+			f.ADU, s.buf = s.buf, nil
+			fn(f)
+			return
+		}
+		if len(s.buf) < n {
+			return
+		}
+		f.ADU, s.buf = append([]byte(nil), s.buf[:n]...), s.buf[n:]
+		fn(f)
+	}
+}
@@ -51,7 +51,9 @@ func merge(idx []Index) []Index {
 	for _, idx := range idx[1:] {
 		switch {
 		case intersect(curr, idx):
-			curr.quantity = ceil(idx) - curr.address
+			if ceil(idx) > ceil(curr) {
+				curr.quantity = ceil(idx) - curr.address
+			}
 		default:
 			merged = append(merged, curr)
 			curr = index{address: idx.Address(), quantity: idx.Quantity()}
@@ -3,6 +3,7 @@ package sunspec
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 )
 
@@ -46,12 +47,13 @@ func (def *ModelDef) ID() uint16 {
 func (def *ModelDef) Instance(adr uint16, callback func(pts []Point) error) (Model, error) {
 	m := &model{}
 
-	var iterate func(def GroupDef) (Group, error)
+	var iterate func(def GroupDef, origin *group) (Group, error)
 
-	iterate = func(def GroupDef) (Group, error) {
+	iterate = func(def GroupDef, origin *group) (Group, error) {
 		g := &group{
 			name:   def.Name,
 			atomic: bool(def.Atomic),
+			origin: origin,
 		}
 		if m.group == nil {
 			m.group = g
@@ -67,9 +69,20 @@ func (def *ModelDef) Instance(adr uint16, callback func(pts []Point) error) (Mod
 				return nil, err
 			}
 		}
-		for _, def := range def.Groups {
-			for c := m.count(def.Count); c != 0; c-- {
-				x, err := iterate(def)
+		var dynamic []int
+		if m.group == g {
+			dynamic = def.dynamic()
+		}
+		for i, sub := range def.Groups {
+			c := m.count(sub.Count)
+			if len(dynamic) == 1 && dynamic[0] == i {
+				var err error
+				if c, err = m.derive(def, sub, c); err != nil {
+					return nil, err
+				}
+			}
+			for ; c != 0; c-- {
+				x, err := iterate(sub, g)
 				if err != nil {
 					return nil, err
 				}
@@ -79,9 +92,10 @@ func (def *ModelDef) Instance(adr uint16, callback func(pts []Point) error) (Mod
 		return g, nil
 	}
 
-	if _, err := iterate(def.Group); err != nil {
+	if _, err := iterate(def.Group, nil); err != nil {
 		return nil, err
 	}
+	resolve(m)
 
 	m.ID().Set(def.Id)
 	m.Length().Set(m.Quantity() - 2)
@@ -89,6 +103,19 @@ func (def *ModelDef) Instance(adr uint16, callback func(pts []Point) error) (Mod
 	return m, nil
 }
 
+// resolve looks up the scale factors of all points in the group ahead of time.
+// Otherwise the lazy lookup would change the points on first use, racing concurrent readers.
+func resolve(g Group) {
+	for _, p := range g.Points() {
+		if p, ok := p.(interface{ Factor() int16 }); ok {
+			p.Factor()
+		}
+	}
+	for _, g := range g.Groups() {
+		resolve(g)
+	}
+}
+
 // model is internally used to build out a usable model.
 type model struct{ *group }
 
@@ -130,6 +157,29 @@ func (m *model) count(c interface{}) uint16 {
 	return 1
 }
 
+// derive determines the occurrences of the repeating group sub from the model length L.
+// The count c, as referenced by the group, is only kept if it is consistent with the length.
+// If L is not yet known (zero) c is returned unchanged.
+func (m *model) derive(root, sub GroupDef, c uint16) (uint16, error) {
+	l := m.Length()
+	if l == nil || l.Get() == 0 {
+		return c, nil
+	}
+	size := int(sub.size(m.count))
+	if size == 0 {
+		return 0, fmt.Errorf("sunspec: repeating group %q has no size", sub.Name)
+	}
+	fixed := int(root.size(m.count)) - int(m.count(sub.Count))*size
+	available := int(l.Get()) + 2
+	if available < fixed {
+		return 0, fmt.Errorf("sunspec: model length %v is smaller than its fixed part of %v registers", l.Get(), fixed-2)
+	}
+	if (available-fixed)%size != 0 {
+		return 0, fmt.Errorf("sunspec: model length %v leaves %v registers, which is not divisible by the size %v of repeating group %q", l.Get(), available-fixed, size, sub.Name)
+	}
+	return uint16((available - fixed) / size), nil
+}
+
 // ID returns the models identifier as defined by the first point "ID".
 func (m *model) ID() Uint16 {
 	if id := m.Points().Point("ID"); id != nil {
@@ -181,6 +231,8 @@ func Verify(m Model) error {
 // Offering functionalities applicable for them.
 type Models []Model
 
+var _ Device = (Models)(nil)
+
 // First returns the first model from the collection.
 func (mls Models) First() Model { return mls[0] }
 
@@ -0,0 +1,64 @@
+package sunspec_test
+
+import (
+	"testing"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// bank is a model whose repeating group is counted by NStr, like the model 803.
+const bank = `{"id": 64050, "group": {"name": "bank", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64050},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "NStr", "type": "uint16", "size": 1},
+	{"name": "V", "type": "uint16", "size": 1}],
+	"groups": [{"name": "string", "type": "group", "count": "NStr", "points": [
+		{"name": "StrA", "type": "uint16", "size": 1},
+		{"name": "StrV", "type": "uint16", "size": 1},
+		{"name": "Tag", "type": "string", "size": 2}]}]}}`
+
+func TestDerive(t *testing.T) {
+	def := definition(t, bank)
+	testCases := []struct {
+		l, nstr uint16
+		want    int
+		fail    bool
+	}{
+		{l: 0, nstr: 2, want: 2},
+		{l: 0, nstr: 0, want: 0},
+		{l: 10, nstr: 2, want: 2},
+		{l: 2, nstr: 0, want: 0},
+		{l: 14, nstr: 2, want: 3},
+		{l: 6, nstr: 5, want: 1},
+		{l: 13, nstr: 2, fail: true},
+		{l: 1, nstr: 0, fail: true},
+	}
+	for _, tc := range testCases {
+		// the fixed points are read before the repeating groups are instantiated, like by a client
+		m, err := def.Instance(40002, func(pts []sunspec.Point) error {
+			for _, p := range pts {
+				switch p.Name() {
+				case "L":
+					p.(sunspec.Uint16).Set(tc.l)
+				case "NStr":
+					p.(sunspec.Uint16).Set(tc.nstr)
+				}
+			}
+			return nil
+		})
+		switch {
+		case tc.fail && err == nil:
+			t.Fatalf("instancing with L %v and NStr %v did not fail", tc.l, tc.nstr)
+		case !tc.fail && err != nil:
+			t.Fatalf("instancing with L %v and NStr %v failed: %v", tc.l, tc.nstr, err)
+		case tc.fail:
+			continue
+		}
+		if n := len(m.Groups("string")); n != tc.want {
+			t.Fatalf("instancing with L %v and NStr %v returned %v repeating groups; want %v", tc.l, tc.nstr, n, tc.want)
+		}
+		if l := m.Length().Get(); l != uint16(2+4*tc.want) {
+			t.Fatalf("instancing with L %v and NStr %v returned the length %v; want %v", tc.l, tc.nstr, l, 2+4*tc.want)
+		}
+	}
+}
@@ -66,6 +66,8 @@ func (c *Client) Request(ctx cancel.Context, code byte, req []byte) (res []byte,
 	}
 
 	sig := cancel.New().Propagate(ctx)
+	// release the propagation once the request is done
+	defer sig.Cancel()
 
 	wait := c.listen(sig, func(adu []byte, er error) (quit bool) {
 		if er != nil {
@@ -22,6 +22,9 @@ type Config struct {
 	Kind string
 	// Endpoint used for connecting to (client) or listening on (server)
 	Endpoint string
+	// Unit is the unit identifier addressed by the requests of a client.
+	// Servers answer with the unit identifier of the request.
+	Unit byte
 }
 
 // Verify validates the modbus.Options, thereby checking for invalid parameter.
@@ -46,7 +49,7 @@ func (cfg *Config) Verify() error {
 func (cfg Config) framer() framer {
 	switch cfg.Mode {
 	case "tcp":
-		return &tcp{}
+		return &tcp{id: cfg.Unit}
 	}
 	return nil
 }
@@ -108,12 +108,12 @@ func (c *network) listen(ctx cancel.Context, callback func(adu []byte, err error
 	e := c.l.PushFront(r)
 	go func() {
 		select {
-		case <-done:
+		case <-r.done:
 		case <-ctx.Done():
 			c.mu.Lock()
 			defer c.mu.Unlock()
 			select {
-			case <-done:
+			case <-r.done:
 			default:
 				c.l.Remove(e)
 				close(r.done)
@@ -13,12 +13,15 @@ type framer interface {
 	decode(adu []byte) (code byte, data []byte, err error)
 	verify(req, res []byte) (err error)
 	reply(code byte, data, req []byte) (res []byte, err error)
+	unit(adu []byte) byte
 }
 
 var _ framer = (*tcp)(nil)
 
 type tcp struct {
 	transid uint32
+	// addressed unit identifier of requests
+	id byte
 }
 
 func (s *tcp) buffer() []byte {
@@ -32,6 +35,7 @@ func (s *tcp) encode(code byte, data []byte) (adu []byte, err error) {
 	adu = s.buffer()
 	binary.BigEndian.PutUint16(adu[0:], uint16(atomic.AddUint32(&s.transid, 1)))
 	binary.BigEndian.PutUint16(adu[4:], 2+uint16(len(data)))
+	adu[6] = s.id
 	adu[7] = code
 	return adu[:8+copy(adu[8:], data)], nil
 }
@@ -62,7 +66,12 @@ func (s *tcp) reply(code byte, data, req []byte) (res []byte, err error) {
 	if res, err = s.encode(code, data); err != nil {
 		return nil, err
 	}
-	// copy transaction id from request
+	// copy transaction id and unit id from request
 	res[0], res[1] = req[0], req[1]
+	res[6] = req[6]
 	return res, nil
 }
+
+func (s *tcp) unit(adu []byte) byte {
+	return adu[6]
+}
@@ -12,6 +12,13 @@ type Handler interface {
 	Handle(ctx cancel.Context, code byte, req []byte) (res []byte, ex Exception)
 }
 
+// UnitHandler is optionally implemented by handlers serving multiple units, e.g. gateways.
+// If implemented, the server calls HandleUnit with the unit identifier of the request instead of Handle.
+type UnitHandler interface {
+	Handler
+	HandleUnit(ctx cancel.Context, unit, code byte, req []byte) (res []byte, ex Exception)
+}
+
 var _ Handler = (*Mux)(nil)
 
 // Mux implements the modbus.Handler interface and is intended to be used as a server side request
@@ -74,7 +74,11 @@ func (s *Server) handle(ctx cancel.Context, c connection, h Handler) {
 			case err != nil:
 				return
 			case code < 0x80:
-				res, ex = h.Handle(ctx, code, req)
+				if u, ok := h.(UnitHandler); ok {
+					res, ex = u.HandleUnit(ctx, s.unit(adu), code, req)
+				} else {
+					res, ex = h.Handle(ctx, code, req)
+				}
 			default:
 				ex = IllegalFunction
 			}
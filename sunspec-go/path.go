@@ -0,0 +1,175 @@
+package sunspec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment is a single element of a point path.
+// It identifies models, groups or points by their name and optional position.
+type segment struct {
+	name string
+	pos  int
+}
+
+// all is the position of a segment referencing all occurrences.
+const all = -1
+
+// parse splits the path into its segments.
+// A path is composed as follows:
+//	model[n].group[n].point
+// The model is identified by its id, groups and points by their names.
+// The position [n] is optional and selects the n-th occurrence, starting at 0.
+// If omitted or given as [*] all occurrences are referenced.
+// The wildcard * can be used instead of any identifier, referencing every element.
+// For instance "803.string[*].StrSoC" references the SoC of all strings in model 803.
+func parse(path string) ([]segment, error) {
+	elements := strings.Split(path, ".")
+	if len(elements) < 2 {
+		return nil, fmt.Errorf("sunspec: path %q must at least reference a model and a point", path)
+	}
+	seg := make([]segment, 0, len(elements))
+	for _, e := range elements {
+		s := segment{name: e, pos: all}
+		if i := strings.IndexByte(e, '['); i >= 0 {
+			if !strings.HasSuffix(e, "]") {
+				return nil, fmt.Errorf("sunspec: path %q contains an unterminated position", path)
+			}
+			s.name = e[:i]
+			if pos := e[i+1 : len(e)-1]; pos != "*" {
+				n, err := strconv.ParseUint(pos, 10, 16)
+				if err != nil {
+					return nil, fmt.Errorf("sunspec: path %q contains an invalid position %q", path, pos)
+				}
+				s.pos = int(n)
+			}
+		}
+		if s.name == "" {
+			return nil, fmt.Errorf("sunspec: path %q contains an empty element", path)
+		}
+		seg = append(seg, s)
+	}
+	if seg[0].name != "*" {
+		if _, err := strconv.ParseUint(seg[0].name, 10, 16); err != nil {
+			return nil, fmt.Errorf("sunspec: path %q must start with a model id", path)
+		}
+	}
+	return seg, nil
+}
+
+// match specifies whether the segment is referencing the given name.
+func (s segment) match(name string) bool {
+	return s.name == "*" || s.name == name
+}
+
+// selects determines whether the n-th occurrence is referenced by the segment.
+func (s segment) selects(n int) bool {
+	return s.pos == all || s.pos == n
+}
+
+// models returns all models in the collection referenced by the segment.
+func (s segment) models(mls Models) (col Models) {
+	var n int
+	for _, m := range mls {
+		if s.match(strconv.Itoa(int(m.ID().Get()))) {
+			if s.selects(n) {
+				col = append(col, m)
+			}
+			n++
+		}
+	}
+	return col
+}
+
+// groups returns all immediate sub-groups of the given groups referenced by the segment.
+func (s segment) groups(gps Groups) (col Groups) {
+	for _, g := range gps {
+		var n int
+		for _, g := range g.Groups() {
+			if s.match(g.Name()) {
+				if s.selects(n) {
+					col = append(col, g)
+				}
+				n++
+			}
+		}
+	}
+	return col
+}
+
+// points returns all immediate points of the given groups referenced by the segment.
+func (s segment) points(gps Groups) (col Points) {
+	for _, g := range gps {
+		var n int
+		for _, p := range g.Points() {
+			if s.match(p.Name()) {
+				if s.selects(n) {
+					col = append(col, p)
+				}
+				n++
+			}
+		}
+	}
+	return col
+}
+
+// Query returns all points in the collection referenced by the path.
+// For example "803.string[2].StrSoC" or "803.string[*].StrSoC".
+func (mls Models) Query(path string) (Points, error) {
+	seg, err := parse(path)
+	if err != nil {
+		return nil, err
+	}
+	var gps Groups
+	for _, m := range seg[0].models(mls) {
+		gps = append(gps, m)
+	}
+	for _, s := range seg[1 : len(seg)-1] {
+		gps = s.groups(gps)
+	}
+	pts := seg[len(seg)-1].points(gps)
+	if len(pts) == 0 {
+		return nil, fmt.Errorf("sunspec: path %q does not reference any points", path)
+	}
+	return pts, nil
+}
+
+// Value returns the scaled value of the single point referenced by the path.
+func (mls Models) Value(path string) (float64, error) {
+	pts, err := mls.Query(path)
+	if err != nil {
+		return 0, err
+	}
+	if len(pts) != 1 {
+		return 0, fmt.Errorf("sunspec: path %q is ambiguous, referencing %v points", path, len(pts))
+	}
+	return Scaled(pts[0])
+}
+
+// Path returns the path referencing the given point, e.g. "803.string[2].StrSoC".
+// Positions are only given for groups occurring multiple times in their parent.
+func Path(p Point) string {
+	elements := []string{p.Name()}
+	g := p.Origin()
+	for ; g != nil && g.Origin() != nil; g = g.Origin() {
+		e := g.Name()
+		if siblings := g.Origin().Groups(g.Name()); len(siblings) > 1 {
+			for i, s := range siblings {
+				if s == g {
+					e += "[" + strconv.Itoa(i) + "]"
+				}
+			}
+		}
+		elements = append(elements, e)
+	}
+	if g != nil {
+		if id, ok := g.Point("ID").(Uint16); ok {
+			elements = append(elements, strconv.Itoa(int(id.Get())))
+		}
+	}
+	for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+		elements[i], elements[j] = elements[j], elements[i]
+	}
+	return strings.Join(elements, ".")
+}
@@ -0,0 +1,149 @@
+package sunspec_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// repeating is a model with a repeating group of two strings and a single group.
+const repeating = `{"id": 64020, "group": {"name": "repeating", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64020},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "W", "type": "int16", "size": 1, "sf": "W_SF", "units": "W"},
+	{"name": "Hz", "type": "uint16", "size": 1, "sf": "Hz_SF", "units": "Hz"},
+	{"name": "W_SF", "type": "sunssf", "size": 1, "value": -1},
+	{"name": "Hz_SF", "type": "sunssf", "size": 1, "value": -2}],
+	"groups": [
+		{"name": "string", "type": "group", "count": 2, "points": [
+			{"name": "StrA", "type": "int16", "size": 1, "units": "A"}]},
+		{"name": "info", "type": "group", "points": [
+			{"name": "Tag", "type": "uint16", "size": 1}]}]}}`
+
+// instances returns two consecutive instances of the repeating model.
+func instances(t *testing.T) sunspec.Models {
+	t.Helper()
+	var def sunspec.ModelDef
+	if err := json.Unmarshal([]byte(repeating), &def); err != nil {
+		t.Fatal(err)
+	}
+	var mls sunspec.Models
+	for _, adr := range []uint16{0, 9} {
+		m, err := def.Instance(adr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mls = append(mls, m)
+	}
+	return mls
+}
+
+func TestQuery(t *testing.T) {
+	mls := instances(t)
+
+	testCases := []struct {
+		path string
+		want []string
+		fail bool
+	}{
+		{path: "64020[0].W", want: []string{"64020.W@2"}},
+		{path: "64020[1].W", want: []string{"64020.W@11"}},
+		{path: "64020.W", want: []string{"64020.W@2", "64020.W@11"}},
+		{path: "*.W", want: []string{"64020.W@2", "64020.W@11"}},
+		{path: "64020[*].info.Tag", want: []string{"64020.info.Tag@8", "64020.info.Tag@17"}},
+		{path: "64020[0].string.StrA", want: []string{"64020.string[0].StrA@6", "64020.string[1].StrA@7"}},
+		{path: "64020[0].string[*].StrA", want: []string{"64020.string[0].StrA@6", "64020.string[1].StrA@7"}},
+		{path: "64020[1].string[1].StrA", want: []string{"64020.string[1].StrA@16"}},
+		{path: "64020[0].*[1].StrA", want: []string{"64020.string[1].StrA@7"}},
+		{path: "64020[0].*.*", want: []string{"64020.string[0].StrA@6", "64020.string[1].StrA@7", "64020.info.Tag@8"}},
+		{path: "64020[1].*", want: []string{"64020.ID@9", "64020.L@10", "64020.W@11", "64020.Hz@12", "64020.W_SF@13", "64020.Hz_SF@14"}},
+		{path: "64020", fail: true},
+		{path: "W.W", fail: true},
+		{path: "64020[0.W", fail: true},
+		{path: "64020[x].W", fail: true},
+		{path: "64020[-1].W", fail: true},
+		{path: "64020..W", fail: true},
+		{path: "64020.[0].W", fail: true},
+		{path: "64020[2].W", fail: true},
+		{path: "64020.string[2].StrA", fail: true},
+		{path: "64021.W", fail: true},
+		{path: "64020.Var", fail: true},
+	}
+
+	for _, tc := range testCases {
+		pts, err := mls.Query(tc.path)
+		switch {
+		case tc.fail && err == nil:
+			t.Fatalf("query %q returned %v points; want error", tc.path, len(pts))
+		case !tc.fail && err != nil:
+			t.Fatalf("query %q failed: %v", tc.path, err)
+		case tc.fail:
+			continue
+		}
+		var got []string
+		for _, p := range pts {
+			got = append(got, fmt.Sprintf("%v@%v", sunspec.Path(p), p.Address()))
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("query %q returned %v; want %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	mls := instances(t)
+	w := mls[1].Point("W").(interface{ SetValue(float64) error })
+	if err := w.SetValue(-12.5); err != nil {
+		t.Fatal(err)
+	}
+	mls[0].Point("Hz").(sunspec.Uint16).Set(0xFFFF)
+
+	testCases := []struct {
+		path string
+		want float64
+		fail bool
+	}{
+		{path: "64020[1].W", want: -12.5},
+		{path: "64020[0].W", want: 0},
+		{path: "64020[1].Hz", want: 0},
+		{path: "64020[0].Hz", fail: true},
+		{path: "64020.W", fail: true},
+		{path: "64020[0].string.StrA", fail: true},
+		{path: "64020[0].Var", fail: true},
+	}
+
+	for _, tc := range testCases {
+		v, err := mls.Value(tc.path)
+		switch {
+		case tc.fail && err == nil:
+			t.Fatalf("value of %q returned %v; want error", tc.path, v)
+		case !tc.fail && err != nil:
+			t.Fatalf("value of %q failed: %v", tc.path, err)
+		case !tc.fail && v != tc.want:
+			t.Fatalf("value of %q returned %v; want %v", tc.path, v, tc.want)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	mls := instances(t)
+	for _, p := range mls[1].Points() {
+		pts, err := mls.Query(sunspec.Path(p))
+		if err != nil {
+			t.Fatalf("path %q of %v does not resolve: %v", sunspec.Path(p), p.Name(), err)
+		}
+		if len(pts) != 2 || pts[1] != p {
+			t.Fatalf("path %q of %v resolves to %v points", sunspec.Path(p), p.Name(), len(pts))
+		}
+	}
+	for _, g := range mls[0].Groups("string") {
+		p := g.Point("StrA")
+		pts, err := mls.Query(sunspec.Path(p))
+		if err != nil || len(pts) != 2 || pts[0] != p {
+			t.Fatalf("path %q of the string does not resolve to its point: %v", sunspec.Path(p), err)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package sunspec
 
+import "time"
+
 // Point defines the generic behavior all sunspec types have in common.
 type Point interface {
 	// Index defines the locality of the point in a modbus address space.
@@ -14,6 +16,26 @@ type Point interface {
 	Static() bool
 	// Writable specifies whether the point can be written to.
 	Writable() bool
+	// Unit returns the engineering unit of the point´s scaled value, e.g. "W" or "%".
+	// If the point has no unit an empty string is returned.
+	Unit() string
+	// Label returns the point´s human readable name.
+	Label() string
+	// Description returns the point´s human readable description.
+	Description() string
+	// Updated returns the time the point´s value was last received.
+	// If the value was never received the zero time is returned.
+	Updated() time.Time
+	// Err returns the error of the last attempt to receive the point´s value, if any.
+	Err() error
+	// Quality describes the trustworthiness of the point´s value.
+	Quality() Quality
+	// Limit returns the constraint of the point´s scaled value.
+	Limit() Limit
+	// constrain sets the constraint of the point´s scaled value.
+	constrain(l Limit)
+	// track records the outcome of an attempt to receive the point´s value.
+	track(err error, valid bool)
 	// encode puts the point´s value into a buffer.
 	encode(buf []byte) error
 	// decode sets the point´s value from a buffer.
@@ -29,6 +51,8 @@ type PointDef struct {
 	Size        uint16      `json:"size"`
 	ScaleFactor interface{} `json:"sf,omitempty"`
 	Units       string      `json:"units,omitempty"`
+	Min         *float64    `json:"min,omitempty"`
+	Max         *float64    `json:"max,omitempty"`
 	Writable    writable    `json:"access,omitempty"`
 	Mandatory   mandatory   `json:"mandatory,omitempty"`
 	Static      static      `json:"static,omitempty"`
@@ -42,11 +66,15 @@ type PointDef struct {
 
 func (def *PointDef) Instance(adr uint16, o Group) Point {
 	p := point{
-		name:     def.Name,
-		static:   bool(def.Static),
-		writable: bool(def.Writable),
-		origin:   o,
-		address:  adr,
+		name:        def.Name,
+		unit:        def.Units,
+		label:       def.Label,
+		description: def.Description,
+		limit:       Limit{Min: def.Min, Max: def.Max},
+		static:      bool(def.Static),
+		writable:    bool(def.Writable),
+		origin:      o,
+		address:     adr,
 	}
 	f := scale{def.ScaleFactor}
 	s := make(Symbols, len(def.Symbols))
@@ -106,11 +134,18 @@ func (def *PointDef) Instance(adr uint16, o Group) Point {
 
 // point is internally used to build out a useable model
 type point struct {
-	name     string
-	origin   Group
-	static   bool
-	writable bool
-	address  uint16
+	name        string
+	unit        string
+	label       string
+	description string
+	origin      Group
+	static      bool
+	writable    bool
+	address     uint16
+	updated     time.Time
+	err         error
+	valid       bool
+	limit       Limit
 }
 
 // Address returns the modbus starting address of the point.
@@ -125,6 +160,48 @@ func (p *point) Writable() bool { return p.writable }
 // Origin returns the point´s associated group
 func (p *point) Origin() Group { return p.origin }
 
+// Unit returns the engineering unit of the point´s scaled value.
+func (p *point) Unit() string { return p.unit }
+
+// Label returns the point´s human readable name.
+func (p *point) Label() string { return p.label }
+
+// Description returns the point´s human readable description.
+func (p *point) Description() string { return p.description }
+
+// Updated returns the time the point´s value was last received.
+func (p *point) Updated() time.Time { return p.updated }
+
+// Err returns the error of the last attempt to receive the point´s value, if any.
+func (p *point) Err() error { return p.err }
+
+// Limit returns the constraint of the point´s scaled value.
+func (p *point) Limit() Limit { return p.limit }
+
+// constrain sets the constraint of the point´s scaled value.
+func (p *point) constrain(l Limit) { p.limit = l }
+
+// Quality describes the trustworthiness of the point´s value.
+func (p *point) Quality() Quality {
+	switch {
+	case p.updated.IsZero():
+		return QualityInvalid
+	case p.err != nil:
+		return QualityStale
+	case !p.valid:
+		return QualityNotImplemented
+	}
+	return QualityGood
+}
+
+// track records the outcome of an attempt to receive the point´s value.
+// On success the time of the update is set, otherwise the previous value is considered outdated.
+func (p *point) track(err error, valid bool) {
+	if p.err = err; err == nil {
+		p.updated, p.valid = time.Now(), valid
+	}
+}
+
 // Static specifies whether the points underlying data is supposed to be constant,
 // meaning it is not supposed to change over time.
 func (p *point) Static() bool { return p.static }
@@ -195,14 +272,25 @@ func (pts Points) index() Index {
 }
 
 // decode sets the value for all points in the collection as stored in the buffer.
-func (pts Points) decode(buf []byte) error {
+// The outcome is tracked by each point, n is the number of points decoded before an error.
+func (pts Points) decode(buf []byte) (n int, err error) {
 	for _, p := range pts {
 		if err := p.decode(buf); err != nil {
-			return err
+			p.track(err, false)
+			return n, err
 		}
+		p.track(nil, p.Valid())
 		buf = buf[2*p.Quantity():]
+		n++
+	}
+	return n, nil
+}
+
+// fail marks the values of all points in the collection as outdated, due to the error.
+func (pts Points) fail(err error) {
+	for _, p := range pts {
+		p.track(err, false)
 	}
-	return nil
 }
 
 // encode puts the values of the points in the collection into the buffer.
@@ -0,0 +1,205 @@
+package sunspec
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/GoAethereal/modbus"
+)
+
+// Proxy is a person in the middle between modbus clients and a sunspec device.
+// It relays all requests to the device and manipulates the traffic according to its rules.
+// The layout of the device is retrieved by its own scan, so rules reference the points by path.
+//
+//	p := sunspec.NewProxy(sunspec.Config{Endpoint: ":502"}, sunspec.Config{Endpoint: "battery:502"})
+//	p.AddRule(sunspec.Rule{Path: "803.ModTmpAvg", Action: sunspec.ActionReplace, Value: -10})
+//	p.Serve(ctx, defs...)
+type Proxy struct {
+	mb       *modbus.Server
+	upstream *Client
+	logger   Logger
+	rules    *RuleSet
+	tap      func(req, res []byte)
+	// mu guards the transaction ids of the tap
+	mu      sync.Mutex
+	transid uint16
+}
+
+// NewProxy instantiates a new proxy listening on the endpoint of o and relaying to the device of upstream.
+// Requests are relayed to the unit given by upstream.
+func NewProxy(o Config, upstream Config) *Proxy {
+	return &Proxy{
+		mb: (modbus.Config{
+			Mode:     "tcp",
+			Kind:     "tcp",
+			Endpoint: o.Endpoint,
+		}).Server(),
+		upstream: upstream.Client(),
+		logger:   o.logger(),
+		rules:    &RuleSet{logger: o.logger()},
+	}
+}
+
+// AddRule adds the rule to the proxy, rules are applied in the order they were added.
+// Rules may be added before and while serving.
+func (p *Proxy) AddRule(r Rule) error {
+	return p.rules.Add(r)
+}
+
+// SetRules replaces all rules of the proxy. On error the previous rules are kept.
+func (p *Proxy) SetRules(rules ...Rule) error {
+	return p.rules.Set(rules...)
+}
+
+// Rules returns the rules of the proxy.
+func (p *Proxy) Rules() []Rule {
+	return p.rules.Rules()
+}
+
+// Watch loads the rules of the proxy from the file and reloads them whenever the file is modified,
+// checking every interval until the context is canceled.
+// An error is returned if the rules can not be loaded initially. Invalid modifications are logged
+// and the previous rules are kept.
+func (p *Proxy) Watch(ctx cancel.Context, name string, interval time.Duration) error {
+	var mod time.Time
+	load := func() error {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if fi.ModTime().Equal(mod) {
+			return nil
+		}
+		mod = fi.ModTime()
+		rules, err := LoadRules(name)
+		if err != nil {
+			return err
+		}
+		p.logger.Info("loaded rules from", name)
+		return p.SetRules(rules...)
+	}
+	if err := load(); err != nil {
+		return err
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			if err := load(); err != nil {
+				p.logger.Error("failed to reload rules:", err)
+			}
+		}
+	}
+}
+
+// Tap registers fn to observe the traffic between the clients and the proxy, e.g. for intrusion detection.
+// It is called for every handled request with the application data units as received from and responded to the client.
+// As the headers are not exposed by the modbus server, the transaction ids are assigned by the proxy.
+// The response of a dropped request is nil. Tap must be called before serving.
+func (p *Proxy) Tap(fn func(req, res []byte)) {
+	p.tap = fn
+}
+
+// Serve connects to the device, scans it using the given definitions and relays requests until the context is canceled.
+func (p *Proxy) Serve(ctx cancel.Context, defs ...Definition) error {
+	if err := p.upstream.Connect(); err != nil {
+		return err
+	}
+	defer p.upstream.Disconnect()
+	if err := p.upstream.Scan(ctx, defs...); err != nil {
+		return err
+	}
+	if err := p.rules.bind(p.upstream.Device); err != nil {
+		return err
+	}
+	return p.mb.Serve(ctx, (*relay)(p))
+}
+
+// relay implements the modbus handler of a proxy.
+type relay Proxy
+
+var _ modbus.UnitHandler = (*relay)(nil)
+
+// Handle relays the request as addressed to the unit 0.
+func (h *relay) Handle(ctx cancel.Context, code byte, req []byte) (res []byte, ex modbus.Exception) {
+	return h.HandleUnit(ctx, 0, code, req)
+}
+
+// HandleUnit relays the request to the device, applying the rules to the request and its response.
+func (h *relay) HandleUnit(ctx cancel.Context, unit, code byte, req []byte) (res []byte, ex modbus.Exception) {
+	p := (*Proxy)(h)
+	var dropped bool
+	if p.tap != nil {
+		p.mu.Lock()
+		p.transid++
+		hdr := []byte{byte(p.transid >> 8), byte(p.transid), 0, 0, 0, 0, unit}
+		p.mu.Unlock()
+		in := frame(hdr, code, req)
+		defer func() {
+			switch {
+			case dropped:
+				p.tap(in, nil)
+			case ex != 0:
+				p.tap(in, frame(hdr, code|0x80, []byte{byte(ex)}))
+			default:
+				p.tap(in, frame(hdr, code, res))
+			}
+		}()
+	}
+	x := &exchange{unit: unit, code: code, req: req}
+	v := p.rules.inbound(x)
+	if res, ex, ok := p.settle(ctx, v); ok {
+		dropped = v.drop
+		return res, ex
+	}
+	x.res, x.ex = p.forward(ctx, code, x.req)
+	v = p.rules.outbound(x)
+	if res, ex, ok := p.settle(ctx, v); ok {
+		dropped = v.drop
+		return res, ex
+	}
+	return x.res, x.ex
+}
+
+// settle delays the response as given by the verdict.
+// If the verdict answers the request, ok is true and the response or exception is returned.
+// Dropped requests are held until the context is canceled, so that they are never responded in time.
+func (p *Proxy) settle(ctx cancel.Context, v verdict) (res []byte, ex modbus.Exception, ok bool) {
+	if v.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, modbus.SlaveDeviceFailure, true
+		case <-time.After(v.delay):
+		}
+	}
+	switch {
+	case v.drop:
+		<-ctx.Done()
+		return nil, modbus.SlaveDeviceFailure, true
+	case v.ex != 0:
+		return nil, v.ex, true
+	case v.replay != nil:
+		return v.replay, 0, true
+	}
+	return nil, 0, false
+}
+
+// forward sends the request to the device.
+func (p *Proxy) forward(ctx cancel.Context, code byte, req []byte) ([]byte, modbus.Exception) {
+	res, err := p.upstream.relay(ctx, code, req)
+	var ex modbus.Exception
+	switch {
+	case err == nil:
+		return res, 0
+	case errors.As(err, &ex):
+		return nil, ex
+	}
+	p.logger.Error("proxy failed to relay request with function code", code, ":", err)
+	return nil, modbus.GatewayTargetDeviceFailedToRespond
+}
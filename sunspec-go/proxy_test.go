@@ -0,0 +1,135 @@
+package sunspec_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+func TestProxy(t *testing.T) {
+	def := definition(t, pair)
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	endpoint, s := serve(t, ctx, sunspec.Config{}, def)
+	if err := s.Update(func(d sunspec.Device) error { return set(d, 10) }); err != nil {
+		t.Fatal(err)
+	}
+	device := sunspec.Config{Endpoint: endpoint}
+	listen := sunspec.Config{Endpoint: free(t)}
+
+	p := sunspec.NewProxy(listen, device)
+	if err := p.AddRule(sunspec.Rule{Path: "64001.sync.B", Action: sunspec.ActionFreeze, Reads: true}); err != nil {
+		t.Fatal(err)
+	}
+	tapped := make(chan []byte, 64)
+	p.Tap(func(req, res []byte) {
+		select {
+		case tapped <- res:
+		default:
+		}
+	})
+	go p.Serve(ctx, def)
+
+	c := listen.Client()
+	wait(t, c.Connect)
+	defer c.Disconnect()
+	if err := c.Scan(ctx, def); err != nil {
+		t.Fatal(err)
+	}
+	sync := c.Model(64001).Group("sync")
+	a, b := sync.Point("A").(sunspec.Uint16), sync.Point("B").(sunspec.Uint16)
+	read := func() {
+		t.Helper()
+		if _, err := c.Read(ctx, sync); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the frozen value is reported while the device changes
+	if err := s.Update(func(d sunspec.Device) error { return set(d, 20) }); err != nil {
+		t.Fatal(err)
+	}
+	read()
+	if a.Get() != 20 || b.Get() != 10 {
+		t.Errorf("expected values 20 and 10, got %v and %v", a.Get(), b.Get())
+	}
+	// the tap observes the manipulated response as relayed to the client
+	var res []byte
+	for len(tapped) > 0 {
+		res = <-tapped
+	}
+	if len(res) != 13 || res[7] != 0x03 || res[10] != 20 || res[12] != 10 {
+		t.Errorf("expected the tapped response of values 20 and 10, got % x", res)
+	}
+
+	// replaced and offset values
+	if err := p.SetRules(
+		sunspec.Rule{Path: "64001.sync.A", Action: sunspec.ActionReplace, Value: 5, Reads: true},
+		sunspec.Rule{Path: "64001.sync.*", Action: sunspec.ActionOffset, Value: 1, Reads: true},
+	); err != nil {
+		t.Fatal(err)
+	}
+	read()
+	if a.Get() != 6 || b.Get() != 21 {
+		t.Errorf("expected values 6 and 21, got %v and %v", a.Get(), b.Get())
+	}
+
+	// written values are manipulated on their way to the device
+	r, err := sunspec.ParseRule("write 64001.sync.B := 99")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetRules(r); err != nil {
+		t.Fatal(err)
+	}
+	a.Set(30)
+	b.Set(30)
+	if _, err := c.Write(ctx, sync); err != nil {
+		t.Fatal(err)
+	}
+	var va, vb float64
+	s.View(func(d sunspec.Device) error {
+		va, _ = d.Value("64001.sync.A")
+		vb, _ = d.Value("64001.sync.B")
+		return nil
+	})
+	if va != 30 || vb != 99 {
+		t.Errorf("expected the device to receive 30 and 99, got %v and %v", va, vb)
+	}
+
+	// rules are reloaded once their file is modified
+	name := filepath.Join(t.TempDir(), "proxy.rules")
+	if err := os.WriteFile(name, []byte("read 64001.sync.A := 5\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	go p.Watch(ctx, name, 10*time.Millisecond)
+	for retry := 0; ; retry++ {
+		read()
+		if a.Get() == 5 {
+			break
+		} else if retry == 50 {
+			t.Fatalf("expected the loaded rule to apply, got %v", a.Get())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := os.WriteFile(name, []byte("read 64001.sync.A := 7\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	for retry := 0; ; retry++ {
+		read()
+		if a.Get() == 7 {
+			break
+		} else if retry == 50 {
+			t.Fatalf("expected the reloaded rule to apply, got %v", a.Get())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := p.AddRule(sunspec.Rule{Path: "64001.unknown", Action: sunspec.ActionDrop}); err == nil {
+		t.Error("expected an error adding a rule of an unknown point")
+	}
+}
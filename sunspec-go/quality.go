@@ -0,0 +1,30 @@
+package sunspec
+
+// Quality describes the trustworthiness of a point´s value as received from a device.
+type Quality int
+
+const (
+	// QualityInvalid signals that the value was never received.
+	QualityInvalid Quality = iota
+	// QualityGood signals that the value was received by the last read.
+	QualityGood
+	// QualityStale signals that the last read failed and the value is outdated.
+	QualityStale
+	// QualityNotImplemented signals that the device reported the value as not implemented.
+	QualityNotImplemented
+)
+
+// String returns a human readable representation of the quality.
+func (q Quality) String() string {
+	switch q {
+	case QualityInvalid:
+		return "invalid"
+	case QualityGood:
+		return "good"
+	case QualityStale:
+		return "stale"
+	case QualityNotImplemented:
+		return "not implemented"
+	}
+	return "unknown"
+}
@@ -0,0 +1,98 @@
+package sunspec_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// measurement is a model of scaled measurements.
+const measurement = `{"id": 64080, "group": {"name": "measurement", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64080},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "W", "type": "int16", "size": 1, "sf": "W_SF"},
+	{"name": "VA", "type": "int16", "size": 1, "sf": "W_SF"},
+	{"name": "W_SF", "type": "sunssf", "size": 1},
+	{"name": "Hz", "type": "uint16", "size": 1}]}}`
+
+func TestQuality(t *testing.T) {
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	def := definition(t, measurement)
+
+	// a value never received is invalid
+	m, err := def.Instance(40002, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := m.Point("W"); p.Quality() != sunspec.QualityInvalid || !p.Updated().IsZero() || p.Err() != nil {
+		t.Errorf("expected the quality %v, got %v updated at %v with error %v", sunspec.QualityInvalid, p.Quality(), p.Updated(), p.Err())
+	}
+
+	endpoint, s := serve(t, ctx, sunspec.Config{}, def)
+	if err := s.Update(func(d sunspec.Device) error {
+		m := d.Model(64080)
+		m.Point("W").(sunspec.Int16).Set(1234)
+		m.Point("VA").(sunspec.Int16).Set(-0x8000)
+		m.Point("Hz").(sunspec.Uint16).Set(5000)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c := sunspec.Config{Endpoint: endpoint}.Client()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if err := c.Scan(ctx, def); err != nil {
+		t.Fatal(err)
+	}
+	m = c.Model(64080)
+	w, va, sf, hz := m.Point("W"), m.Point("VA"), m.Point("W_SF"), m.Point("Hz")
+
+	// received values are good, unless not implemented
+	if _, err := c.Read(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if w.Quality() != sunspec.QualityGood || w.Updated().IsZero() || w.Err() != nil {
+		t.Errorf("expected the quality %v, got %v updated at %v with error %v", sunspec.QualityGood, w.Quality(), w.Updated(), w.Err())
+	}
+	if va.Quality() != sunspec.QualityNotImplemented {
+		t.Errorf("expected the quality %v, got %v", sunspec.QualityNotImplemented, va.Quality())
+	}
+	updated := hz.Updated()
+
+	// values of a failed read become stale, keeping the time of their last update
+	if err := s.InjectFault(sunspec.Fault{Name: "busy", Kind: sunspec.FaultException, Path: "64080.Hz", Reads: true, Exception: modbus.SlaveDeviceBusy}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Read(ctx, m); !errors.Is(err, modbus.SlaveDeviceBusy) {
+		t.Fatalf("expected exception %v, got %v", modbus.SlaveDeviceBusy, err)
+	}
+	if hz.Quality() != sunspec.QualityStale || !errors.Is(hz.Err(), modbus.SlaveDeviceBusy) || !hz.Updated().Equal(updated) {
+		t.Errorf("expected the quality %v updated at %v, got %v updated at %v with error %v", sunspec.QualityStale, updated, hz.Quality(), hz.Updated(), hz.Err())
+	}
+
+	// points decoded before an invalid value of the same response stay good
+	s.RemoveFault("busy")
+	if err := s.InjectFault(sunspec.Fault{Name: "sf", Kind: sunspec.FaultReplace, Path: "64080.W_SF", Value: 20}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Read(ctx, m); err == nil {
+		t.Fatal("expected an error reading an invalid scale factor")
+	}
+	if w.Quality() != sunspec.QualityGood || w.Err() != nil {
+		t.Errorf("expected the quality %v, got %v with error %v", sunspec.QualityGood, w.Quality(), w.Err())
+	}
+	for _, p := range []sunspec.Point{sf, hz} {
+		if p.Quality() != sunspec.QualityStale || p.Err() == nil {
+			t.Errorf("expected the quality %v of %v, got %v with error %v", sunspec.QualityStale, p.Name(), p.Quality(), p.Err())
+		}
+	}
+	if s := sunspec.QualityNotImplemented.String(); s != "not implemented" {
+		t.Errorf("expected the quality to be described as %q, got %q", "not implemented", s)
+	}
+}
@@ -25,11 +25,20 @@ func (r *request) Writing() bool { return r.writing }
 
 // Ingest updates the affected point values in accordance to the request.
 // For read only requests no change is applied to the points.
+// The update is applied all-or-nothing, on error the previous values are restored.
 func (r *request) Ingest() error {
 	if !r.Writing() {
 		return nil
 	}
-	return r.points.decode(r.buffer)
+	backup := make([]byte, len(r.buffer))
+	if err := r.points.encode(backup); err != nil {
+		return err
+	}
+	if _, err := r.points.decode(r.buffer); err != nil {
+		r.points.decode(backup)
+		return err
+	}
+	return nil
 }
 
 // Points returns all points that are affected by the request.
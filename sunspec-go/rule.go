@@ -0,0 +1,761 @@
+package sunspec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoAethereal/modbus"
+)
+
+// Action defines how a rule manipulates the traffic of a device.
+// The actions up to ActionFreeze manipulate the values of points, the others the complete request.
+type Action int
+
+const (
+	// ActionPass relays the value unchanged.
+	ActionPass Action = iota
+	// ActionReplace rewrites the scaled value by the rule´s value.
+	ActionReplace
+	// ActionOffset adds the rule´s value to the scaled value.
+	ActionOffset
+	// ActionScale multiplies the scaled value by the rule´s value.
+	ActionScale
+	// ActionFreeze replaces the value by the one the point had when the rule took effect.
+	ActionFreeze
+	// ActionDrop drops the complete request, which is never responded.
+	ActionDrop
+	// ActionReplay responds the last response to the same request from before the rule matched,
+	// writes are acknowledged without being relayed.
+	ActionReplay
+	// ActionDelay delays the response by the rule´s delay.
+	ActionDelay
+	// ActionException responds the rule´s modbus exception.
+	ActionException
+)
+
+// String returns a human readable representation of the action.
+func (a Action) String() string {
+	switch a {
+	case ActionPass:
+		return "pass"
+	case ActionReplace:
+		return "replace"
+	case ActionOffset:
+		return "offset"
+	case ActionScale:
+		return "scale"
+	case ActionFreeze:
+		return "freeze"
+	case ActionDrop:
+		return "drop"
+	case ActionReplay:
+		return "replay"
+	case ActionDelay:
+		return "delay"
+	case ActionException:
+		return "exception"
+	}
+	return "unknown"
+}
+
+// Predicate compares the scaled value of a point in transit, e.g. {">", 30}.
+// The empty predicate holds for any value.
+type Predicate struct {
+	// Op is one of "<", "<=", ">", ">=", "==" and "!=".
+	Op    string
+	Value float64
+}
+
+// holds returns whether the value satisfies the predicate.
+func (p Predicate) holds(v float64) bool {
+	switch p.Op {
+	case "":
+		return true
+	case "<":
+		return v < p.Value
+	case "<=":
+		return v <= p.Value
+	case ">":
+		return v > p.Value
+	case ">=":
+		return v >= p.Value
+	case "==":
+		return v == p.Value
+	case "!=":
+		return v != p.Value
+	}
+	return false
+}
+
+// Rule manipulates the traffic of a device matching all of its restrictions.
+type Rule struct {
+	// Path references the points the rule applies to, e.g. "803.ModTmpAvg".
+	// Rules without a path apply to complete requests only.
+	Path string
+	// When restricts the rule to the points whose value in transit satisfies the predicate.
+	When Predicate
+	// Reads and Writes restrict the rule to the responses of read or the values of write requests.
+	// If neither is set, both are affected.
+	Reads, Writes bool
+	// Code and Unit restrict the rule to requests of the function code and unit id, zero matches any.
+	Code, Unit uint8
+	// Address and Quantity restrict the rule to requests intersecting the address range.
+	// A quantity of zero matches any request.
+	Address, Quantity uint16
+	// Action is the manipulation of the traffic.
+	Action Action
+	// Value is the value of ActionReplace, ActionOffset and ActionScale.
+	Value float64
+	// Delay is the delay of ActionDelay.
+	Delay time.Duration
+	// Exception is responded by ActionException.
+	Exception modbus.Exception
+}
+
+// ParseRule parses the textual representation of a rule. It consists of its restrictions followed by the action:
+//
+//	read                   restricts the rule to reads, "write" to writes
+//	code 0x03              restricts the rule to the function code
+//	unit 1                 restricts the rule to the unit id
+//	address 40070-40080    restricts the rule to requests intersecting the address range
+//	803.ModTmpAvg          restricts the rule to requests of the path´s points
+//	803.ModTmpAvg > 30     further restricts the rule to values satisfying the comparison <, <=, >, >=, == or !=
+//
+// The action is one of:
+//
+//	:= -10           rewrites the value
+//	+= 5             adds to the value, "-=" subtracts from it
+//	*= 0.5           scales the value
+//	freeze           freezes the value
+//	pass             relays the value unchanged
+//	drop             drops the request
+//	replay           responds the last response to the request
+//	delay 500ms      delays the response
+//	exception 6      responds the modbus exception
+//
+// For example "read unit 1 803.ModTmpAvg > 30 := 25" or "write code 0x10 address 40000-40200 exception 6".
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+	fail := func(format string, args ...interface{}) (Rule, error) {
+		return Rule{}, fmt.Errorf("sunspec: invalid rule %q: %v", s, fmt.Sprintf(format, args...))
+	}
+	fields := strings.Fields(s)
+	// arg returns the argument of the current keyword
+	arg := func() (string, bool) {
+		if len(fields) < 2 {
+			return "", false
+		}
+		a := fields[1]
+		fields = fields[2:]
+		return a, true
+	}
+	action := false
+	for len(fields) > 0 && !action {
+		switch f := fields[0]; f {
+		case "read", "write":
+			r.Reads, r.Writes = r.Reads || f == "read", r.Writes || f == "write"
+			fields = fields[1:]
+		case "code", "unit":
+			a, ok := arg()
+			n, err := strconv.ParseUint(a, 0, 8)
+			if !ok || err != nil {
+				return fail("%v requires a number from 0 to 255", f)
+			}
+			if f == "code" {
+				r.Code = uint8(n)
+			} else {
+				r.Unit = uint8(n)
+			}
+		case "address":
+			a, ok := arg()
+			if !ok {
+				return fail("address requires a range")
+			}
+			from, to := a, a
+			if i := strings.Index(a, "-"); i >= 0 {
+				from, to = a[:i], a[i+1:]
+			}
+			x, err1 := strconv.ParseUint(from, 0, 16)
+			y, err2 := strconv.ParseUint(to, 0, 16)
+			if err1 != nil || err2 != nil || y < x || y-x >= math.MaxUint16 {
+				return fail("invalid address range %q", a)
+			}
+			r.Address, r.Quantity = uint16(x), uint16(y-x+1)
+		case "<", "<=", ">", ">=", "==", "!=":
+			a, ok := arg()
+			v, err := strconv.ParseFloat(a, 64)
+			if !ok || err != nil {
+				return fail("%v requires a number", f)
+			}
+			r.When = Predicate{Op: f, Value: v}
+		case ":=", "+=", "-=", "*=":
+			a, ok := arg()
+			v, err := strconv.ParseFloat(a, 64)
+			if !ok || err != nil {
+				return fail("%v requires a number", f)
+			}
+			r.Action, r.Value, action = map[string]Action{":=": ActionReplace, "+=": ActionOffset, "-=": ActionOffset, "*=": ActionScale}[f], v, true
+			if f == "-=" {
+				r.Value = -v
+			}
+		case "pass", "freeze", "drop", "replay":
+			r.Action, action = map[string]Action{"pass": ActionPass, "freeze": ActionFreeze, "drop": ActionDrop, "replay": ActionReplay}[f], true
+			fields = fields[1:]
+		case "delay":
+			a, ok := arg()
+			d, err := time.ParseDuration(a)
+			if !ok || err != nil || d < 0 {
+				return fail("delay requires a duration")
+			}
+			r.Action, r.Delay, action = ActionDelay, d, true
+		case "exception":
+			a, ok := arg()
+			n, err := strconv.ParseUint(a, 0, 8)
+			if !ok || err != nil || n == 0 {
+				return fail("exception requires a code from 1 to 255")
+			}
+			r.Action, r.Exception, action = ActionException, modbus.Exception(n), true
+		default:
+			if r.Path != "" {
+				return fail("unexpected %q", f)
+			}
+			r.Path, fields = f, fields[1:]
+		}
+	}
+	switch {
+	case !action:
+		return fail("missing action")
+	case len(fields) > 0:
+		return fail("unexpected %q after the action", strings.Join(fields, " "))
+	}
+	if err := r.validate(); err != nil {
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+// String returns the textual representation of the rule as parsed by ParseRule.
+func (r Rule) String() string {
+	var col []string
+	switch {
+	case r.Reads && !r.Writes:
+		col = append(col, "read")
+	case r.Writes && !r.Reads:
+		col = append(col, "write")
+	}
+	if r.Code != 0 {
+		col = append(col, fmt.Sprintf("code 0x%02X", r.Code))
+	}
+	if r.Unit != 0 {
+		col = append(col, "unit "+strconv.Itoa(int(r.Unit)))
+	}
+	switch {
+	case r.Quantity == 1:
+		col = append(col, "address "+strconv.Itoa(int(r.Address)))
+	case r.Quantity > 1:
+		col = append(col, fmt.Sprintf("address %v-%v", r.Address, int(r.Address)+int(r.Quantity)-1))
+	}
+	if r.Path != "" {
+		col = append(col, r.Path)
+	}
+	if r.When.Op != "" {
+		col = append(col, r.When.Op, strconv.FormatFloat(r.When.Value, 'g', -1, 64))
+	}
+	switch r.Action {
+	case ActionReplace:
+		col = append(col, ":=", strconv.FormatFloat(r.Value, 'g', -1, 64))
+	case ActionOffset:
+		col = append(col, "+=", strconv.FormatFloat(r.Value, 'g', -1, 64))
+	case ActionScale:
+		col = append(col, "*=", strconv.FormatFloat(r.Value, 'g', -1, 64))
+	case ActionDelay:
+		col = append(col, "delay", r.Delay.String())
+	case ActionException:
+		col = append(col, "exception", strconv.Itoa(int(r.Exception)))
+	default:
+		col = append(col, r.Action.String())
+	}
+	return strings.Join(col, " ")
+}
+
+// validate checks the consistency of the rule.
+func (r Rule) validate() error {
+	switch {
+	case r.Action < ActionPass || r.Action > ActionException:
+		return fmt.Errorf("sunspec: invalid rule %q: unknown action %v", r, int(r.Action))
+	case r.Path == "" && r.Action <= ActionFreeze:
+		return fmt.Errorf("sunspec: invalid rule %q: the action %v requires a path", r, r.Action)
+	case r.Path == "" && r.When.Op != "":
+		return fmt.Errorf("sunspec: invalid rule %q: the predicate requires a path", r)
+	case !r.When.valid():
+		return fmt.Errorf("sunspec: invalid rule %q: unknown comparison %q", r, r.When.Op)
+	}
+	if r.Path != "" {
+		if _, err := parse(r.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valid returns whether the comparison of the predicate is known.
+func (p Predicate) valid() bool {
+	switch p.Op {
+	case "", "<", "<=", ">", ">=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+// LoadRules reads rules from a text file, one rule per line.
+// Empty lines and lines starting with # are ignored.
+func LoadRules(name string) ([]Rule, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := ParseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %w", name, n, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, sc.Err()
+}
+
+// RuleSet evaluates rules in order against the requests and responses of a device.
+// The values in transit are decoded using the layout of the device, so rules reference points by path
+// and respect their scale factors.
+type RuleSet struct {
+	mu     sync.Mutex
+	device Device
+	rules  []*rule
+	// last holds the latest responses by request for replaying
+	last   map[string][]byte
+	logger Logger
+}
+
+// rule is a rule of a set with its resolved points.
+type rule struct {
+	Rule
+	pts Points
+	// frozen holds the encoded values of the points when the rule took effect
+	frozen map[Point][]byte
+}
+
+// Verdict is the outcome of evaluating a rule set against a request and its response.
+type Verdict struct {
+	// Request and Response are the resulting application data units.
+	Request, Response []byte
+	// Delay is the delay of the response.
+	Delay time.Duration
+	// Drop signals that the response is withheld.
+	Drop bool
+	// Replayed signals that a previous response is responded instead.
+	Replayed bool
+	// Exception is responded instead of the device´s response, if set.
+	Exception modbus.Exception
+	// Matched are the rules applied.
+	Matched []Rule
+}
+
+// verdict is the outcome of evaluating a rule set against an exchange.
+type verdict struct {
+	delay   time.Duration
+	drop    bool
+	ex      modbus.Exception
+	replay  []byte
+	matched []Rule
+}
+
+// exchange is a request and its response, if already known.
+// Both are given as protocol data without the function code.
+type exchange struct {
+	unit, code byte
+	req, res   []byte
+	ex         modbus.Exception
+}
+
+// NewRuleSet returns a rule set for the device, which may be nil if bound later, e.g. by a proxy.
+func NewRuleSet(d Device, rules ...Rule) (*RuleSet, error) {
+	rs := &RuleSet{logger: logger{}}
+	if err := rs.bind(d); err != nil {
+		return nil, err
+	}
+	return rs, rs.Set(rules...)
+}
+
+// Add appends the rule to the set.
+func (rs *RuleSet) Add(r Rule) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	x := &rule{Rule: r}
+	if err := x.resolve(rs.device); err != nil {
+		return err
+	}
+	rs.rules = append(rs.rules, x)
+	rs.logger.Info("added rule", r)
+	return nil
+}
+
+// Set replaces all rules of the set. On error the previous rules are kept.
+func (rs *RuleSet) Set(rules ...Rule) error {
+	col := make([]*rule, len(rules))
+	for i, r := range rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+		col[i] = &rule{Rule: r}
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for _, r := range col {
+		if err := r.resolve(rs.device); err != nil {
+			return err
+		}
+	}
+	rs.rules = col
+	rs.logger.Info("set", len(col), "rules")
+	return nil
+}
+
+// Rules returns the rules of the set.
+func (rs *RuleSet) Rules() []Rule {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	col := make([]Rule, len(rs.rules))
+	for i, r := range rs.rules {
+		col[i] = r.Rule
+	}
+	return col
+}
+
+// Apply evaluates the rules against a recorded request and its response, both modbus-TCP application data units.
+// The response may be nil, e.g. if it was never received. Writes are evaluated before and reads after the response,
+// as done by a proxy relaying them. The verdict holds the resulting units and the actions taken.
+func (rs *RuleSet) Apply(req, res []byte) (Verdict, error) {
+	if len(req) < 8 || res != nil && len(res) < 9 {
+		return Verdict{}, errors.New("sunspec: invalid application data unit")
+	}
+	x := &exchange{unit: req[6], code: req[7], req: append([]byte(nil), req[8:]...)}
+	v := rs.inbound(x)
+	if !v.final() && res != nil {
+		if res[7] >= 0x80 {
+			x.ex = modbus.Exception(res[8])
+		} else {
+			x.res = append([]byte(nil), res[8:]...)
+		}
+		w := rs.outbound(x)
+		w.delay, w.matched = w.delay+v.delay, append(v.matched, w.matched...)
+		v = w
+	}
+	out := Verdict{Request: frame(req, x.code, x.req), Delay: v.delay, Drop: v.drop, Exception: v.ex, Matched: v.matched}
+	hdr := res
+	if hdr == nil {
+		hdr = req
+	}
+	switch {
+	case v.drop:
+	case v.ex != 0:
+		out.Response = frame(hdr, x.code|0x80, []byte{byte(v.ex)})
+	case v.replay != nil:
+		out.Response, out.Replayed = frame(hdr, x.code, v.replay), true
+	case res != nil && x.ex != 0:
+		out.Response = frame(hdr, x.code|0x80, []byte{byte(x.ex)})
+	case res != nil:
+		out.Response = frame(hdr, x.code, x.res)
+	}
+	return out, nil
+}
+
+// frame returns the application data unit of the protocol data, using the header of adu.
+func frame(adu []byte, code byte, data []byte) []byte {
+	b := make([]byte, 8+len(data))
+	copy(b, adu[:7])
+	binary.BigEndian.PutUint16(b[4:], uint16(2+len(data)))
+	b[7] = code
+	copy(b[8:], data)
+	return b
+}
+
+// bind resolves the rules for the device.
+func (rs *RuleSet) bind(d Device) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.device = d
+	for _, r := range rs.rules {
+		if err := r.resolve(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve determines the points of the rule and freezes their current values.
+// Without a device the points remain unresolved.
+func (r *rule) resolve(d Device) error {
+	if r.Path == "" || d == nil {
+		return nil
+	}
+	pts, err := d.Query(r.Path)
+	if err != nil {
+		return fmt.Errorf("sunspec: invalid rule %q: %w", r.Rule, err)
+	}
+	r.pts, r.frozen = pts, make(map[Point][]byte, len(pts))
+	for _, pt := range pts {
+		buf := make([]byte, 2*pt.Quantity())
+		if err := pt.encode(buf); err != nil {
+			return err
+		}
+		r.frozen[pt] = buf
+	}
+	return nil
+}
+
+// final returns whether the verdict answers the request without relaying it.
+func (v verdict) final() bool {
+	return v.drop || v.ex != 0 || v.replay != nil
+}
+
+// writing returns whether the function code writes to the device.
+func writing(code byte) bool {
+	switch code {
+	case 0x05, 0x06, 0x0F, 0x10:
+		return true
+	}
+	return false
+}
+
+// span returns the address range of the request and its register values, if any.
+func (x *exchange) span() (idx index, values []byte, ok bool) {
+	req := x.req
+	switch x.code {
+	case 0x01, 0x02, 0x03, 0x04, 0x0F, 0x10:
+		if len(req) < 4 {
+			return idx, nil, false
+		}
+		idx = index{address: binary.BigEndian.Uint16(req), quantity: binary.BigEndian.Uint16(req[2:])}
+	case 0x05, 0x06:
+		if len(req) < 4 {
+			return idx, nil, false
+		}
+		idx = index{address: binary.BigEndian.Uint16(req), quantity: 1}
+	default:
+		return idx, nil, false
+	}
+	switch {
+	case x.code == 0x03 && len(x.res) == 1+2*int(idx.quantity):
+		values = x.res[1:]
+	case x.code == 0x06:
+		values = req[2:4]
+	case x.code == 0x10 && len(req) == 5+2*int(idx.quantity):
+		values = req[5:]
+	}
+	return idx, values, true
+}
+
+// key identifies the request for replaying its response.
+func (x *exchange) key() string {
+	req := x.req
+	if len(req) > 4 {
+		req = req[:4]
+	}
+	return string([]byte{x.unit, x.code}) + string(req)
+}
+
+// inbound evaluates the rules against a write request before it is relayed.
+func (rs *RuleSet) inbound(x *exchange) verdict {
+	if !writing(x.code) {
+		return verdict{}
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.evaluate(x, true)
+}
+
+// outbound evaluates the rules against the response of a read request and records responses for replaying.
+func (rs *RuleSet) outbound(x *exchange) verdict {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if writing(x.code) {
+		rs.record(x, x.res)
+		return verdict{}
+	}
+	genuine := append([]byte(nil), x.res...)
+	v := rs.evaluate(x, false)
+	if v.replay == nil {
+		rs.record(x, genuine)
+	}
+	return v
+}
+
+// record stores the response for replaying.
+func (rs *RuleSet) record(x *exchange, res []byte) {
+	if x.ex != 0 || res == nil {
+		return
+	}
+	if rs.last == nil {
+		rs.last = make(map[string][]byte)
+	}
+	rs.last[x.key()] = res
+}
+
+// evaluate applies the rules to the exchange.
+// The register values in transit are decoded into the device first, so that their scale factors are respected.
+func (rs *RuleSet) evaluate(x *exchange, write bool) (v verdict) {
+	idx, values, ranged := x.span()
+	if values != nil && rs.device != nil {
+		Decode(rs.device, idx.Address(), values)
+	}
+	replayed := false
+	for _, r := range rs.rules {
+		hits, ok := r.matches(x, write, idx, ranged, values != nil)
+		if !ok {
+			continue
+		}
+		v.matched = append(v.matched, r.Rule)
+		switch r.Action {
+		case ActionDrop:
+			v.drop = true
+		case ActionDelay:
+			v.delay += r.Delay
+		case ActionException:
+			if !v.final() {
+				v.ex = r.Exception
+			}
+		case ActionReplay:
+			if v.final() || replayed {
+				continue
+			}
+			replayed = true
+			if last, ok := rs.last[x.key()]; ok {
+				v.replay = append([]byte(nil), last...)
+			} else if writing(x.code) && len(x.req) >= 4 {
+				// writes never recorded are acknowledged by the echo of their address and quantity or value
+				v.replay = append([]byte(nil), x.req[:4]...)
+			} else {
+				rs.logger.Warn("rule", r.Rule, "has no response to replay for function code", x.code)
+			}
+		default:
+			for _, pt := range hits {
+				off := 2 * int(pt.Address()-idx.Address())
+				if err := r.apply(pt); err != nil {
+					rs.logger.Warn("rule", r.Rule, "failed for", Path(pt), ":", err)
+					continue
+				}
+				if err := pt.encode(values[off:]); err != nil {
+					rs.logger.Warn("rule", r.Rule, "failed for", Path(pt), ":", err)
+				}
+			}
+		}
+		rs.logger.Info("rule", r.Rule, "applied to function code", x.code, "of unit", x.unit)
+	}
+	return v
+}
+
+// matches returns whether the rule applies to the exchange and the points of its path manipulated in transit.
+func (r *rule) matches(x *exchange, write bool, idx Index, ranged, values bool) (hits Points, ok bool) {
+	switch {
+	case write && r.Reads && !r.Writes, !write && r.Writes && !r.Reads:
+		return nil, false
+	case r.Code != 0 && r.Code != x.code, r.Unit != 0 && r.Unit != x.unit:
+		return nil, false
+	case r.Quantity > 0 && (!ranged || !intersect(idx, index{address: r.Address, quantity: r.Quantity})):
+		return nil, false
+	case r.Path == "":
+		return nil, true
+	case !ranged:
+		return nil, false
+	}
+	var touched bool
+	for _, pt := range r.pts {
+		if !intersect(idx, pt) {
+			continue
+		}
+		touched = true
+		if !values || pt.Address() < idx.Address() || ceil(pt) > ceil(idx) {
+			continue
+		}
+		if r.When.Op != "" {
+			v, err := Scaled(pt)
+			if err != nil || !r.When.holds(v) {
+				continue
+			}
+		}
+		hits = append(hits, pt)
+	}
+	// without a predicate, requests of the path´s points match even if their values are unknown
+	if r.When.Op == "" {
+		return hits, touched
+	}
+	return hits, len(hits) > 0
+}
+
+// apply manipulates the value of the point.
+func (r *rule) apply(pt Point) error {
+	switch r.Action {
+	case ActionReplace:
+		return setScaled(pt, r.Value)
+	case ActionOffset, ActionScale:
+		v, err := Scaled(pt)
+		if err != nil {
+			return err
+		}
+		if r.Action == ActionScale {
+			return setScaled(pt, v*r.Value)
+		}
+		return setScaled(pt, v+r.Value)
+	case ActionFreeze:
+		return pt.decode(r.frozen[pt])
+	}
+	return nil
+}
+
+// setScaled sets the numeric value of the point with its scale factor applied, reversing Scaled.
+func setScaled(p Point, v float64) error {
+	raw := func(sf int16) float64 { return math.Round(v * math.Pow10(-int(sf))) }
+	switch p := p.(type) {
+	case interface{ SetValue(v float64) error }:
+		return p.SetValue(v)
+	case Acc16:
+		return p.Set(uint16(raw(p.Factor())))
+	case Acc32:
+		return p.Set(uint32(raw(p.Factor())))
+	case Acc64:
+		return p.Set(uint64(raw(p.Factor())))
+	case Enum16:
+		return p.Set(uint16(v))
+	case Enum32:
+		return p.Set(uint32(v))
+	case Bitfield16:
+		return p.Set(uint16(v))
+	case Bitfield32:
+		return p.Set(uint32(v))
+	case Bitfield64:
+		return p.Set(uint64(v))
+	case Float32:
+		return p.Set(float32(v))
+	case Float64:
+		return p.Set(v)
+	}
+	return fmt.Errorf("sunspec: point %q is not numeric", p.Name())
+}
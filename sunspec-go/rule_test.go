@@ -0,0 +1,178 @@
+package sunspec_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+func TestParseRule(t *testing.T) {
+	for s, r := range map[string]sunspec.Rule{
+		"803.ModTmpAvg := -10":        {Path: "803.ModTmpAvg", Action: sunspec.ActionReplace, Value: -10},
+		"read 802.SoC += 5":           {Path: "802.SoC", Action: sunspec.ActionOffset, Value: 5, Reads: true},
+		"802.SoC -= 5":                {Path: "802.SoC", Action: sunspec.ActionOffset, Value: -5},
+		"802.W *= 0.5":                {Path: "802.W", Action: sunspec.ActionScale, Value: 0.5},
+		"write 704.WMaxLimPct drop":   {Path: "704.WMaxLimPct", Action: sunspec.ActionDrop, Writes: true},
+		"803.string[*].StrSoC freeze": {Path: "803.string[*].StrSoC", Action: sunspec.ActionFreeze},
+		"read unit 1 803.ModTmpAvg > 30 := 25": {
+			Path: "803.ModTmpAvg", When: sunspec.Predicate{Op: ">", Value: 30}, Reads: true, Unit: 1, Action: sunspec.ActionReplace, Value: 25,
+		},
+		"write code 0x10 address 40000-40199 exception 6": {
+			Writes: true, Code: 0x10, Address: 40000, Quantity: 200, Action: sunspec.ActionException, Exception: modbus.SlaveDeviceBusy,
+		},
+		"address 40070 delay 1.5s": {Address: 40070, Quantity: 1, Action: sunspec.ActionDelay, Delay: 1500 * time.Millisecond},
+		"code 3 replay":            {Code: 3, Action: sunspec.ActionReplay},
+	} {
+		x, err := sunspec.ParseRule(s)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+		} else if x != r {
+			t.Errorf("%q: expected %+v, got %+v", s, r, x)
+		}
+		// the textual representation parses into the same rule
+		if y, err := sunspec.ParseRule(x.String()); err != nil || y != x {
+			t.Errorf("%q: expected %+v, got %+v (%v)", x.String(), x, y, err)
+		}
+	}
+	for _, s := range []string{
+		"", "802.SoC", "802.SoC /= 2", "802.SoC := high", "802.SoC melt", "802..SoC drop",
+		"802.SoC := 1 drop", "code 300 drop", "address 10-5 drop", "freeze", "read > 3 drop", "delay soon",
+	} {
+		if _, err := sunspec.ParseRule(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "attack.rules")
+	content := "# manipulated temperatures\n\nread 803.ModTmpAvg := -10\n  write 704.WMaxLimPct freeze\n"
+	if err := os.WriteFile(name, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := sunspec.LoadRules(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[1].Action != sunspec.ActionFreeze {
+		t.Errorf("expected 2 rules, got %v", rules)
+	}
+	if err := os.WriteFile(name, []byte(content+"803.ModTmpAvg melt\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sunspec.LoadRules(name); err == nil || !strings.Contains(err.Error(), ":5:") {
+		t.Errorf("expected an error for line 5, got %v", err)
+	}
+}
+
+// adu decodes the hex representation of a recorded application data unit.
+func adu(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRuleSet(t *testing.T) {
+	var def sunspec.ModelDef
+	if err := json.Unmarshal([]byte(pair), &def); err != nil {
+		t.Fatal(err)
+	}
+	m, err := def.Instance(40002, func(pts []sunspec.Point) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := sunspec.Models{m}
+
+	// recorded transactions of the unit 1, reading and writing A (40004) and B (40005)
+	read := adu(t, "0001 0000 0006 01 03 9c44 0002")
+	response := adu(t, "0001 0000 0007 01 03 04 000a 0014")
+	later := adu(t, "0002 0000 0007 01 03 04 001e 0028")
+	write := adu(t, "0003 0000 000b 01 10 9c44 0002 04 0003 0004")
+	ack := adu(t, "0003 0000 0006 01 10 9c44 0002")
+
+	for _, c := range []struct {
+		rule      string
+		req, res  []byte
+		expectReq []byte
+		expectRes []byte
+		matched   int
+	}{
+		{"read 64001.sync.B > 15 := 0", read, response, read, adu(t, "0001 0000 0007 01 03 04 000a 0000"), 1},
+		{"read 64001.sync.B > 25 := 0", read, response, read, response, 0},
+		{"64001.sync.* += 1", read, response, read, adu(t, "0001 0000 0007 01 03 04 000b 0015"), 1},
+		{"write 64001.sync.A *= 2", write, ack, adu(t, "0003 0000 000b 01 10 9c44 0002 04 0006 0004"), ack, 1},
+		{"read 64001.sync.A *= 2", write, ack, write, ack, 0},
+		{"unit 2 delay 1s", read, response, read, response, 0},
+		{"address 40000-40003 exception 6", read, response, read, response, 0},
+		{"address 40004 exception 6", read, response, read, adu(t, "0001 0000 0003 01 83 06"), 1},
+		{"write code 0x10 drop", write, nil, write, nil, 1},
+	} {
+		r, err := sunspec.ParseRule(c.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs, err := sunspec.NewRuleSet(d, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := rs.Apply(c.req, c.res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(v.Request, c.expectReq) || !bytes.Equal(v.Response, c.expectRes) {
+			t.Errorf("%q: expected % x and % x, got % x and % x", c.rule, c.expectReq, c.expectRes, v.Request, v.Response)
+		}
+		if len(v.Matched) != c.matched {
+			t.Errorf("%q: expected %v matched rules, got %v", c.rule, c.matched, len(v.Matched))
+		}
+	}
+
+	// responses are replayed from the last response before the rule matched
+	rs, err := sunspec.NewRuleSet(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Apply(read, response); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Add(sunspec.Rule{Path: "64001.sync.A", Action: sunspec.ActionReplay}); err != nil {
+		t.Fatal(err)
+	}
+	v, err := rs.Apply(read, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Replayed || !bytes.Equal(v.Response[8:], response[8:]) || !bytes.Equal(v.Response[:2], later[:2]) {
+		t.Errorf("expected the replayed response % x, got % x", response, v.Response)
+	}
+
+	// writes never recorded are acknowledged without being relayed
+	rs, err = sunspec.NewRuleSet(d, sunspec.Rule{Path: "64001.sync.A", Action: sunspec.ActionReplay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err = rs.Apply(write, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !v.Replayed || !bytes.Equal(v.Response, ack) {
+		t.Errorf("expected the acknowledgement % x, got % x", ack, v.Response)
+	}
+
+	if _, err := sunspec.NewRuleSet(d, sunspec.Rule{Path: "64001.unknown", Action: sunspec.ActionDrop}); err == nil {
+		t.Error("expected an error for a rule of an unknown point")
+	}
+	if _, err := sunspec.NewRuleSet(d, sunspec.Rule{Action: sunspec.ActionReplace}); err == nil {
+		t.Error("expected an error for a value action without path")
+	}
+}
@@ -0,0 +1,158 @@
+package sunspec
+
+import (
+	"sync"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+)
+
+// Poll describes the outcome of a single cycle of the scheduler.
+type Poll struct {
+	// Points contains all points successfully read during the cycle.
+	// Their values should be accessed within a view of the client, see Client.View.
+	Points Points
+	// Started is the time the cycle began.
+	Started time.Time
+	// Latency is the duration required for all reads of the cycle.
+	Latency time.Duration
+	// Err is the error of the cycle, if any.
+	Err error
+}
+
+// Scheduler periodically reads registered indexes from the client´s device.
+// Indexes due at the same time are merged into the least number of modbus requests.
+// Static points are only read until they were successfully received once.
+type Scheduler struct {
+	client *Client
+	report func(p Poll)
+	wake   chan struct{}
+	mu     sync.Mutex
+	tasks  []*task
+	static map[Point]bool
+}
+
+// task is a set of indexes polled with the same interval.
+type task struct {
+	interval time.Duration
+	next     time.Time
+	idx      []Index
+}
+
+// Scheduler returns a new scheduler polling the client.
+// The optional report function is called after every cycle of the scheduler.
+func (c *Client) Scheduler(report func(p Poll)) *Scheduler {
+	if report == nil {
+		report = func(p Poll) {}
+	}
+	return &Scheduler{
+		client: c,
+		report: report,
+		wake:   make(chan struct{}, 1),
+		static: make(map[Point]bool),
+	}
+}
+
+// Register adds the indexes (e.g. models, groups or points) to the scheduler.
+// They are read for the first time immediately and subsequently every interval.
+func (s *Scheduler) Register(interval time.Duration, idx ...Index) {
+	if len(idx) == 0 || interval <= 0 {
+		return
+	}
+	s.mu.Lock()
+	s.tasks = append(s.tasks, &task{interval: interval, next: time.Now(), idx: idx})
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run executes the scheduler until the context is canceled.
+func (s *Scheduler) Run(ctx cancel.Context) error {
+	for {
+		var timer *time.Timer
+		var due <-chan time.Time
+		if next, ok := s.next(); ok {
+			timer = time.NewTimer(time.Until(next))
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-due:
+			if p, ok := s.poll(ctx, time.Now()); ok {
+				s.report(p)
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+// next returns the time the next task is due.
+func (s *Scheduler) next() (next time.Time, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tasks {
+		if !ok || t.next.Before(next) {
+			next, ok = t.next, true
+		}
+	}
+	return next, ok
+}
+
+// poll reads all indexes due at the given time and reschedules their tasks.
+// If nothing had to be read false is returned.
+func (s *Scheduler) poll(ctx cancel.Context, now time.Time) (p Poll, ok bool) {
+	p.Started = now
+	var idx []Index
+	s.mu.Lock()
+	for _, t := range s.tasks {
+		if t.next.After(now) {
+			continue
+		}
+		idx = append(idx, t.idx...)
+		if t.next = t.next.Add(t.interval); !t.next.After(now) {
+			t.next = now.Add(t.interval)
+		}
+	}
+	s.mu.Unlock()
+	if len(idx) == 0 {
+		return p, false
+	}
+
+	pts, err := collect(s.client, idx...)
+	if err != nil {
+		p.Err = err
+		return p, true
+	}
+	// skip static points already received, unless they are required for a sync group
+	var i int
+	for _, pt := range pts {
+		if g := pt.Origin(); s.static[pt] && (g == nil || !g.Atomic()) {
+			continue
+		}
+		pts[i] = pt
+		i++
+	}
+	if i == 0 {
+		return p, false
+	}
+
+	p.Points, p.Err = s.client.read(ctx, pts[:i]...)
+	p.Latency = time.Since(now)
+	s.client.notify(p.Points)
+	for _, pt := range p.Points {
+		if pt.Static() {
+			s.static[pt] = true
+		}
+	}
+	return p, true
+}
@@ -0,0 +1,120 @@
+package sunspec_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// nameplate is a model of measurements followed by a static point.
+const nameplate = `{"id": 64070, "group": {"name": "nameplate", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64070},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "W", "type": "int16", "size": 1},
+	{"name": "VA", "type": "int16", "size": 1},
+	{"name": "Hz", "type": "uint16", "size": 1},
+	{"name": "Mn", "type": "string", "size": 4, "static": "S"}]}}`
+
+func TestScheduler(t *testing.T) {
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	def := definition(t, nameplate)
+
+	// the address ranges of the read requests received by the server
+	var mu sync.Mutex
+	var reads [][2]uint16
+	handler := func(ctx cancel.Context, req sunspec.Request) error {
+		if pts := req.Points(); !req.Writing() && len(pts) > 0 {
+			mu.Lock()
+			reads = append(reads, [2]uint16{pts[0].Address(), pts[len(pts)-1].Address() + pts[len(pts)-1].Quantity()})
+			mu.Unlock()
+		}
+		return ingest(ctx, req)
+	}
+	received := func() [][2]uint16 {
+		mu.Lock()
+		defer mu.Unlock()
+		col := reads
+		reads = nil
+		return col
+	}
+	endpoint := free(t)
+	s := sunspec.Config{Endpoint: endpoint}.Server()
+	start(t, ctx, s, endpoint, handler, def)
+
+	c := sunspec.Config{Endpoint: endpoint}.Client()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if err := c.Scan(ctx, def); err != nil {
+		t.Fatal(err)
+	}
+	m := c.Model(64070)
+	received()
+
+	polls := make(chan sunspec.Poll, 16)
+	sched := c.Scheduler(func(p sunspec.Poll) { polls <- p })
+	next := func() sunspec.Poll {
+		t.Helper()
+		select {
+		case p := <-polls:
+			return p
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a poll of the scheduler")
+		}
+		return sunspec.Poll{}
+	}
+	// overlapping indexes of the same time are merged
+	sched.Register(100*time.Millisecond, m)
+	sched.Register(100*time.Millisecond, m.Point("W"), m.Point("VA"), m.Point("Mn"))
+	go sched.Run(ctx)
+
+	p := next()
+	if p.Err != nil {
+		t.Fatal(p.Err)
+	}
+	if len(p.Points) != len(m.Points()) {
+		t.Errorf("expected the %v points of the model to be read once, got %v", len(m.Points()), len(p.Points))
+	}
+	if p.Started.IsZero() || p.Latency <= 0 {
+		t.Errorf("expected the start and latency of the poll, got %v and %v", p.Started, p.Latency)
+	}
+	if r := received(); len(r) != 1 || r[0] != [2]uint16{m.Address(), m.Address() + m.Quantity()} {
+		t.Errorf("expected a single read request of the model, got %v", r)
+	}
+
+	// the static point is skipped once received
+	p = next()
+	if p.Err != nil {
+		t.Fatal(p.Err)
+	}
+	for _, pt := range p.Points {
+		if pt.Static() {
+			t.Errorf("expected the static point %v to be skipped", pt.Name())
+		}
+	}
+	if r := received(); len(r) != 1 || r[0] != [2]uint16{m.Address(), m.Point("Mn").Address()} {
+		t.Errorf("expected a single read request without the static point, got %v", r)
+	}
+
+	// failed reads are reported
+	if err := s.InjectFault(sunspec.Fault{Name: "busy", Kind: sunspec.FaultException, Reads: true, Exception: modbus.SlaveDeviceBusy}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; ; i++ {
+		if p = next(); errors.Is(p.Err, modbus.SlaveDeviceBusy) {
+			break
+		} else if i == 3 {
+			t.Fatalf("expected exception %v, got %v", modbus.SlaveDeviceBusy, p.Err)
+		}
+	}
+	if len(p.Points) != 0 {
+		t.Errorf("expected no points of the failed poll, got %v", len(p.Points))
+	}
+}
@@ -1,6 +1,10 @@
 package sunspec
 
 import (
+	"bytes"
+	"errors"
+	"sync"
+
 	"github.com/GoAethereal/cancel"
 	"github.com/GoAethereal/modbus"
 )
@@ -10,22 +14,44 @@ type Server struct {
 	server
 	models Models
 	logger Logger
+	limits Limits
+	store  Store
+	keys   map[Point]string
+	// hooks are the registered write hooks, intercepts the hooks by their resolved points.
+	hooks      []hook
+	intercepts map[Point][]WriteHook
+	// faults are injected into the responses, e.g. to test the robustness of clients.
+	faults faults
+	// mu guards the publication of the instantiated models and their guard.
+	mu    sync.Mutex
+	guard *guard
 }
 
 var _ Device = (*Server)(nil)
 
+// ErrNotServing is returned when accessing the values of a server, which is not serving yet.
+var ErrNotServing = errors.New("sunspec: the server is not serving any models yet")
+
 // Model returns the first model identifies by id.
 func (s *Server) Model(id uint16) Model { return s.models[1 : len(s.models)-1].Model(id) }
 
 // Models returns all models from the device.
 func (s *Server) Models(ids ...uint16) Models { return s.models[1 : len(s.models)-1].Models(ids...) }
 
+// Query returns all points referenced by the path, e.g. "803.string[*].StrSoC".
+func (s *Server) Query(path string) (Points, error) { return s.Models().Query(path) }
+
+// Value returns the scaled value of the single point referenced by the path.
+func (s *Server) Value(path string) (float64, error) { return s.Models().Value(path) }
+
 // Serve instantiates the model, as declared in the definition and starts serving it to connected clients.
 // The handler function is called for any incoming client request.
+// While handling a request all models affected by it are locked, so that the handler has exclusive
+// access to the requested points. Requests for other models are handled concurrently.
 func (s *Server) Serve(ctx cancel.Context, handler func(ctx cancel.Context, req Request) error, defs ...Definition) error {
 	// append the start marker
-	s.models = append(Models(nil), marker(0))
-	adr := ceil(s.models.First())
+	mls := append(Models(nil), marker(0))
+	adr := ceil(mls.First())
 	for _, def := range defs {
 		s.logger.Info("instantiating model definition", def.ID(), "at address", adr)
 		m, err := def.Instance(adr, func(pts []Point) error { return nil })
@@ -37,16 +63,159 @@ func (s *Server) Serve(ctx cancel.Context, handler func(ctx cancel.Context, req
 			return err
 		}
 		adr = ceil(m)
-		s.models = append(s.models, m)
+		mls = append(mls, m)
 	}
 	// append the endmarker
-	s.models = append(s.models, header(adr, 0xFFFF, 0))
+	mls = append(mls, header(adr, 0xFFFF, 0))
+	g, err := s.init(mls)
+	if err != nil {
+		return err
+	}
+	if err := s.faults.start(s); err != nil {
+		return err
+	}
+
+	return s.serve(ctx, mls, g, &s.faults, s.intercept, func(ctx cancel.Context, req Request) error {
+		if !req.Writing() {
+			return handler(ctx, req)
+		}
+		old := s.snapshot(req.Points())
+		if err := handler(ctx, req); err != nil {
+			return err
+		}
+		if err := s.persist(req.Points()); err != nil {
+			return err
+		}
+		s.applied(req.Points(), old)
+		return nil
+	})
+}
+
+// View allows reading the served point values outside of client requests.
+// The function fn is called while no client request or update is changing any value,
+// so that all values are consistent. Views may run concurrently to each other.
+// View must not be called from within a request handler.
+func (s *Server) View(fn func(d Device) error) error {
+	g, err := s.guarded()
+	if err != nil {
+		return err
+	}
+	defer g.lock(nil, false)()
+	return fn(s)
+}
+
+// Update allows changing the served point values outside of client requests,
+// for instance by a simulation or data ingest.
+// The function fn is called exclusively, no client request is processed meanwhile.
+// Changed values are persisted into the server´s store, if any.
+// Update must not be called from within a request handler.
+func (s *Server) Update(fn func(d Device) error) error {
+	g, err := s.guarded()
+	if err != nil {
+		return err
+	}
+	defer g.lock(nil, true)()
+	if s.store == nil {
+		return fn(s)
+	}
+	pts, err := collect(s, s.Models().Index()...)
+	if err != nil {
+		return err
+	}
+	before, err := values(pts, s.keys)
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	var changed Points
+	for _, p := range pts {
+		buf := make([]byte, 2*p.Quantity())
+		if err := p.encode(buf); err != nil {
+			return err
+		}
+		if !bytes.Equal(buf, before[s.keys[p]]) {
+			changed = append(changed, p)
+		}
+	}
+	return s.persist(changed)
+}
 
-	return s.serve(ctx, s.models, handler)
+// init publishes the instantiated models, applying the configured limits and stored values.
+func (s *Server) init(mls Models) (*guard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models = mls
+	if err := s.limits.apply(s); err != nil {
+		return nil, err
+	}
+	if err := s.restore(); err != nil {
+		return nil, err
+	}
+	if err := s.resolve(); err != nil {
+		return nil, err
+	}
+	s.guard = newGuard(mls)
+	return s.guard, nil
+}
+
+// guarded returns the guard of the served models.
+// An error is returned if the server is not serving yet.
+func (s *Server) guarded() (*guard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.guard == nil {
+		return nil, ErrNotServing
+	}
+	return s.guard, nil
+}
+
+// restore loads the previously persisted point values from the server´s store, if any.
+// Stored values no longer fitting their point are ignored.
+func (s *Server) restore() error {
+	if s.store == nil {
+		return nil
+	}
+	pts, err := collect(s, s.Models().Index()...)
+	if err != nil {
+		return err
+	}
+	s.keys = keys(pts)
+	stored, err := s.store.Load()
+	if err != nil {
+		return err
+	}
+	for _, p := range pts {
+		v, ok := stored[s.keys[p]]
+		if !ok {
+			continue
+		}
+		if len(v) != 2*int(p.Quantity()) {
+			s.logger.Warn("ignoring stored value of", s.keys[p], "due to mismatching size")
+			continue
+		}
+		if err := p.decode(v); err != nil {
+			s.logger.Warn("ignoring stored value of", s.keys[p], ":", err)
+		}
+	}
+	return nil
+}
+
+// persist saves the values of the points into the server´s store, if any.
+func (s *Server) persist(pts Points) error {
+	if s.store == nil || len(pts) == 0 {
+		return nil
+	}
+	values, err := values(pts, s.keys)
+	if err != nil {
+		return err
+	}
+	return s.store.Save(values)
 }
 
 type server interface {
-	serve(ctx cancel.Context, d Device, handler func(ctx cancel.Context, req Request) error) error
+	serve(ctx cancel.Context, d Device, g *guard, f *faults, intercept func(pts Points, values []byte) error, handler func(ctx cancel.Context, req Request) error) error
 }
 
 var _ server = (*mbServer)(nil)
@@ -67,11 +236,23 @@ func newModbusServer(endpoint string, l Logger) *mbServer {
 	}
 }
 
-func (s *mbServer) serve(ctx cancel.Context, d Device, handler func(ctx cancel.Context, req Request) error) error {
-	return s.mb.Serve(ctx, &modbus.Mux{
+func (s *mbServer) serve(ctx cancel.Context, d Device, g *guard, f *faults, intercept func(pts Points, values []byte) error, handler func(ctx cancel.Context, req Request) error) error {
+	return s.mb.Serve(ctx, mux(s.logger, d, g, f, intercept, handler))
+}
+
+// mux returns the modbus handler serving the device.
+func mux(l Logger, d Device, g *guard, f *faults, intercept func(pts Points, values []byte) error, handler func(ctx cancel.Context, req Request) error) *modbus.Mux {
+	return &modbus.Mux{
 		ReadHoldingRegisters: func(ctx cancel.Context, address, quantity uint16) (res []byte, ex modbus.Exception) {
-			s.logger.Debug("received modbus read request for address", address, "with quantity", quantity)
-			pts, err := collect(d, index{address: address, quantity: quantity})
+			l.Debug("received modbus read request for address", address, "with quantity", quantity)
+			idx := index{address: address, quantity: quantity}
+			faults := f.active(false, idx)
+			if ex, ok := f.before(ctx, l, faults, idx); !ok {
+				return nil, ex
+			}
+			// the handler may change point values even for read requests
+			defer g.lock(idx, true)()
+			pts, err := collect(d, idx)
 			if err != nil {
 				return nil, modbus.IllegalDataAddress
 			}
@@ -79,11 +260,17 @@ func (s *mbServer) serve(ctx cancel.Context, d Device, handler func(ctx cancel.C
 			if err := handler(ctx, req); err != nil {
 				return nil, modbus.SlaveDeviceFailure
 			}
+			f.after(l, faults, pts, req.buffer)
 			return req.buffer, 0
 		},
 		WriteMultipleRegisters: func(ctx cancel.Context, address uint16, values []byte) (ex modbus.Exception) {
-			s.logger.Debug("received modbus write request for address", address, "with payload", values)
-			pts, err := collect(d, index{address: address, quantity: uint16(len(values) * 2)})
+			l.Debug("received modbus write request for address", address, "with payload", values)
+			idx := index{address: address, quantity: uint16(len(values) / 2)}
+			if ex, ok := f.before(ctx, l, f.active(true, idx), idx); !ok {
+				return ex
+			}
+			defer g.lock(idx, true)()
+			pts, err := collect(d, idx)
 			if err != nil {
 				return modbus.IllegalDataAddress
 			}
@@ -93,11 +280,22 @@ func (s *mbServer) serve(ctx cancel.Context, d Device, handler func(ctx cancel.C
 					return modbus.IllegalDataAddress
 				}
 			}
+			// keep the previous values, so that a failed request leaves no partial changes (e.g. in sync groups)
+			backup := make([]byte, len(values))
+			if err := pts.encode(backup); err != nil {
+				return modbus.SlaveDeviceFailure
+			}
+			// refuse vetoed values or values violating the point´s constraints before passing them to the handler
+			if err := intercept(pts, values); err != nil {
+				l.Debug("refusing modbus write request for address", address, ":", err)
+				return exception(err)
+			}
 			req := &request{points: pts, writing: true, buffer: values}
 			if err := handler(ctx, req); err != nil {
+				pts.decode(backup)
 				return modbus.SlaveDeviceFailure
 			}
 			return 0
 		},
-	})
+	}
 }
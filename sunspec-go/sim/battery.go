@@ -0,0 +1,629 @@
+package sim
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// enumerated values of the battery models 802 and 803
+const (
+	chaStOff         = 1
+	chaStEmpty       = 2
+	chaStDischarging = 3
+	chaStCharging    = 4
+	chaStFull        = 5
+	chaStHolding     = 6
+
+	stateDisconnected  = 1
+	stateConnected     = 3
+	stateSoCProtection = 5
+	stateFault         = 99
+
+	setOpConnect    = 1
+	setOpDisconnect = 2
+
+	invStopped = 1
+	invStarted = 3
+
+	reqInvNone = 0
+	reqInvStop = 2
+
+	typLithiumIon = 4
+
+	strEnable     = 1
+	strDisable    = 2
+	strConnect    = 1
+	strDisconnect = 2
+
+	strStEnabled   = 0
+	strStContactor = 1
+
+	strConFailNone     = 0
+	strConFailDisabled = 4
+
+	strDisRsnNone        = 0
+	strDisRsnFault       = 1
+	strDisRsnMaintenance = 2
+)
+
+// bit positions of the battery events (Evt1 and StrEvt1)
+const (
+	evtOverTempAlarm               = 1
+	evtOverTempWarning             = 2
+	evtUnderTempWarning            = 4
+	evtOverChargeCurrentWarning    = 6
+	evtOverDischargeCurrentWarning = 8
+	evtUnderSoCMinWarning          = 14
+	evtOverSoCMaxWarning           = 16
+	evtTemperatureImbalanceWarning = 19
+)
+
+// Battery simulates a lithium-ion battery bank, served by the models 802 (battery base) and 803 (lithium-ion bank).
+//
+// The bank consists of parallel strings of series connected modules. The state of charge of each string integrates
+// its current, the voltage follows the open circuit voltage of the cells reduced by the internal resistance and the
+// module temperatures respond to the resistive losses and the ambient temperature.
+// The power flow is given by the demand, e.g. of an inverter, limited by the nameplate ratings.
+//
+// Clients control the bank by the writable points of the models: the bank is connected or disconnected by SetOp,
+// power only flows while SetInvState indicates a started inverter, the state of charge is kept within the limits
+// SoCMin, SoCRsvMin, SocRsvMax and SoCMax and strings are enabled or connected by StrSetEna and StrSetCon.
+// Latched alarms are reset by writing 1 to AlmRst.
+// Currents and powers are positive while discharging and negative while charging.
+type Battery struct {
+	// Strings is the number of parallel strings of the bank.
+	// It is overwritten by the number of strings of the served model 803.
+	Strings int
+	// Modules is the number of series connected modules per string.
+	Modules int
+	// Cells is the number of series connected cells per module.
+	Cells int
+	// Capacity is the nominal charge capacity of a single string in Ah.
+	Capacity float64
+	// Resistance is the internal resistance of a single cell in Ohm.
+	Resistance float64
+	// OCV is the open circuit voltage of a cell in V by its state of charge, ranging from 0 to 1.
+	OCV Curve
+	// MaxCharge and MaxDischarge are the nameplate power ratings of the bank in W.
+	MaxCharge, MaxDischarge float64
+	// HeatCapacity is the heat capacity of a single module in J/K.
+	HeatCapacity float64
+	// Cooling is the thermal conductance of a single module to the ambient in W/K.
+	Cooling float64
+	// Imbalance is the deviation of the extreme cell voltages from the average cell voltage in V.
+	Imbalance float64
+	// WarnTemp and AlarmTemp are the module temperatures in °C raising the over temperature warning and alarm.
+	// The alarm is latched and disconnects the bank until it is reset.
+	WarnTemp, AlarmTemp float64
+
+	mu       sync.Mutex
+	ready    bool
+	demand   float64
+	ambient  float64
+	strings  []cell
+	current  float64
+	voltage  float64
+	alarms   uint32
+	warnings uint32
+	uptime   float64
+	// throughput is the total charge transferred by the bank in Ah.
+	throughput float64
+	// the control state as written by the clients
+	connected, started bool
+	protected          bool
+	minSoC, maxSoC     float64
+}
+
+// cell is the simulated state of a single string.
+type cell struct {
+	soc, temp          float64
+	current, voltage   float64
+	enabled, connected bool
+	events             uint32
+}
+
+var _ Simulator = (*Battery)(nil)
+
+// NewBattery returns a battery bank of two strings with 144 cells each, rated at 100 Ah and 50 kW.
+func NewBattery() *Battery {
+	return &Battery{
+		Strings:    2,
+		Modules:    12,
+		Cells:      12,
+		Capacity:   100,
+		Resistance: 0.001,
+		// typical lithium nickel manganese cobalt oxide cell
+		OCV: Curve{
+			{0, 3.0}, {0.05, 3.3}, {0.1, 3.45}, {0.2, 3.55}, {0.3, 3.61}, {0.4, 3.66},
+			{0.5, 3.72}, {0.6, 3.8}, {0.7, 3.88}, {0.8, 3.96}, {0.9, 4.05}, {1, 4.15},
+		},
+		MaxCharge:    50000,
+		MaxDischarge: 50000,
+		HeatCapacity: 30000,
+		Cooling:      5,
+		Imbalance:    0.005,
+		WarnTemp:     45,
+		AlarmTemp:    55,
+		ambient:      25,
+	}
+}
+
+// SetDemand sets the power demanded from the bank in W, positive for discharging and negative for charging.
+func (b *Battery) SetDemand(w float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.demand = w
+}
+
+// SetAmbient sets the ambient temperature in °C.
+func (b *Battery) SetAmbient(c float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ambient = c
+}
+
+// Power returns the current DC power of the bank in W, positive for discharging and negative for charging.
+func (b *Battery) Power() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.voltage * b.current
+}
+
+// SoC returns the average state of charge of the bank, ranging from 0 to 1.
+func (b *Battery) SoC() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.soc()
+}
+
+// Prepare sets the scale factors of the models 802 and 803 and the number of strings of model 803.
+func (b *Battery) Prepare(defs ...sunspec.Definition) error {
+	if def := definition(defs, 802); def != nil {
+		scale(&def.Group, map[string]int16{
+			"AHRtg_SF": 0, "WHRtg_SF": 1, "WChaDisChaMax_SF": 1, "DisChaRte_SF": -2, "SoC_SF": -1, "DoD_SF": -1,
+			"SoH_SF": -1, "V_SF": -1, "CellV_SF": -3, "A_SF": -1, "AMax_SF": -1, "W_SF": 1,
+		})
+	}
+	if def := definition(defs, 803); def != nil {
+		if err := count(def, "string", b.Strings); err != nil {
+			return err
+		}
+		scale(&def.Group, map[string]int16{
+			"CellV_SF": -3, "ModTmp_SF": -1, "A_SF": -1, "SoH_SF": -1, "SoC_SF": -1, "V_SF": -1,
+		})
+	}
+	return nil
+}
+
+// Attach registers a write hook refusing inconsistent state of charge limits.
+// The limits must satisfy SoCMin <= SoCRsvMin <= SocRsvMax <= SoCMax within 0 and 100 %.
+func (b *Battery) Attach(s *sunspec.Server) {
+	h := sunspec.WriteHook{Validate: func(c sunspec.Change) error {
+		g, last := c.Point.Origin(), 0.0
+		for _, name := range [...]string{"SoCMin", "SoCRsvMin", "SocRsvMax", "SoCMax"} {
+			if v, ok := get(g, name); ok {
+				if v < last {
+					return modbus.IllegalDataValue
+				}
+				last = v
+			}
+		}
+		if last > 100 {
+			return modbus.IllegalDataValue
+		}
+		return nil
+	}}
+	for _, name := range [...]string{"SoCMin", "SoCRsvMin", "SocRsvMax", "SoCMax"} {
+		s.OnWrite("802."+name, h)
+	}
+}
+
+// Step advances the simulation by dt.
+func (b *Battery) Step(d sunspec.Device, dt time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	base, bank := model(d, 802), model(d, 803)
+	if base == nil {
+		return errors.New("sim: the battery requires the model 802")
+	}
+	var strs sunspec.Groups
+	if bank != nil {
+		strs = bank.Groups("string")
+	}
+	if !b.ready {
+		b.init(base, strs)
+	}
+	b.control(base, strs)
+	b.simulate(dt.Seconds())
+	return b.publish(base, bank, strs)
+}
+
+// init sets up the simulated state and the default setpoints.
+// Setpoints already given, e.g. restored from a store, are kept.
+func (b *Battery) init(base sunspec.Group, strs sunspec.Groups) {
+	if len(strs) > 0 {
+		b.Strings = len(strs)
+	}
+	b.strings = make([]cell, b.Strings)
+	for i := range b.strings {
+		b.strings[i] = cell{soc: 0.5, temp: b.ambient, enabled: true, connected: true}
+	}
+	defaults := func(g sunspec.Group, name string, v float64) {
+		if x, ok := get(g, name); ok && x == 0 {
+			set(g, name, v)
+		}
+	}
+	defaults(base, "SoCMax", 95)
+	defaults(base, "SocRsvMax", 90)
+	defaults(base, "SoCRsvMin", 10)
+	defaults(base, "SoCMin", 5)
+	defaults(base, "SetOp", setOpConnect)
+	defaults(base, "SetInvState", invStarted)
+	for _, g := range strs {
+		defaults(g, "StrSetEna", strEnable)
+		defaults(g, "StrSetCon", strConnect)
+	}
+	b.ready = true
+}
+
+// control reads the setpoints written by the clients.
+func (b *Battery) control(base sunspec.Group, strs sunspec.Groups) {
+	if v, ok := get(base, "AlmRst"); ok && v == 1 {
+		b.alarms = 0
+		for i := range b.strings {
+			b.strings[i].events &^= 1 << evtOverTempAlarm
+		}
+		set(base, "AlmRst", 0)
+	}
+	op, _ := get(base, "SetOp")
+	b.connected = op != setOpDisconnect && b.alarms == 0
+	inv, ok := get(base, "SetInvState")
+	b.started = !ok || inv == invStarted
+
+	b.minSoC, b.maxSoC = 0, 1
+	for _, name := range [...]string{"SoCMin", "SoCRsvMin"} {
+		if v, ok := get(base, name); ok {
+			b.minSoC = math.Max(b.minSoC, v/100)
+		}
+	}
+	for _, name := range [...]string{"SoCMax", "SocRsvMax"} {
+		if v, ok := get(base, name); ok && v > 0 {
+			b.maxSoC = math.Min(b.maxSoC, v/100)
+		}
+	}
+	for i, g := range strs {
+		if i >= len(b.strings) {
+			break
+		}
+		ena, _ := get(g, "StrSetEna")
+		con, _ := get(g, "StrSetCon")
+		b.strings[i].enabled = ena != strDisable
+		b.strings[i].connected = b.strings[i].enabled && con != strDisconnect
+	}
+}
+
+// series returns the number of series connected cells per string.
+func (b *Battery) series() float64 { return float64(b.Modules * b.Cells) }
+
+// soc returns the average state of charge of all strings.
+func (b *Battery) soc() float64 {
+	var sum float64
+	for _, s := range b.strings {
+		sum += s.soc
+	}
+	return sum / math.Max(1, float64(len(b.strings)))
+}
+
+// health returns the state of health, ranging from 0 to 1.
+// The capacity is assumed to fade linearly by 0.01 % per cycle.
+func (b *Battery) health() float64 {
+	return math.Max(0, 1-b.cycles()*1e-4)
+}
+
+// cycles returns the number of full equivalent cycles.
+func (b *Battery) cycles() float64 {
+	return b.throughput / (2 * b.Capacity * math.Max(1, float64(len(b.strings))))
+}
+
+// active returns the indexes of all strings carrying current.
+func (b *Battery) active() (idx []int) {
+	if !b.connected || !b.started {
+		return nil
+	}
+	for i, s := range b.strings {
+		if s.connected {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// simulate advances the physical state by dt seconds.
+func (b *Battery) simulate(dt float64) {
+	idx := b.active()
+	rs := b.Resistance * b.series()
+	capacity := b.Capacity * b.health()
+
+	// the current shared equally by the active strings, solving P = (Voc - I*R/n) * I
+	var current float64
+	b.protected = false
+	if n := float64(len(idx)); n > 0 {
+		var voc, minSoC, maxSoC float64 = 0, 1, 0
+		for _, i := range idx {
+			voc += b.OCV.At(b.strings[i].soc) * b.series() / n
+			minSoC, maxSoC = math.Min(minSoC, b.strings[i].soc), math.Max(maxSoC, b.strings[i].soc)
+		}
+		p := math.Max(-b.MaxCharge, math.Min(b.MaxDischarge, b.demand))
+		r := rs / n
+		if disc := voc*voc - 4*r*p; disc > 0 {
+			current = (voc - math.Sqrt(disc)) / (2 * r)
+		} else {
+			current = voc / (2 * r)
+		}
+		// limited to 1C and the state of charge limits
+		current = math.Max(-capacity*n, math.Min(capacity*n, current))
+		if current > 0 && minSoC <= b.minSoC || current < 0 && maxSoC >= b.maxSoC {
+			current, b.protected = 0, true
+		}
+	}
+
+	b.current, b.voltage, b.warnings = current, 0, 0
+	for i := range b.strings {
+		s := &b.strings[i]
+		s.current = 0
+		for _, j := range idx {
+			if i == j {
+				s.current = current / float64(len(idx))
+			}
+		}
+		s.soc = math.Max(0, math.Min(1, s.soc-s.current*dt/3600/capacity))
+		s.voltage = b.OCV.At(s.soc)*b.series() - s.current*rs
+		// the strings are cooled slightly differently, causing a temperature spread
+		heat := s.current * s.current * rs / float64(b.Modules)
+		cooling := b.Cooling * (1 - 0.03*float64(i))
+		s.temp += (heat - cooling*(s.temp-b.ambient)) * dt / b.HeatCapacity
+
+		s.events &= 1 << evtOverTempAlarm
+		switch max := b.moduleTemp(s, b.Modules-1); {
+		case max >= b.AlarmTemp:
+			s.events |= 1<<evtOverTempAlarm | 1<<evtOverTempWarning
+		case max >= b.WarnTemp:
+			s.events |= 1 << evtOverTempWarning
+		case b.moduleTemp(s, 0) < 0:
+			s.events |= 1 << evtUnderTempWarning
+		}
+		switch {
+		case s.current > 0.8*capacity:
+			s.events |= 1 << evtOverDischargeCurrentWarning
+		case s.current < -0.8*capacity:
+			s.events |= 1 << evtOverChargeCurrentWarning
+		}
+		switch {
+		case s.soc <= b.minSoC:
+			s.events |= 1 << evtUnderSoCMinWarning
+		case s.soc >= b.maxSoC:
+			s.events |= 1 << evtOverSoCMaxWarning
+		}
+		b.alarms |= s.events & (1 << evtOverTempAlarm)
+		b.warnings |= s.events
+	}
+	for _, i := range idx {
+		b.voltage += b.strings[i].voltage / float64(len(idx))
+	}
+	if len(idx) == 0 {
+		for _, s := range b.strings {
+			b.voltage += s.voltage / float64(len(b.strings))
+		}
+	}
+	var min, max = math.Inf(1), math.Inf(-1)
+	for _, s := range b.strings {
+		min, max = math.Min(min, s.temp), math.Max(max, s.temp)
+	}
+	if max-min > 10 {
+		b.warnings |= 1 << evtTemperatureImbalanceWarning
+	}
+	b.throughput += math.Abs(current) * dt / 3600
+	b.uptime += dt
+}
+
+// moduleTemp returns the temperature of the given module of the string.
+// The modules are heated unevenly, spreading their temperatures around the string´s average.
+func (b *Battery) moduleTemp(s *cell, module int) float64 {
+	if b.Modules < 2 {
+		return s.temp
+	}
+	spread := 1 + 0.1*math.Abs(s.temp-b.ambient)
+	return s.temp + spread*(float64(module)/float64(b.Modules-1)-0.5)
+}
+
+// cellV returns the average cell voltage of the string.
+func (b *Battery) cellV(s *cell) float64 { return s.voltage / b.series() }
+
+// publish writes the simulated state into the models.
+func (b *Battery) publish(base, bank sunspec.Group, strs sunspec.Groups) error {
+	var w setter
+	nominal := b.OCV.At(0.5) * b.series()
+	n := float64(len(b.strings))
+	soc, health := b.soc(), b.health()
+	idx := b.active()
+
+	chaSt := chaStHolding
+	state := stateConnected
+	switch {
+	case b.alarms != 0:
+		chaSt, state = chaStOff, stateFault
+	case !b.connected:
+		chaSt, state = chaStOff, stateDisconnected
+	case b.current > 0:
+		chaSt = chaStDischarging
+	case b.current < 0:
+		chaSt = chaStCharging
+	case soc <= b.minSoC:
+		chaSt = chaStEmpty
+	case soc >= b.maxSoC:
+		chaSt = chaStFull
+	}
+	if b.protected && state == stateConnected {
+		state = stateSoCProtection
+	}
+	reqInv := reqInvNone
+	if state == stateFault {
+		reqInv = reqInvStop
+	}
+
+	// the extreme string voltages and cells
+	vmax, vmin := math.Inf(-1), math.Inf(1)
+	var cmax, cmin, cavg float64 = math.Inf(-1), math.Inf(1), 0
+	var cmaxStr, cminStr int
+	for i := range b.strings {
+		s := &b.strings[i]
+		vmax, vmin = math.Max(vmax, s.voltage), math.Min(vmin, s.voltage)
+		if v := b.cellV(s) + b.Imbalance; v > cmax {
+			cmax, cmaxStr = v, i+1
+		}
+		if v := b.cellV(s) - b.Imbalance; v < cmin {
+			cmin, cminStr = v, i+1
+		}
+		cavg += b.cellV(s) / n
+	}
+	var achaMax, adisMax float64
+	if len(idx) > 0 {
+		achaMax = math.Min(b.Capacity*float64(len(idx)), b.MaxCharge/math.Max(1, b.voltage))
+		adisMax = math.Min(b.Capacity*float64(len(idx)), b.MaxDischarge/math.Max(1, b.voltage))
+		if soc >= b.maxSoC {
+			achaMax = 0
+		}
+		if soc <= b.minSoC {
+			adisMax = 0
+		}
+	}
+
+	w.set(base, "AHRtg", b.Capacity*n)
+	w.set(base, "WHRtg", b.Capacity*n*nominal)
+	w.set(base, "WChaRteMax", b.MaxCharge)
+	w.set(base, "WDisChaRteMax", b.MaxDischarge)
+	w.set(base, "DisChaRte", 0)
+	w.set(base, "SoC", 100*soc)
+	w.set(base, "DoD", 100*(1-soc))
+	w.set(base, "SoH", 100*health)
+	w.set(base, "NCyc", math.Floor(b.cycles()))
+	w.set(base, "ChaSt", float64(chaSt))
+	w.set(base, "LocRemCtl", 0)
+	w.set(base, "Hb", math.Mod(math.Floor(b.uptime), 0xFFFF))
+	w.set(base, "Typ", typLithiumIon)
+	w.set(base, "State", float64(state))
+	w.set(base, "Evt1", float64(b.alarms|b.warnings))
+	w.set(base, "V", b.voltage)
+	w.set(base, "VMax", vmax)
+	w.set(base, "VMin", vmin)
+	w.set(base, "CellVMax", cmax)
+	w.set(base, "CellVMaxStr", float64(cmaxStr))
+	w.set(base, "CellVMaxMod", 1)
+	w.set(base, "CellVMin", cmin)
+	w.set(base, "CellVMinStr", float64(cminStr))
+	w.set(base, "CellVMinMod", float64(b.Modules))
+	w.set(base, "CellVAvg", cavg)
+	w.set(base, "A", b.current)
+	w.set(base, "AChaMax", achaMax)
+	w.set(base, "ADisChaMax", adisMax)
+	w.set(base, "W", b.voltage*b.current)
+	w.set(base, "ReqInvState", float64(reqInv))
+	w.set(base, "ReqW", 0)
+	if bank == nil {
+		return w.err
+	}
+
+	// the lithium-ion bank and its strings
+	var tmax, tmin, tavg float64 = math.Inf(-1), math.Inf(1), 0
+	var tmaxStr, tminStr int
+	var amax, amin, aavg float64 = math.Inf(-1), math.Inf(1), 0
+	var amaxStr, aminStr, vmaxStr, vminStr int
+	for i := range b.strings {
+		s := &b.strings[i]
+		if t := b.moduleTemp(s, b.Modules-1); t > tmax {
+			tmax, tmaxStr = t, i+1
+		}
+		if t := b.moduleTemp(s, 0); t < tmin {
+			tmin, tminStr = t, i+1
+		}
+		tavg += s.temp / n
+		if s.current > amax {
+			amax, amaxStr = s.current, i+1
+		}
+		if s.current < amin {
+			amin, aminStr = s.current, i+1
+		}
+		aavg += s.current / n
+		if s.voltage == vmax {
+			vmaxStr = i + 1
+		}
+		if s.voltage == vmin {
+			vminStr = i + 1
+		}
+	}
+	w.set(bank, "NStr", n)
+	w.set(bank, "NStrCon", float64(len(idx)))
+	w.set(bank, "ModTmpMax", tmax)
+	w.set(bank, "ModTmpMaxStr", float64(tmaxStr))
+	w.set(bank, "ModTmpMaxMod", float64(b.Modules))
+	w.set(bank, "ModTmpMin", tmin)
+	w.set(bank, "ModTmpMinStr", float64(tminStr))
+	w.set(bank, "ModTmpMinMod", 1)
+	w.set(bank, "ModTmpAvg", tavg)
+	w.set(bank, "StrVMax", vmax)
+	w.set(bank, "StrVMaxStr", float64(vmaxStr))
+	w.set(bank, "StrVMin", vmin)
+	w.set(bank, "StrVMinStr", float64(vminStr))
+	w.set(bank, "StrVAvg", b.voltage)
+	w.set(bank, "StrAMax", amax)
+	w.set(bank, "StrAMaxStr", float64(amaxStr))
+	w.set(bank, "StrAMin", amin)
+	w.set(bank, "StrAMinStr", float64(aminStr))
+	w.set(bank, "StrAAvg", aavg)
+	w.set(bank, "NCellBal", 0)
+	for i, g := range strs {
+		if i >= len(b.strings) {
+			break
+		}
+		s := &b.strings[i]
+		var st []int
+		if s.enabled {
+			st = append(st, strStEnabled)
+		}
+		if s.connected && b.connected {
+			st = append(st, strStContactor)
+		}
+		fail, rsn := strConFailNone, strDisRsnNone
+		switch {
+		case !s.enabled:
+			fail, rsn = strConFailDisabled, strDisRsnMaintenance
+		case s.events&(1<<evtOverTempAlarm) != 0:
+			rsn = strDisRsnFault
+		}
+		w.set(g, "StrNMod", float64(b.Modules))
+		w.set(g, "StrSt", bits(st...))
+		w.set(g, "StrConFail", float64(fail))
+		w.set(g, "StrSoC", 100*s.soc)
+		w.set(g, "StrSoH", 100*health)
+		w.set(g, "StrA", s.current)
+		w.set(g, "StrCellVMax", b.cellV(s)+b.Imbalance)
+		w.set(g, "StrCellVMaxMod", 1)
+		w.set(g, "StrCellVMin", b.cellV(s)-b.Imbalance)
+		w.set(g, "StrCellVMinMod", float64(b.Modules))
+		w.set(g, "StrCellVAvg", b.cellV(s))
+		w.set(g, "StrModTmpMax", b.moduleTemp(s, b.Modules-1))
+		w.set(g, "StrModTmpMaxMod", float64(b.Modules))
+		w.set(g, "StrModTmpMin", b.moduleTemp(s, 0))
+		w.set(g, "StrModTmpMinMod", 1)
+		w.set(g, "StrModTmpAvg", s.temp)
+		w.set(g, "StrDisRsn", float64(rsn))
+		w.set(g, "StrConSt", bits(0))
+		w.set(g, "StrEvt1", float64(s.events))
+	}
+	return w.err
+}
@@ -0,0 +1,95 @@
+package sim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBattery(t *testing.T) {
+	b := NewBattery()
+	d := instance(t, b, 802, 803)
+	if n := len(d.Model(803).Groups("string")); n != 2 {
+		t.Fatalf("expected the prepared model 803 to have 2 strings, got %v", n)
+	}
+
+	// idle at half charge
+	step(t, b, d, 1, time.Second)
+	near(t, d, "802.SoC", 50, 0)
+	near(t, d, "802.A", 0, 0)
+	near(t, d, "802.State", stateConnected, 0)
+	near(t, d, "802.ChaSt", chaStHolding, 0)
+	near(t, d, "802.SoCMin", 5, 0)
+	near(t, d, "802.SoCMax", 95, 0)
+	near(t, d, "802.AHRtg", 200, 0)
+	near(t, d, "803.NStr", 2, 0)
+	near(t, d, "802.V", b.OCV.At(0.5)*144, 0.1)
+
+	// discharging integrates the current into the state of charge of every string
+	b.SetDemand(20000)
+	step(t, b, d, 1, 360*time.Second)
+	a := value(t, d, "802.A")
+	if a <= 0 {
+		t.Fatalf("expected a discharging current, got %v", a)
+	}
+	soc := 100 * (0.5 - a/2*360/3600/100)
+	near(t, d, "802.SoC", soc, 0.1)
+	near(t, d, "803.string[0].StrSoC", soc, 0.1)
+	near(t, d, "803.string[1].StrSoC", soc, 0.1)
+	near(t, d, "803.string[0].StrA", a/2, 0.1)
+	near(t, d, "803.StrAAvg", a/2, 0.1)
+	near(t, d, "802.W", 20000, 200)
+	near(t, d, "802.ChaSt", chaStDischarging, 0)
+
+	// charging raises it again
+	b.SetDemand(-20000)
+	step(t, b, d, 1, 360*time.Second)
+	if v := value(t, d, "802.SoC"); v <= soc {
+		t.Errorf("expected the state of charge to rise above %v, got %v", soc, v)
+	}
+	near(t, d, "802.ChaSt", chaStCharging, 0)
+	if a := value(t, d, "802.A"); a >= 0 {
+		t.Errorf("expected a charging current, got %v", a)
+	}
+
+	// the state of charge limits stop the discharge
+	b.SetDemand(20000)
+	write(t, d, "802.SoCRsvMin", 60)
+	step(t, b, d, 1, time.Second)
+	near(t, d, "802.A", 0, 0)
+	near(t, d, "802.State", stateSoCProtection, 0)
+	near(t, d, "802.ADisChaMax", 0, 0)
+	write(t, d, "802.SoCRsvMin", 10)
+
+	// a disconnected string carries no current
+	write(t, d, "803.string[1].StrSetCon", strDisconnect)
+	step(t, b, d, 1, time.Second)
+	near(t, d, "803.NStrCon", 1, 0)
+	near(t, d, "803.string[1].StrA", 0, 0)
+	near(t, d, "803.string[0].StrA", value(t, d, "802.A"), 0.1)
+
+	// the disconnected bank carries no current at all
+	write(t, d, "802.SetOp", setOpDisconnect)
+	step(t, b, d, 1, time.Second)
+	near(t, d, "802.A", 0, 0)
+	near(t, d, "802.W", 0, 0)
+	near(t, d, "802.State", stateDisconnected, 0)
+	near(t, d, "802.ChaSt", chaStOff, 0)
+	write(t, d, "802.SetOp", setOpConnect)
+
+	// overheating latches the alarm until it is reset
+	b.SetDemand(0)
+	b.SetAmbient(60)
+	step(t, b, d, 100, time.Hour)
+	near(t, d, "802.State", stateFault, 0)
+	near(t, d, "802.ReqInvState", reqInvStop, 0)
+	if evt := uint32(value(t, d, "802.Evt1")); evt&(1<<evtOverTempAlarm) == 0 {
+		t.Errorf("expected the over temperature alarm, got %#x", evt)
+	}
+	b.SetAmbient(25)
+	step(t, b, d, 100, time.Hour)
+	near(t, d, "802.State", stateFault, 0)
+	write(t, d, "802.AlmRst", 1)
+	step(t, b, d, 1, time.Second)
+	near(t, d, "802.State", stateConnected, 0)
+	near(t, d, "802.AlmRst", 0, 0)
+}
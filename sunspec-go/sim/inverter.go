@@ -0,0 +1,797 @@
+package sim
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/GoAethereal/modbus"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// enumerated values of the DER models 701 to 705
+const (
+	disabled = 0
+	enabled  = 1
+
+	acThreePhase = 2
+
+	stOn = 1
+
+	invStOff      = 0
+	invStStarting = 2
+	invStRunning  = 3
+	invStThrottle = 4
+	invStStandby  = 7
+
+	wSetModPct   = 0
+	wSetModWatts = 1
+
+	varSetModWMaxPct     = 0
+	varSetModVarMaxPct   = 1
+	varSetModVarAvailPct = 2
+	varSetModVAMaxPct    = 3
+	varSetModVars        = 4
+
+	varSetPriActive = 0
+
+	extOverExcited = 0
+
+	deptRefWMaxPct     = 1
+	deptRefVarMaxPct   = 2
+	deptRefVarAvailPct = 3
+
+	priReactive = 2
+
+	crvRW = 0
+	crvR  = 1
+
+	adptCompleted = 1
+	adptFailed    = 2
+)
+
+// bit positions of the DER alarms (Alrm) and throttling sources (ThrotSrc)
+const (
+	alrmOverFrequency  = 8
+	alrmUnderFrequency = 9
+	alrmACOverVolt     = 10
+	alrmACUnderVolt    = 11
+
+	throtMaxW     = 0
+	throtFixedW   = 1
+	throtFixedVar = 2
+	throtFixedPF  = 3
+	throtVoltVar  = 4
+	throtFreqWatt = 5
+	throtLVTrip   = 7
+	throtHVTrip   = 8
+	throtLFTrip   = 12
+	throtHFTrip   = 13
+)
+
+// Inverter simulates a three phase grid connected DER, served by the models 701 (AC measurement),
+// 702 (capacity), 703 (enter service), 704 (AC controls) and 705 (volt-var).
+//
+// The inverter feeds the available power, e.g. of a PV array, into a grid given by a voltage and frequency profile.
+// The active and reactive power follow the controls written by clients:
+// the active power limit (WMaxLimPct), the active power setpoint (WSet), the constant power factor (PFWInj, PFWAbs),
+// the constant reactive power (VarSet) and the volt-var curve (705). Time limited controls revert after their reversion time.
+// Independently of the served models, the active power is reduced by the frequency-watt droop of the inverter.
+// The inverter ceases to energize while enter service is not permitted or the grid exceeds the trip limits,
+// and enters service again after the grid returned into the enter service window for the given delay.
+//
+// Powers are positive while injecting into the grid, reactive power is positive while over-excited.
+type Inverter struct {
+	// WRtg, VARtg and VarRtg are the nameplate ratings of the active, apparent and reactive power.
+	WRtg, VARtg, VarRtg float64
+	// MaxCharge is the maximum active power absorbed from the grid in W, e.g. to charge a battery.
+	// Active power setpoints below zero are only followed up to this rating, which is zero for PV inverters.
+	MaxCharge float64
+	// VNom and FNom are the nominal line to neutral voltage in V and frequency in Hz.
+	VNom, FNom float64
+	// Voltage and Frequency are the profiles of the grid´s line to neutral voltage in V and frequency in Hz
+	// by the simulated time in seconds. Empty profiles are taken as the nominal values.
+	Voltage, Frequency Curve
+	// R and X are the grid impedance per phase in Ohm, raising the voltage at the point of connection
+	// by the injected active and reactive power.
+	R, X float64
+	// Trip defines the limits of the grid at which the inverter ceases to energize.
+	Trip Trip
+	// FreqWatt is the frequency-watt droop of the inverter.
+	FreqWatt FreqWatt
+	// Seed seeds the random enter service delay.
+	Seed int64
+
+	mu        sync.Mutex
+	ready     bool
+	served    map[uint16]bool
+	rnd       *rand.Rand
+	elapsed   float64
+	available float64
+	ambient   float64
+	// the grid at the point of connection
+	v, hz float64
+	// the simulated output
+	p, q      float64
+	connected bool
+	delay     float64
+	ramp      float64
+	throttle  []int
+	alarms    []int
+	heat      float64
+	energy    [4]float64
+	timers    map[string]float64
+}
+
+// Trip defines the limits of the grid at which an inverter ceases to energize.
+type Trip struct {
+	// VLow and VHigh are the limits of the voltage in per unit of the nominal voltage.
+	VLow, VHigh float64
+	// FLow and FHigh are the limits of the frequency in Hz.
+	FLow, FHigh float64
+}
+
+// FreqWatt defines the frequency-watt droop of an inverter.
+// Beyond the dead band, the active power is changed by the rated power for a frequency deviation of K times
+// the nominal frequency, reduced for over-frequency and raised for under-frequency as far as power is available.
+type FreqWatt struct {
+	Enabled bool
+	// DbOf and DbUf are the over- and under-frequency dead bands in Hz.
+	DbOf, DbUf float64
+	// KOf and KUf are the over- and under-frequency droops in per unit.
+	KOf, KUf float64
+}
+
+var _ Simulator = (*Inverter)(nil)
+
+// NewInverter returns a 10 kW three phase inverter connected to a 230 V / 50 Hz grid.
+// The trip limits and the frequency-watt droop default to the IEEE 1547 category II settings.
+func NewInverter() *Inverter {
+	return &Inverter{
+		WRtg:      10000,
+		VARtg:     10000,
+		VarRtg:    4400,
+		VNom:      230,
+		FNom:      50,
+		Trip:      Trip{VLow: 0.5, VHigh: 1.2, FLow: 47, FHigh: 52},
+		FreqWatt:  FreqWatt{Enabled: true, DbOf: 0.036, DbUf: 0.036, KOf: 0.05, KUf: 0.05},
+		available: 10000,
+		ambient:   25,
+	}
+}
+
+// SetAvailable sets the power available to the inverter in W, e.g. by the irradiance of a PV array.
+func (inv *Inverter) SetAvailable(w float64) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.available = w
+}
+
+// SetIrradiance sets the power available to the inverter by the irradiance of its PV array in W/m².
+// The array is assumed to deliver the rated power at the standard test condition of 1000 W/m².
+func (inv *Inverter) SetIrradiance(wm2 float64) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.available = inv.WRtg * wm2 / 1000
+}
+
+// SetAmbient sets the ambient temperature in °C.
+func (inv *Inverter) SetAmbient(c float64) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.ambient = c
+}
+
+// SetGrid replaces the grid profiles by the constant voltage v in V and frequency hz in Hz.
+func (inv *Inverter) SetGrid(v, hz float64) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.Voltage, inv.Frequency = Curve{{0, v}}, Curve{{0, hz}}
+}
+
+// Power returns the current active and reactive power of the inverter.
+func (inv *Inverter) Power() (w, vars float64) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return inv.p, inv.q
+}
+
+// Prepare sets the scale factors of the models 701 to 705.
+// The volt-var model 705 is served with three curves of four points each.
+func (inv *Inverter) Prepare(defs ...sunspec.Definition) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.served = make(map[uint16]bool)
+	factors := map[uint16]map[string]int16{
+		701: {"A_SF": -2, "V_SF": -1, "Hz_SF": -3, "W_SF": 0, "PF_SF": -3, "VA_SF": 0, "Var_SF": 0, "TotWh_SF": 0, "TotVarh_SF": 0, "Tmp_SF": -1},
+		702: {"W_SF": 0, "PF_SF": -3, "VA_SF": 0, "Var_SF": 0, "V_SF": -1, "A_SF": -2, "S_SF": 0},
+		703: {"V_SF": -1, "Hz_SF": -2},
+		704: {"PF_SF": -3, "WMaxLimPct_SF": -1, "WSet_SF": 0, "WSetPct_SF": -1, "VarSet_SF": 0, "VarSetPct_SF": -1},
+		705: {"V_SF": -1, "DeptRef_SF": -1, "RspTms_SF": 0},
+	}
+	for id, f := range factors {
+		if def := definition(defs, id); def != nil {
+			scale(&def.Group, f)
+			inv.served[id] = true
+		}
+	}
+	if def := definition(defs, 705); def != nil {
+		if err := count(def, "Crv", 3); err != nil {
+			return err
+		}
+		if err := count(def, "Crv.Pt", 4); err != nil {
+			return err
+		}
+		for i, p := range def.Group.Points {
+			switch p.Name {
+			case "NCrv":
+				def.Group.Points[i].Value = 3
+			case "NPt":
+				def.Group.Points[i].Value = 4
+			}
+		}
+	}
+	return nil
+}
+
+// Attach registers the write hooks of the controls.
+// Writing a time limited control of model 704 or the volt-var model starts its reversion timer,
+// a curve is adopted by writing its index to AdptCrvReq and the adopted curve 1 is read-only.
+// Only hooks of models given to Prepare are registered.
+func (inv *Inverter) Attach(s *sunspec.Server) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.served[704] {
+		for _, prefix := range [...]string{"PFWInj", "PFWAbs", "WMaxLimPct", "WSet", "VarSet"} {
+			prefix := prefix
+			s.OnWrite("704."+prefix+"Ena", sunspec.WriteHook{Apply: func(c sunspec.Change) {
+				rearm(c.Point.Origin(), prefix)
+			}})
+		}
+	}
+	if inv.served[705] {
+		s.OnWrite("705.Ena", sunspec.WriteHook{Apply: func(c sunspec.Change) {
+			rearm(c.Point.Origin(), "")
+		}})
+		s.OnWrite("705.AdptCrvReq", sunspec.WriteHook{Apply: func(c sunspec.Change) {
+			g := c.Point.Origin()
+			if n, _ := get(g, "AdptCrvReq"); n != 0 {
+				adopt(g, int(n))
+				set(g, "AdptCrvReq", 0)
+				rearm(g, "")
+			}
+		}})
+		// rewriting the current values is accepted, so that clients may write the model as a whole
+		readOnly := sunspec.WriteHook{Validate: func(c sunspec.Change) error {
+			if !reflect.DeepEqual(c.Old, c.New) {
+				return modbus.IllegalDataAddress
+			}
+			return nil
+		}}
+		s.OnWrite("705.Crv[0].*", readOnly)
+		s.OnWrite("705.Crv[0].Pt[*].*", readOnly)
+	}
+}
+
+// rearm starts the reversion timer of the control given by its prefix.
+func rearm(g sunspec.Group, prefix string) {
+	if t, ok := get(g, prefix+"RvrtTms"); ok {
+		set(g, prefix+"RvrtRem", t)
+	}
+}
+
+// adopt copies the stored curve n (1-based) into the active curve 1.
+// The curve is only adopted if it is valid, the result is reported by AdptCrvRslt.
+func adopt(g sunspec.Group, n int) {
+	crvs := g.Groups("Crv")
+	if n < 1 || n > len(crvs) || !valid(crvs[n-1]) {
+		set(g, "AdptCrvRslt", adptFailed)
+		return
+	}
+	if n > 1 {
+		src, dst := crvs[n-1], crvs[0]
+		for _, name := range [...]string{"ActPt", "DeptRef", "Pri", "VRef", "VRefAutoEna", "VRefAutoTms", "RspTms"} {
+			if v, ok := get(src, name); ok {
+				set(dst, name, v)
+			}
+		}
+		from, to := src.Groups("Pt"), dst.Groups("Pt")
+		for i := range from {
+			for _, name := range [...]string{"V", "Var"} {
+				if v, ok := get(from[i], name); ok && i < len(to) {
+					set(to[i], name, v)
+				}
+			}
+		}
+	}
+	set(g, "AdptCrvRslt", adptCompleted)
+}
+
+// valid reports whether the curve has at least two active points of ascending voltage.
+func valid(crv sunspec.Group) bool {
+	n, _ := get(crv, "ActPt")
+	pts := crv.Groups("Pt")
+	if n < 2 || int(n) > len(pts) {
+		return false
+	}
+	last := math.Inf(-1)
+	for _, pt := range pts[:int(n)] {
+		v, ok := get(pt, "V")
+		if !ok || v <= last {
+			return false
+		}
+		last = v
+	}
+	return true
+}
+
+// curve returns the volt-var characteristic of the active curve 1 in percent of the nominal voltage.
+func curve(g sunspec.Group) (c Curve, deptRef float64, rsp float64) {
+	crvs := g.Groups("Crv")
+	if len(crvs) == 0 || !valid(crvs[0]) {
+		return nil, 0, 0
+	}
+	n, _ := get(crvs[0], "ActPt")
+	ref, ok := get(crvs[0], "VRef")
+	if !ok || ref == 0 {
+		ref = 100
+	}
+	for _, pt := range crvs[0].Groups("Pt")[:int(n)] {
+		v, _ := get(pt, "V")
+		q, _ := get(pt, "Var")
+		// the curve voltages are relative to the reference voltage
+		c = append(c, Point{X: v + ref - 100, Y: q})
+	}
+	deptRef, _ = get(crvs[0], "DeptRef")
+	rsp, _ = get(crvs[0], "RspTms")
+	return c, deptRef, rsp
+}
+
+// Step advances the simulation by dt.
+func (inv *Inverter) Step(d sunspec.Device, dt time.Duration) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	meas := model(d, 701)
+	if meas == nil {
+		return errors.New("sim: the inverter requires the model 701")
+	}
+	cpt, es, ctl, vv := model(d, 702), model(d, 703), model(d, 704), model(d, 705)
+	if !inv.ready {
+		inv.init(cpt, es, ctl, vv)
+	}
+	s := dt.Seconds()
+	inv.elapsed += s
+	inv.revert(ctl, vv, s)
+	inv.grid()
+	inv.service(es, s)
+	inv.simulate(cpt, ctl, vv, s)
+	return inv.publish(meas, cpt, es)
+}
+
+// init sets up the simulated state and the default settings.
+// Settings already given, e.g. restored from a store, are kept.
+func (inv *Inverter) init(cpt, es, ctl, vv sunspec.Group) {
+	inv.rnd = rand.New(rand.NewSource(inv.Seed))
+	inv.timers = make(map[string]float64)
+	inv.heat = inv.ambient
+	defaults := func(g sunspec.Group, name string, v float64) {
+		if x, ok := get(g, name); ok && x == 0 {
+			set(g, name, v)
+		}
+	}
+	defaults(cpt, "WMax", inv.WRtg)
+	defaults(cpt, "VAMax", inv.VARtg)
+	defaults(cpt, "VarMaxInj", inv.VarRtg)
+	defaults(cpt, "VarMaxAbs", inv.VarRtg)
+	defaults(cpt, "WChaRteMax", inv.MaxCharge)
+	defaults(cpt, "VNom", inv.VNom)
+	defaults(cpt, "VMax", inv.VNom*inv.Trip.VHigh)
+	defaults(cpt, "VMin", inv.VNom*inv.Trip.VLow)
+	defaults(cpt, "AMax", inv.VARtg/inv.VNom/3)
+	defaults(cpt, "PFOvrExt", 0.9)
+	defaults(cpt, "PFUndExt", 0.9)
+
+	defaults(es, "ES", enabled)
+	defaults(es, "ESVHi", 105)
+	defaults(es, "ESVLo", 91.7)
+	defaults(es, "ESHzHi", inv.FNom+0.1)
+	defaults(es, "ESHzLo", inv.FNom-0.5)
+	defaults(es, "ESDlyTms", 300)
+	defaults(es, "ESRmpTms", 300)
+
+	defaults(ctl, "WMaxLimPct", 100)
+	defaults(ctl, "WMaxLimPctRvrt", 100)
+	defaults(ctl, "WRmp", 100)
+	defaults(ctl, "VarRmp", 100)
+	for _, name := range [...]string{"PFWInj", "PFWInjRvrt", "PFWAbs", "PFWAbsRvrt"} {
+		if ctl != nil {
+			defaults(ctl.Group(name), "PF", 1)
+		}
+	}
+
+	// the curves according to IEEE 1547 category B
+	if vv != nil {
+		for i, crv := range vv.Groups("Crv") {
+			defaults(crv, "ActPt", 4)
+			defaults(crv, "DeptRef", deptRefVarMaxPct)
+			defaults(crv, "Pri", priReactive)
+			defaults(crv, "VRef", 100)
+			defaults(crv, "RspTms", 5)
+			if i == 0 {
+				set(crv, "ReadOnly", crvR)
+			} else {
+				set(crv, "ReadOnly", crvRW)
+			}
+			for j, pt := range crv.Groups("Pt") {
+				if j >= 4 {
+					break
+				}
+				defaults(pt, "V", [...]float64{92, 98, 102, 108}[j])
+				if v, ok := get(pt, "Var"); ok && v == 0 {
+					set(pt, "Var", [...]float64{44, 0, 0, -44}[j])
+				}
+			}
+		}
+		defaults(vv, "AdptCrvRslt", adptCompleted)
+	}
+
+	// the inverter is in service from the start, if permitted by the grid
+	inv.grid()
+	inv.connected = inv.permitted(es)
+	inv.delay, inv.ramp = -1, 1
+	inv.ready = true
+}
+
+// revert counts down the reversion timers of the time limited controls.
+// When a timer expires, the control reverts to its reversion settings.
+func (inv *Inverter) revert(ctl, vv sunspec.Group, dt float64) {
+	countdown := func(g sunspec.Group, prefix string, expire func()) {
+		rem, ok := get(g, prefix+"RvrtRem")
+		if !ok || rem == 0 {
+			delete(inv.timers, prefix)
+			return
+		}
+		// the point only holds whole seconds, the fraction is kept by the timer
+		t, ok := inv.timers[prefix]
+		if !ok || math.Ceil(t) != rem {
+			t = rem
+		}
+		if t -= dt; t <= 0 {
+			expire()
+			t = 0
+		}
+		inv.timers[prefix] = t
+		set(g, prefix+"RvrtRem", math.Ceil(t))
+	}
+	if ctl != nil {
+		for _, prefix := range [...]string{"PFWInj", "PFWAbs", "WMaxLimPct", "WSet", "VarSet"} {
+			prefix := prefix
+			countdown(ctl, prefix, func() {
+				if v, ok := get(ctl, prefix+"EnaRvrt"); ok {
+					set(ctl, prefix+"Ena", v)
+				}
+				switch prefix {
+				case "PFWInj", "PFWAbs":
+					for _, name := range [...]string{"PF", "Ext"} {
+						if v, ok := get(ctl.Group(prefix+"Rvrt"), name); ok {
+							set(ctl.Group(prefix), name, v)
+						}
+					}
+				case "WSet", "VarSet":
+					for _, name := range [...]string{prefix, prefix + "Pct"} {
+						if v, ok := get(ctl, name+"Rvrt"); ok {
+							set(ctl, name, v)
+						}
+					}
+				default:
+					if v, ok := get(ctl, prefix+"Rvrt"); ok {
+						set(ctl, prefix, v)
+					}
+				}
+			})
+		}
+	}
+	if vv != nil {
+		countdown(vv, "", func() {
+			if n, ok := get(vv, "RvrtCrv"); ok && n != 0 {
+				adopt(vv, int(n))
+			}
+		})
+	}
+}
+
+// grid determines the voltage and frequency at the point of connection.
+func (inv *Inverter) grid() {
+	inv.v, inv.hz = inv.VNom, inv.FNom
+	if len(inv.Voltage) > 0 {
+		inv.v = inv.Voltage.At(inv.elapsed)
+	}
+	if len(inv.Frequency) > 0 {
+		inv.hz = inv.Frequency.At(inv.elapsed)
+	}
+	if inv.v > 0 {
+		inv.v += (inv.R*inv.p + inv.X*inv.q) / 3 / inv.v
+	}
+}
+
+// permitted reports whether the inverter may enter service, given by the enter service window of model 703.
+func (inv *Inverter) permitted(es sunspec.Group) bool {
+	if v, ok := get(es, "ES"); ok && v != enabled {
+		return false
+	}
+	pu := 100 * inv.v / inv.VNom
+	if v, ok := get(es, "ESVHi"); ok && pu > v {
+		return false
+	}
+	if v, ok := get(es, "ESVLo"); ok && pu < v {
+		return false
+	}
+	if v, ok := get(es, "ESHzHi"); ok && inv.hz > v {
+		return false
+	}
+	if v, ok := get(es, "ESHzLo"); ok && inv.hz < v {
+		return false
+	}
+	return true
+}
+
+// service trips the inverter if the grid exceeds the trip limits and re-enters service after the delay.
+func (inv *Inverter) service(es sunspec.Group, dt float64) {
+	pu := inv.v / inv.VNom
+	inv.alarms = inv.alarms[:0]
+	inv.throttle = inv.throttle[:0]
+	trip := func(cond bool, alarm, throttle int) {
+		if cond {
+			inv.alarms = append(inv.alarms, alarm)
+			inv.throttle = append(inv.throttle, throttle)
+			inv.connected = false
+		}
+	}
+	trip(pu > inv.Trip.VHigh, alrmACOverVolt, throtHVTrip)
+	trip(pu < inv.Trip.VLow, alrmACUnderVolt, throtLVTrip)
+	trip(inv.hz > inv.Trip.FHigh, alrmOverFrequency, throtHFTrip)
+	trip(inv.hz < inv.Trip.FLow, alrmUnderFrequency, throtLFTrip)
+
+	if v, ok := get(es, "ES"); ok && v != enabled {
+		inv.connected = false
+	}
+	switch {
+	case inv.connected:
+		inv.delay = -1
+		if rmp, _ := get(es, "ESRmpTms"); rmp > 0 {
+			inv.ramp = math.Min(1, inv.ramp+dt/rmp)
+		} else {
+			inv.ramp = 1
+		}
+	case !inv.permitted(es) || len(inv.alarms) > 0:
+		inv.delay, inv.ramp = -1, 0
+	case inv.delay < 0:
+		// the grid just returned into the window, start the delay including its random part
+		dly, _ := get(es, "ESDlyTms")
+		rnd, _ := get(es, "ESRndTms")
+		inv.delay = dly + rnd*inv.rnd.Float64()
+	default:
+		if inv.delay -= dt; inv.delay <= 0 {
+			inv.connected, inv.delay = true, -1
+		}
+	}
+}
+
+// simulate determines the active and reactive power by the controls.
+func (inv *Inverter) simulate(cpt, ctl, vv sunspec.Group, dt float64) {
+	if !inv.connected {
+		inv.p, inv.q = 0, 0
+		inv.heat += (inv.ambient - inv.heat) * (1 - math.Exp(-dt/300))
+		return
+	}
+	setting := func(name string, v float64) float64 {
+		if x, ok := get(cpt, name); ok && x > 0 {
+			return x
+		}
+		return v
+	}
+	wmax, vamax := setting("WMax", inv.WRtg), setting("VAMax", inv.VARtg)
+	varInj, varAbs := setting("VarMaxInj", inv.VarRtg), setting("VarMaxAbs", inv.VarRtg)
+	isEnabled := func(name string) bool { v, _ := get(ctl, name); return v == enabled }
+
+	// active power
+	avail := math.Min(inv.available, wmax)
+	p := avail
+	if isEnabled("WSetEna") {
+		mod, _ := get(ctl, "WSetMod")
+		w, _ := get(ctl, "WSet")
+		if mod == wSetModPct {
+			pct, _ := get(ctl, "WSetPct")
+			w = pct / 100 * wmax
+		}
+		if w < p {
+			p, inv.throttle = math.Max(-inv.MaxCharge, w), append(inv.throttle, throtFixedW)
+		}
+	}
+	if isEnabled("WMaxLimPctEna") {
+		pct, _ := get(ctl, "WMaxLimPct")
+		if lim := pct / 100 * wmax; lim < p {
+			p, inv.throttle = lim, append(inv.throttle, throtMaxW)
+		}
+	}
+	if fw := inv.FreqWatt; fw.Enabled {
+		switch {
+		case fw.KOf > 0 && inv.hz > inv.FNom+fw.DbOf:
+			if lim := p - (inv.hz-inv.FNom-fw.DbOf)/(inv.FNom*fw.KOf)*wmax; lim < p {
+				p, inv.throttle = math.Max(math.Min(0, p), lim), append(inv.throttle, throtFreqWatt)
+			}
+		case fw.KUf > 0 && inv.hz < inv.FNom-fw.DbUf:
+			p = math.Min(avail, p+(inv.FNom-fw.DbUf-inv.hz)/(inv.FNom*fw.KUf)*wmax)
+		}
+	}
+	// increases are limited by the ramp rate and the enter service ramp
+	if rmp, ok := get(ctl, "WRmp"); ok && rmp > 0 && p > inv.p {
+		p = math.Min(p, inv.p+rmp/100*wmax*dt)
+	}
+	p = math.Min(p, inv.ramp*wmax)
+
+	// reactive power
+	var q, tau float64
+	switch {
+	case p >= 0 && isEnabled("PFWInjEna"):
+		q = reactive(p, ctl.Group("PFWInj"))
+		inv.throttle = append(inv.throttle, throtFixedPF)
+	case p < 0 && isEnabled("PFWAbsEna"):
+		q = reactive(p, ctl.Group("PFWAbs"))
+		inv.throttle = append(inv.throttle, throtFixedPF)
+	case isEnabled("VarSetEna"):
+		mod, _ := get(ctl, "VarSetMod")
+		pct, _ := get(ctl, "VarSetPct")
+		switch mod {
+		case varSetModVars:
+			q, _ = get(ctl, "VarSet")
+		case varSetModWMaxPct:
+			q = pct / 100 * wmax
+		case varSetModVarMaxPct:
+			q = pct / 100 * math.Max(varInj, varAbs)
+		case varSetModVarAvailPct:
+			q = pct / 100 * math.Sqrt(math.Max(0, vamax*vamax-p*p))
+		case varSetModVAMaxPct:
+			q = pct / 100 * vamax
+		}
+		inv.throttle = append(inv.throttle, throtFixedVar)
+	default:
+		if ena, _ := get(vv, "Ena"); ena == enabled {
+			if c, ref, rsp := curve(vv); c != nil {
+				pct := c.At(100 * inv.v / inv.VNom)
+				switch ref {
+				case deptRefWMaxPct:
+					q = pct / 100 * wmax
+				case deptRefVarAvailPct:
+					q = pct / 100 * math.Sqrt(math.Max(0, vamax*vamax-p*p))
+				default:
+					q = pct / 100 * math.Max(varInj, varAbs)
+				}
+				// the open loop response time is the time to reach 90 % of the change
+				tau = rsp / math.Ln10
+				inv.throttle = append(inv.throttle, throtVoltVar)
+			}
+		}
+	}
+	q = math.Max(-varAbs, math.Min(varInj, q))
+	if tau > 0 {
+		q = inv.q + (q-inv.q)*(1-math.Exp(-dt/tau))
+	}
+
+	// the apparent power is limited by the priority of the reactive power
+	if s := math.Hypot(p, q); s > vamax {
+		if pri, _ := get(ctl, "VarSetPri"); pri == varSetPriActive && ctl != nil {
+			q = math.Copysign(math.Sqrt(math.Max(0, vamax*vamax-p*p)), q)
+		} else {
+			p = math.Copysign(math.Sqrt(math.Max(0, vamax*vamax-q*q)), p)
+		}
+	}
+	inv.p, inv.q = p, q
+
+	s := math.Hypot(p, q)
+	inv.heat += (inv.ambient + 30*s/inv.VARtg - inv.heat) * (1 - math.Exp(-dt/300))
+	h := dt / 3600
+	if p >= 0 {
+		inv.energy[0] += p * h
+	} else {
+		inv.energy[1] -= p * h
+	}
+	if q >= 0 {
+		inv.energy[2] += q * h
+	} else {
+		inv.energy[3] -= q * h
+	}
+}
+
+// reactive returns the reactive power at active power p for the constant power factor setting of the group.
+func reactive(p float64, g sunspec.Group) float64 {
+	pf, ok := get(g, "PF")
+	if !ok || pf <= 0 || pf >= 1 {
+		return 0
+	}
+	q := math.Abs(p) * math.Sqrt(1-pf*pf) / pf
+	if ext, _ := get(g, "Ext"); ext != extOverExcited {
+		return -q
+	}
+	return q
+}
+
+// publish writes the simulated state into the models.
+func (inv *Inverter) publish(meas, cpt, es sunspec.Group) error {
+	var w setter
+	s := math.Hypot(inv.p, inv.q)
+	var pf float64
+	if s > 0 {
+		pf = inv.p / s
+	}
+	a := s / 3 / math.Max(1, inv.v)
+	st := invStRunning
+	switch {
+	case func() bool { v, ok := get(es, "ES"); return ok && v != enabled }():
+		st = invStOff
+	case !inv.connected && inv.delay >= 0:
+		st = invStStarting
+	case !inv.connected:
+		st = invStStandby
+	case len(inv.throttle) > 0:
+		st = invStThrottle
+	}
+	var conn, throt float64
+	if inv.connected {
+		conn = 1
+	}
+	if wmax, _ := get(cpt, "WMax"); wmax > 0 {
+		throt = math.Max(0, 100*(math.Min(inv.available, wmax)-inv.p)/wmax)
+	}
+
+	w.set(meas, "ACType", acThreePhase)
+	w.set(meas, "St", stOn)
+	w.set(meas, "InvSt", float64(st))
+	w.set(meas, "ConnSt", conn)
+	w.set(meas, "Alrm", bits(inv.alarms...))
+	w.set(meas, "DERMode", bits(0))
+	w.set(meas, "W", inv.p)
+	w.set(meas, "VA", s)
+	w.set(meas, "Var", inv.q)
+	w.set(meas, "PF", pf)
+	w.set(meas, "A", 3*a)
+	w.set(meas, "LLV", inv.v*math.Sqrt(3))
+	w.set(meas, "LNV", inv.v)
+	w.set(meas, "Hz", inv.hz)
+	for i, name := range [...]string{"TotWhInj", "TotWhAbs", "TotVarhInj", "TotVarhAbs"} {
+		w.set(meas, name, inv.energy[i])
+		for _, l := range [...]string{"L1", "L2", "L3"} {
+			w.set(meas, name+l, inv.energy[i]/3)
+		}
+	}
+	w.set(meas, "TmpAmb", inv.ambient)
+	w.set(meas, "TmpCab", (inv.ambient+inv.heat)/2)
+	w.set(meas, "TmpSnk", inv.heat)
+	for i, l := range [...]string{"1", "2", "3"} {
+		w.set(meas, "WL"+l, inv.p/3)
+		w.set(meas, "VAL"+l, s/3)
+		w.set(meas, "VarL"+l, inv.q/3)
+		w.set(meas, "PFL"+l, pf)
+		w.set(meas, "AL"+l, a)
+		w.set(meas, "VL"+l, inv.v)
+		w.set(meas, "VL"+l+"L"+[...]string{"2", "3", "1"}[i], inv.v*math.Sqrt(3))
+	}
+	w.set(meas, "ThrotPct", throt)
+	w.set(meas, "ThrotSrc", bits(inv.throttle...))
+
+	w.set(cpt, "WMaxRtg", inv.WRtg)
+	w.set(cpt, "VAMaxRtg", inv.VARtg)
+	w.set(cpt, "VarMaxInjRtg", inv.VarRtg)
+	w.set(cpt, "VarMaxAbsRtg", inv.VarRtg)
+	w.set(cpt, "WChaRteMaxRtg", inv.MaxCharge)
+	w.set(cpt, "VNomRtg", inv.VNom)
+	w.set(cpt, "VMaxRtg", inv.VNom*inv.Trip.VHigh)
+	w.set(cpt, "VMinRtg", inv.VNom*inv.Trip.VLow)
+	w.set(cpt, "AMaxRtg", inv.VARtg/inv.VNom/3)
+
+	w.set(es, "ESDlyRemTms", math.Max(0, math.Ceil(inv.delay)))
+	return w.err
+}
@@ -0,0 +1,103 @@
+package sim
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestInverter(t *testing.T) {
+	inv := NewInverter()
+	d := instance(t, inv, 701, 702, 703, 704, 705)
+	alarm := func(bit int) bool { return uint32(value(t, d, "701.Alrm"))&(1<<bit) != 0 }
+	throttled := func(bit int) bool { return uint32(value(t, d, "701.ThrotSrc"))&(1<<bit) != 0 }
+
+	// feeding the available power into the nominal grid
+	step(t, inv, d, 360, time.Second)
+	near(t, d, "701.W", 10000, 0)
+	near(t, d, "701.Var", 0, 0)
+	near(t, d, "701.PF", 1, 0)
+	near(t, d, "701.LNV", 230, 0)
+	near(t, d, "701.Hz", 50, 0)
+	near(t, d, "701.InvSt", invStRunning, 0)
+	near(t, d, "701.ConnSt", 1, 0)
+	near(t, d, "701.TotWhInj", 1000, 1)
+	near(t, d, "702.WMaxRtg", 10000, 0)
+	near(t, d, "705.NCrv", 3, 0)
+
+	// the active power limit, reverting after its reversion time
+	write(t, d, "704.WMaxLimPctEna", enabled)
+	write(t, d, "704.WMaxLimPct", 50)
+	write(t, d, "704.WMaxLimPctRvrtRem", 10)
+	step(t, inv, d, 1, time.Second)
+	near(t, d, "701.W", 5000, 0)
+	near(t, d, "701.InvSt", invStThrottle, 0)
+	near(t, d, "701.ThrotPct", 50, 0)
+	if !throttled(throtMaxW) {
+		t.Error("expected the inverter to be throttled by the active power limit")
+	}
+	near(t, d, "704.WMaxLimPctRvrtRem", 9, 0)
+	step(t, inv, d, 9, time.Second)
+	near(t, d, "704.WMaxLimPctEna", disabled, 0)
+	near(t, d, "704.WMaxLimPct", 100, 0)
+	near(t, d, "704.WMaxLimPctRvrtRem", 0, 0)
+
+	// the frequency-watt droop reduces the active power by over-frequency
+	inv.SetGrid(230, 50.5)
+	step(t, inv, d, 1, time.Second)
+	near(t, d, "701.W", 10000-(50.5-50-0.036)/(50*0.05)*10000, 1)
+	if !throttled(throtFreqWatt) {
+		t.Error("expected the inverter to be throttled by the frequency-watt droop")
+	}
+
+	// the volt-var curve absorbs reactive power by over-voltage, within the apparent power
+	inv.SetAvailable(8000)
+	inv.SetGrid(230*1.08, 50)
+	write(t, d, "705.Ena", enabled)
+	step(t, inv, d, 60, time.Second)
+	near(t, d, "701.Var", -0.44*4400, 1)
+	if !throttled(throtVoltVar) {
+		t.Error("expected the reactive power to follow the volt-var curve")
+	}
+	inv.SetGrid(230, 50)
+	step(t, inv, d, 60, time.Second)
+	near(t, d, "701.Var", 0, 1)
+	inv.SetAvailable(10000)
+
+	// a stored curve is adopted into the active curve
+	vv := d.Model(705)
+	for i, v := range [...]float64{90, 95, 105, 110} {
+		write(t, d, fmt.Sprintf("705.Crv[1].Pt[%v].V", i), v)
+	}
+	adopt(vv, 2)
+	near(t, d, "705.AdptCrvRslt", adptCompleted, 0)
+	near(t, d, "705.Crv[0].Pt[3].V", 110, 0)
+	write(t, d, "705.Crv[2].Pt[1].V", 80)
+	adopt(vv, 3)
+	near(t, d, "705.AdptCrvRslt", adptFailed, 0)
+	near(t, d, "705.Crv[0].Pt[1].V", 95, 0)
+
+	// the inverter trips by over-frequency
+	write(t, d, "705.Ena", disabled)
+	inv.SetGrid(230, 53)
+	step(t, inv, d, 1, time.Second)
+	near(t, d, "701.W", 0, 0)
+	near(t, d, "701.ConnSt", 0, 0)
+	near(t, d, "701.InvSt", invStStandby, 0)
+	if !alarm(alrmOverFrequency) || !throttled(throtHFTrip) {
+		t.Error("expected the over-frequency trip")
+	}
+
+	// and enters service again after the delay, ramping up its power
+	inv.SetGrid(230, 50)
+	step(t, inv, d, 300, time.Second)
+	near(t, d, "701.InvSt", invStStarting, 0)
+	near(t, d, "703.ESDlyRemTms", 1, 0)
+	step(t, inv, d, 1, time.Second)
+	near(t, d, "701.ConnSt", 1, 0)
+	step(t, inv, d, 150, time.Second)
+	near(t, d, "701.W", 5000, 1)
+	if alarm(alrmOverFrequency) {
+		t.Error("expected the alarm to be cleared")
+	}
+}
@@ -0,0 +1,247 @@
+package sim
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// bit positions of the meter events (Evt)
+const (
+	mEvtPowerFailure = 2
+	mEvtUnderVoltage = 3
+	mEvtLowPF        = 4
+	mEvtOverCurrent  = 5
+	mEvtOverVoltage  = 6
+)
+
+// Flow returns the active and reactive power of a simulated device, positive while injecting into the grid,
+// e.g. the method Power of an Inverter.
+type Flow func() (w, vars float64)
+
+// Meter simulates a revenue meter at the point of connection of a site, served by one of the models
+// 201 (single phase), 202 (split phase), 203 (three phase wye) or 204 (three phase delta).
+//
+// The metered power is the consumption of the site´s load reduced by the power flows of the simulated devices,
+// which is distributed equally across the phases of the served model.
+// Powers are positive while importing from the grid, following the meter models. The energy accumulators
+// count the imported and exported energy by the quadrants of the power flow and roll over at their range.
+type Meter struct {
+	// Flows are the power flows of the devices behind the meter, e.g. of an inverter and a battery.
+	Flows []Flow
+	// VNom and FNom are the nominal line to neutral voltage in V and frequency in Hz.
+	VNom, FNom float64
+	// Voltage and Frequency are the profiles of the grid´s line to neutral voltage in V and frequency in Hz
+	// by the simulated time in seconds. Empty profiles are taken as the nominal values.
+	Voltage, Frequency Curve
+	// AMax is the rated current per phase in A, exceeding it raises the over current event.
+	AMax float64
+
+	mu      sync.Mutex
+	id      uint16
+	elapsed float64
+	load    [2]float64
+	// the metered state
+	v, hz, p, q float64
+	// energy holds the accumulated energies in the order of exported and imported active and apparent energy,
+	// followed by the reactive energy of the quadrants 1 to 4.
+	energy [8]float64
+}
+
+var _ Simulator = (*Meter)(nil)
+
+// NewMeter returns a meter rated at 63 A per phase connected to a 230 V / 50 Hz grid.
+func NewMeter(flows ...Flow) *Meter {
+	return &Meter{
+		Flows: flows,
+		VNom:  230,
+		FNom:  50,
+		AMax:  63,
+	}
+}
+
+// SetLoad sets the active and reactive power consumed by the site´s load in W and var.
+func (m *Meter) SetLoad(w, vars float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.load = [2]float64{w, vars}
+}
+
+// SetGrid replaces the grid profiles by the constant voltage v in V and frequency hz in Hz.
+func (m *Meter) SetGrid(v, hz float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Voltage, m.Frequency = Curve{{0, v}}, Curve{{0, hz}}
+}
+
+// Power returns the current metered active and reactive power, positive while importing.
+func (m *Meter) Power() (w, vars float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.p, m.q
+}
+
+// Prepare sets the scale factors of the meter models.
+// The first of the models 201 to 204 given is simulated, the others are ignored.
+func (m *Meter) Prepare(defs ...sunspec.Definition) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.id = 0
+	for id := uint16(201); id <= 204; id++ {
+		if def := definition(defs, id); def != nil {
+			scale(&def.Group, map[string]int16{
+				"A_SF": -1, "V_SF": -1, "Hz_SF": -2, "W_SF": 1, "VA_SF": 1, "VAR_SF": 1, "PF_SF": -1,
+				"TotWh_SF": 0, "TotVAh_SF": 0, "TotVArh_SF": 0,
+			})
+			if m.id == 0 {
+				m.id = id
+			}
+		}
+	}
+	if m.id == 0 {
+		return errors.New("sim: the meter requires one of the models 201 to 204")
+	}
+	return nil
+}
+
+// Attach does nothing, as all points of the meter models are read-only.
+func (m *Meter) Attach(s *sunspec.Server) {}
+
+// Step advances the simulation by dt.
+// The power flows are sampled at the end of the step, so the meter should be stepped after the metered devices.
+func (m *Meter) Step(d sunspec.Device, dt time.Duration) error {
+	var p, q float64
+	for _, f := range m.Flows {
+		w, vars := f()
+		p, q = p-w, q-vars
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g := model(d, m.id)
+	if g == nil {
+		return errors.New("sim: the meter requires one of the models 201 to 204")
+	}
+	m.elapsed += dt.Seconds()
+	m.v, m.hz = m.VNom, m.FNom
+	if len(m.Voltage) > 0 {
+		m.v = m.Voltage.At(m.elapsed)
+	}
+	if len(m.Frequency) > 0 {
+		m.hz = m.Frequency.At(m.elapsed)
+	}
+	m.p, m.q = p+m.load[0], q+m.load[1]
+	m.accumulate(dt.Seconds())
+	return m.publish(g)
+}
+
+// accumulate integrates the metered power over dt seconds.
+func (m *Meter) accumulate(dt float64) {
+	h := dt / 3600
+	s := math.Hypot(m.p, m.q)
+	if m.p >= 0 {
+		m.energy[1] += m.p * h
+		m.energy[3] += s * h
+	} else {
+		m.energy[0] -= m.p * h
+		m.energy[2] += s * h
+	}
+	switch {
+	case m.p >= 0 && m.q >= 0:
+		m.energy[4] += m.q * h
+	case m.p < 0 && m.q >= 0:
+		m.energy[5] += m.q * h
+	case m.p < 0:
+		m.energy[6] -= m.q * h
+	default:
+		m.energy[7] -= m.q * h
+	}
+}
+
+// phases returns the names of the phases metered by the served model.
+func (m *Meter) phases() []string {
+	switch m.id {
+	case 201:
+		return []string{"A"}
+	case 202:
+		return []string{"A", "B"}
+	}
+	return []string{"A", "B", "C"}
+}
+
+// publish writes the metered state into the model.
+func (m *Meter) publish(g sunspec.Group) error {
+	var w setter
+	phs := m.phases()
+	n := float64(len(phs))
+	s := math.Hypot(m.p, m.q)
+	var pf float64
+	if s > 0 {
+		pf = 100 * m.p / s
+	}
+	a := s / n / math.Max(1, m.v)
+
+	// the line to line voltage of the split phase is across both half windings
+	vll := m.v * math.Sqrt(3)
+	if m.id == 202 {
+		vll = 2 * m.v
+	}
+	var evt []int
+	switch pu := m.v / m.VNom; {
+	case pu < 0.1:
+		evt = append(evt, mEvtPowerFailure)
+	case pu < 0.9:
+		evt = append(evt, mEvtUnderVoltage)
+	case pu > 1.1:
+		evt = append(evt, mEvtOverVoltage)
+	}
+	if m.AMax > 0 && a > m.AMax {
+		evt = append(evt, mEvtOverCurrent)
+	}
+	if s > 0.05*m.AMax*m.VNom*n && math.Abs(pf) < 50 {
+		evt = append(evt, mEvtLowPF)
+	}
+
+	w.set(g, "A", a*n)
+	w.set(g, "Hz", m.hz)
+	w.set(g, "W", m.p)
+	w.set(g, "VA", s)
+	w.set(g, "VAR", m.q)
+	w.set(g, "PF", pf)
+	// delta connected meters have no neutral to measure against
+	if m.id != 204 {
+		w.set(g, "PhV", m.v)
+	}
+	if m.id != 201 {
+		w.set(g, "PPV", vll)
+	}
+	for _, ph := range phs {
+		w.set(g, "Aph"+ph, a)
+		w.set(g, "Wph"+ph, m.p/n)
+		w.set(g, "VAph"+ph, s/n)
+		w.set(g, "VARph"+ph, m.q/n)
+		w.set(g, "PFph"+ph, pf)
+		if m.id != 204 {
+			w.set(g, "PhVph"+ph, m.v)
+		}
+	}
+	switch m.id {
+	case 202:
+		w.set(g, "PPVphAB", vll)
+	case 203, 204:
+		for _, ll := range [...]string{"AB", "BC", "CA"} {
+			w.set(g, "PPVph"+ll, vll)
+		}
+	}
+	names := [...]string{"TotWhExp", "TotWhImp", "TotVAhExp", "TotVAhImp", "TotVArhImpQ1", "TotVArhImpQ2", "TotVArhExpQ3", "TotVArhExpQ4"}
+	for i, name := range names {
+		w.set(g, name, m.energy[i])
+		for _, ph := range phs {
+			w.set(g, name+"Ph"+ph, m.energy[i]/n)
+		}
+	}
+	w.set(g, "Evt", bits(evt...))
+	return w.err
+}
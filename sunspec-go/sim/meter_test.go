@@ -0,0 +1,67 @@
+package sim
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMeter(t *testing.T) {
+	pv := [2]float64{3000, 0}
+	m := NewMeter(func() (w, vars float64) { return pv[0], pv[1] })
+	d := instance(t, m, 203)
+	m.SetLoad(1000, 500)
+
+	// exporting the surplus of the inverter, while the load draws reactive power
+	step(t, m, d, 3600, time.Second)
+	near(t, d, "203.W", -2000, 0)
+	near(t, d, "203.VAR", 500, 0)
+	near(t, d, "203.VA", math.Hypot(2000, 500), 10)
+	near(t, d, "203.WphA", -2000.0/3, 10)
+	near(t, d, "203.PF", -100*2000/math.Hypot(2000, 500), 0.1)
+	near(t, d, "203.PhVphA", 230, 0)
+	near(t, d, "203.PPVphAB", 230*math.Sqrt(3), 0.1)
+	near(t, d, "203.Hz", 50, 0)
+	near(t, d, "203.A", math.Hypot(2000, 500)/230, 0.1)
+	near(t, d, "203.TotWhExp", 2000, 1)
+	near(t, d, "203.TotWhExpPhA", 2000.0/3, 1)
+	near(t, d, "203.TotVAhExp", math.Hypot(2000, 500), 1)
+	near(t, d, "203.TotVArhImpQ2", 500, 1)
+	near(t, d, "203.Evt", 0, 0)
+
+	// importing, the accumulators of the other quadrants count on
+	pv = [2]float64{0, 1500}
+	step(t, m, d, 1800, time.Second)
+	near(t, d, "203.W", 1000, 0)
+	near(t, d, "203.VAR", -1000, 0)
+	near(t, d, "203.TotWhImp", 500, 1)
+	near(t, d, "203.TotWhExp", 2000, 1)
+	near(t, d, "203.TotVArhExpQ4", 500, 1)
+	near(t, d, "203.TotVArhImpQ2", 500, 1)
+
+	// a grid outage is reported as power failure
+	m.SetGrid(0, 50)
+	step(t, m, d, 1, time.Second)
+	if evt := uint32(value(t, d, "203.Evt")); evt&(1<<mEvtPowerFailure) == 0 {
+		t.Errorf("expected the power failure event, got %#x", evt)
+	}
+
+	// the accumulators roll over at their range
+	m.energy[1] = 1<<32 + 10
+	m.SetGrid(230, 50)
+	m.SetLoad(0, 0)
+	pv = [2]float64{0, 0}
+	step(t, m, d, 1, time.Second)
+	near(t, d, "203.TotWhImp", 10, 0)
+
+	// the single phase meter
+	m = NewMeter()
+	d = instance(t, m, 201)
+	m.SetLoad(2300, 0)
+	step(t, m, d, 1, time.Second)
+	near(t, d, "201.WphA", 2300, 0)
+	near(t, d, "201.AphA", 10, 0)
+	if err := NewMeter().Prepare(definitions(t, 802)...); err == nil {
+		t.Error("expected an error preparing a meter without meter models")
+	}
+}
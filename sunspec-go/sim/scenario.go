@@ -0,0 +1,369 @@
+package sim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// Scenario is a time series of environment events played against simulated devices, for instance:
+//
+//	{
+//		"seed": 42, "step": 1, "duration": 3600,
+//		"events": [
+//			{"at": 0, "target": "pv", "set": "irradiance", "value": 200, "ramp": 600, "noise": 20},
+//			{"at": 900, "target": "site", "set": "load", "value": 8000, "vars": 1500},
+//			{"at": 1800, "set": "grid", "value": 0, "hz": 50},
+//			{"at": 1800.2, "set": "grid", "value": 230, "hz": 50}
+//		]
+//	}
+type Scenario struct {
+	// Seed seeds the random noise of the events and the random behavior of the simulators,
+	// so that playing the scenario again yields the same results.
+	Seed int64 `json:"seed"`
+	// Step is the simulated time in seconds advanced by each step, defaulting to one second.
+	Step float64 `json:"step"`
+	// Duration is the simulated time in seconds after which the scenario ends.
+	// Scenarios without a duration are played until canceled.
+	Duration float64 `json:"duration"`
+	// Events are the changes of the environment, which are applied in the order of their time.
+	Events []Event `json:"events"`
+}
+
+// Event changes a quantity of the environment of the simulated devices at the given time.
+//
+// The quantity is given by Set and applied to all simulators of the target supporting it:
+//
+//	"load"        the site´s load in W (Value) and var (Vars) of a Meter
+//	"demand"      the power in W demanded from a Battery
+//	"available"   the power in W available to an Inverter
+//	"irradiance"  the irradiance in W/m² of the PV array of an Inverter
+//	"ambient"     the ambient temperature in °C of a Battery or Inverter
+//	"grid"        the grid´s voltage in V (Value) and frequency in Hz (Hz) of an Inverter or Meter
+//
+// Grid events must always give the frequency, as a zero frequency would trip the inverters.
+type Event struct {
+	// At is the simulated time of the event in seconds.
+	At float64 `json:"at"`
+	// Target is the name of the device the event applies to, an empty target applies to all devices.
+	Target string `json:"target"`
+	// Set is the quantity changed by the event.
+	Set string `json:"set"`
+	// Value, Vars and Hz are the new values of the quantity.
+	Value float64 `json:"value"`
+	Vars  float64 `json:"vars"`
+	Hz    float64 `json:"hz"`
+	// Ramp is the time in seconds in which the quantity changes linearly from its previous value.
+	Ramp float64 `json:"ramp"`
+	// Noise is the standard deviation of the normally distributed noise added to the Value at every step.
+	Noise float64 `json:"noise"`
+}
+
+// setters bind the setter of a quantity of a simulator, nil if the simulator does not support the quantity.
+var setters = map[string]func(s Simulator) func(v [2]float64){
+	"load": func(s Simulator) func(v [2]float64) {
+		if x, ok := s.(interface{ SetLoad(w, vars float64) }); ok {
+			return func(v [2]float64) { x.SetLoad(v[0], v[1]) }
+		}
+		return nil
+	},
+	"demand": func(s Simulator) func(v [2]float64) {
+		if x, ok := s.(interface{ SetDemand(w float64) }); ok {
+			return func(v [2]float64) { x.SetDemand(v[0]) }
+		}
+		return nil
+	},
+	"available": func(s Simulator) func(v [2]float64) {
+		if x, ok := s.(interface{ SetAvailable(w float64) }); ok {
+			return func(v [2]float64) { x.SetAvailable(v[0]) }
+		}
+		return nil
+	},
+	"irradiance": func(s Simulator) func(v [2]float64) {
+		if x, ok := s.(interface{ SetIrradiance(wm2 float64) }); ok {
+			return func(v [2]float64) { x.SetIrradiance(v[0]) }
+		}
+		return nil
+	},
+	"ambient": func(s Simulator) func(v [2]float64) {
+		if x, ok := s.(interface{ SetAmbient(c float64) }); ok {
+			return func(v [2]float64) { x.SetAmbient(v[0]) }
+		}
+		return nil
+	},
+	"grid": func(s Simulator) func(v [2]float64) {
+		if x, ok := s.(interface{ SetGrid(v, hz float64) }); ok {
+			return func(v [2]float64) { x.SetGrid(v[0], v[1]) }
+		}
+		return nil
+	},
+}
+
+// values returns the values of the event´s quantity.
+func (e Event) values() [2]float64 {
+	switch e.Set {
+	case "load":
+		return [2]float64{e.Value, e.Vars}
+	case "grid":
+		return [2]float64{e.Value, e.Hz}
+	}
+	return [2]float64{e.Value, 0}
+}
+
+// check verifies the quantity and values of the event.
+func (e Event) check() error {
+	if _, ok := setters[e.Set]; !ok {
+		return fmt.Errorf("sim: unknown quantity %q of the event at %vs", e.Set, e.At)
+	}
+	if e.Set == "grid" && e.Hz <= 0 {
+		return fmt.Errorf("sim: the grid event at %vs gives no frequency", e.At)
+	}
+	return nil
+}
+
+// LoadScenario reads a scenario from a json file, other formats like YAML are not supported.
+// An error is returned if any event is invalid, e.g. a grid event without frequency.
+func LoadScenario(name string) (*Scenario, error) {
+	if ext := strings.ToLower(filepath.Ext(name)); ext == ".yaml" || ext == ".yml" {
+		return nil, fmt.Errorf("sim: scenario file %q is not supported, only json is", name)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return nil, fmt.Errorf("sim: invalid scenario file %q: %w", name, err)
+	}
+	for _, ev := range sc.Events {
+		if err := ev.check(); err != nil {
+			return nil, err
+		}
+	}
+	return &sc, nil
+}
+
+// Engine plays a scenario against the simulated devices of one or more servers.
+//
+// The simulated time advances by the scenario´s step, independently of the time passed in reality.
+// Thus a scenario played with the same seed always yields the same results, regardless of its speed.
+type Engine struct {
+	// Scenario is the played scenario.
+	Scenario *Scenario
+	// Speed is the ratio of the simulated to the real time, e.g. 60 plays an hour within a minute.
+	// A speed of zero plays the scenario as fast as possible.
+	Speed float64
+	// Logger optionally logs the applied events.
+	Logger sunspec.Logger
+
+	devices []*device
+}
+
+// device is a server driven by simulators.
+type device struct {
+	name   string
+	server *sunspec.Server
+	sims   []Simulator
+}
+
+// track is the course of a quantity of a device, as given by its latest event.
+type track struct {
+	dev        *device
+	set        string
+	from, to   [2]float64
+	at, ramp   float64
+	noise      float64
+	seen, done bool
+}
+
+// NewEngine returns an engine playing the scenario at the given speed.
+func NewEngine(sc *Scenario, speed float64) *Engine {
+	return &Engine{Scenario: sc, Speed: speed}
+}
+
+// Add registers the server and its simulators as device of the given name, referenced by the targets of the events.
+// The simulators are stepped in the given order.
+func (e *Engine) Add(name string, s *sunspec.Server, sims ...Simulator) {
+	e.devices = append(e.devices, &device{name: name, server: s, sims: sims})
+}
+
+// Run plays the scenario until its duration is reached or the context is canceled.
+// Steps are held back while a server is not yet serving, so no part of the scenario is skipped.
+func (e *Engine) Run(ctx cancel.Context) error {
+	if e.Scenario == nil {
+		return errors.New("sim: no scenario given")
+	}
+	events, err := e.events()
+	if err != nil {
+		return err
+	}
+	dt := e.Scenario.Step
+	if dt <= 0 {
+		dt = 1
+	}
+	rnd := rand.New(rand.NewSource(e.Scenario.Seed))
+	// every inverter draws its own random sequence, derived from the scenario´s seed
+	var n int64
+	for _, d := range e.devices {
+		for _, s := range d.sims {
+			if inv, ok := s.(*Inverter); ok {
+				inv.Seed = e.Scenario.Seed + n
+				n++
+			}
+		}
+	}
+	var tracks []*track
+	start := time.Now()
+	for k := 0; ; k++ {
+		t := float64(k) * dt
+		if e.Scenario.Duration > 0 && t >= e.Scenario.Duration {
+			return nil
+		}
+		for len(events) > 0 && events[0].At <= t {
+			tracks = e.apply(tracks, events[0], t)
+			events = events[1:]
+		}
+		for _, tr := range tracks {
+			tr.update(t, rnd)
+		}
+		for _, d := range e.devices {
+			if err := d.step(ctx, dt); err != nil {
+				return err
+			}
+		}
+		if e.Speed > 0 {
+			next := start.Add(time.Duration(float64(k+1) * dt / e.Speed * float64(time.Second)))
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Until(next)):
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+	}
+}
+
+// events returns the events of the scenario in the order of their time.
+// An error is returned if an event is invalid or no device supports its quantity.
+func (e *Engine) events() ([]Event, error) {
+	events := append([]Event(nil), e.Scenario.Events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+	for _, ev := range events {
+		if err := ev.check(); err != nil {
+			return nil, err
+		}
+		bind := setters[ev.Set]
+		var supported bool
+		for _, d := range e.targets(ev.Target) {
+			for _, s := range d.sims {
+				supported = supported || bind(s) != nil
+			}
+		}
+		if !supported {
+			return nil, fmt.Errorf("sim: no device %q supports the quantity %q of the event at %vs", ev.Target, ev.Set, ev.At)
+		}
+	}
+	return events, nil
+}
+
+// targets returns the devices referenced by the target name, all devices if it is empty.
+func (e *Engine) targets(name string) (col []*device) {
+	for _, d := range e.devices {
+		if name == "" || name == d.name {
+			col = append(col, d)
+		}
+	}
+	return col
+}
+
+// apply starts the course of the event´s quantity at the simulated time t on all targeted devices.
+func (e *Engine) apply(tracks []*track, ev Event, t float64) []*track {
+	if e.Logger != nil {
+		e.Logger.Info("scenario at", t, "s: setting", ev.Set, "of", ev.Target, "to", ev.values())
+	}
+	for _, d := range e.targets(ev.Target) {
+		var tr *track
+		for _, x := range tracks {
+			if x.dev == d && x.set == ev.Set {
+				tr = x
+			}
+		}
+		if tr == nil {
+			tr = &track{dev: d, set: ev.Set}
+			tracks = append(tracks, tr)
+		}
+		// a ramp starts at the current value, which is unknown for the first event
+		if tr.seen {
+			tr.from = tr.value(t)
+		} else {
+			tr.from = ev.values()
+		}
+		tr.to, tr.at, tr.ramp, tr.noise = ev.values(), ev.At, ev.Ramp, ev.Noise
+		tr.seen, tr.done = true, false
+	}
+	return tracks
+}
+
+// value returns the value of the quantity at the simulated time t, without noise.
+func (tr *track) value(t float64) [2]float64 {
+	if tr.ramp <= 0 || t >= tr.at+tr.ramp {
+		return tr.to
+	}
+	f := (t - tr.at) / tr.ramp
+	return [2]float64{tr.from[0] + (tr.to[0]-tr.from[0])*f, tr.from[1] + (tr.to[1]-tr.from[1])*f}
+}
+
+// update applies the quantity at the simulated time t to the simulators of the device.
+// Constant quantities are applied once, ramps and noise at every step.
+func (tr *track) update(t float64, rnd *rand.Rand) {
+	if tr.done {
+		return
+	}
+	v := tr.value(t)
+	if tr.noise > 0 {
+		v[0] += tr.noise * rnd.NormFloat64()
+	}
+	for _, s := range tr.dev.sims {
+		if set := setters[tr.set](s); set != nil {
+			set(v)
+		}
+	}
+	tr.done = tr.noise <= 0 && (tr.ramp <= 0 || t >= tr.at+tr.ramp)
+}
+
+// step advances the simulators of the device by dt seconds within a single update of the server.
+// While the server is not serving, the step is retried until the context is canceled.
+func (d *device) step(ctx cancel.Context, dt float64) error {
+	for {
+		err := d.server.Update(func(dev sunspec.Device) error {
+			for _, s := range d.sims {
+				if err := s.Step(dev, time.Duration(dt*float64(time.Second))); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if !errors.Is(err, sunspec.ErrNotServing) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
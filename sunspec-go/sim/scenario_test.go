@@ -0,0 +1,151 @@
+package sim
+
+import (
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// serve serves the models prepared by the simulator on a free local endpoint.
+func serve(t *testing.T, ctx cancel.Context, s Simulator, ids ...uint16) *sunspec.Server {
+	t.Helper()
+	defs := definitions(t, ids...)
+	if err := s.Prepare(defs...); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := l.Addr().String()
+	l.Close()
+	srv := sunspec.Config{Endpoint: endpoint}.Server()
+	s.Attach(srv)
+	go srv.Serve(ctx, func(ctx cancel.Context, req sunspec.Request) error {
+		defer req.Flush()
+		return req.Ingest()
+	}, defs...)
+	return srv
+}
+
+// play plays the scenario against an inverter "pv" and a meter "site" metering it.
+// It returns the published values of the paths of both devices once the scenario ended.
+func play(t *testing.T, sc *Scenario, paths ...string) []float64 {
+	t.Helper()
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	inv := NewInverter()
+	m := NewMeter(inv.Power)
+	pv, site := serve(t, ctx, inv, 701), serve(t, ctx, m, 203)
+	e := NewEngine(sc, 0)
+	e.Add("pv", pv, inv)
+	e.Add("site", site, m)
+	if err := e.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var col []float64
+	for _, path := range paths {
+		for _, s := range [...]*sunspec.Server{pv, site} {
+			if err := s.View(func(d sunspec.Device) error {
+				if v, err := d.Value(path); err == nil {
+					col = append(col, v)
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(col) != len(paths) {
+		t.Fatalf("expected the values of %v, got %v", paths, col)
+	}
+	return col
+}
+
+func TestScenario(t *testing.T) {
+	// the load ramps up from its first value, the grid applies to all devices
+	got := play(t, &Scenario{Duration: 5, Events: []Event{
+		{At: 2, Target: "site", Set: "load", Value: 4000, Vars: 1000, Ramp: 4},
+		{At: 0, Target: "site", Set: "load"},
+		{At: 0, Target: "pv", Set: "irradiance"},
+		{At: 0, Set: "grid", Value: 230, Hz: 49.9},
+	}}, "203.W", "203.VAR", "203.Hz", "701.W", "701.Hz")
+	for i, want := range []float64{2000, 500, 49.9, 0, 49.9} {
+		if math.Abs(got[i]-want) > 1e-6 {
+			t.Errorf("expected the value %v to be %v, got %v", i, want, got[i])
+		}
+	}
+
+	// the noise and the inverters are reproduced by the seed
+	noisy := func(seed int64) *Scenario {
+		return &Scenario{Seed: seed, Step: 10, Duration: 3600, Events: []Event{
+			{At: 0, Target: "pv", Set: "irradiance", Value: 200, Ramp: 600, Noise: 50},
+			{At: 0, Target: "site", Set: "load", Value: 3000, Noise: 500},
+			{At: 1800, Set: "grid", Value: 230, Hz: 53},
+			{At: 1800.2, Set: "grid", Value: 230, Hz: 50},
+		}}
+	}
+	paths := []string{"701.W", "701.TotWhInj", "203.W", "203.TotWhImp", "203.TotWhExp"}
+	a, b, c := play(t, noisy(42), paths...), play(t, noisy(42), paths...), play(t, noisy(43), paths...)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("expected the scenario to yield the same values with the same seed, got %v and %v", a, b)
+	}
+	if reflect.DeepEqual(a, c) {
+		t.Errorf("expected the scenario to yield other values with another seed, got %v", c)
+	}
+
+	// events of unsupported quantities are rejected
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	e := NewEngine(&Scenario{Events: []Event{{Set: "demand", Value: 1000}}}, 0)
+	e.Add("site", sunspec.Config{}.Server(), NewMeter())
+	if err := e.Run(ctx); err == nil {
+		t.Error("expected an error playing an event no device supports")
+	}
+	if err := NewEngine(nil, 0).Run(ctx); err == nil {
+		t.Error("expected an error playing no scenario")
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	testCases := []struct {
+		json string
+		fail bool
+	}{
+		{json: `{"events": [{"at": 0, "set": "grid", "value": 230, "hz": 50}]}`},
+		{json: `{"events": [{"at": 0, "target": "pv", "set": "irradiance", "value": 200, "ramp": 600}]}`},
+		{json: `{"events": [{"at": 0, "set": "grid", "value": 230}]}`, fail: true},
+		{json: `{"events": [{"at": 0, "set": "grid", "value": 0, "hz": -1}]}`, fail: true},
+		{json: `{"events": [{"at": 0, "set": "wind", "value": 10}]}`, fail: true},
+		{json: `{"events": [`, fail: true},
+	}
+	for _, tc := range testCases {
+		name := filepath.Join(t.TempDir(), "scenario.json")
+		if err := os.WriteFile(name, []byte(tc.json), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadScenario(name)
+		switch {
+		case tc.fail && err == nil:
+			t.Fatalf("loading the scenario %v did not fail", tc.json)
+		case !tc.fail && err != nil:
+			t.Fatalf("loading the scenario %v failed: %v", tc.json, err)
+		}
+	}
+	if _, err := LoadScenario(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("loading a missing scenario file did not fail")
+	}
+	name := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(name, []byte("events:\n  - {at: 0, set: grid, value: 230, hz: 50}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScenario(name); err == nil {
+		t.Fatal("loading a yaml scenario file did not fail")
+	}
+}
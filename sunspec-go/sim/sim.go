@@ -0,0 +1,248 @@
+// Package sim provides simulated devices driving the values served by a sunspec.Server.
+//
+// A simulator reads the setpoints written by clients from the served device and publishes its
+// simulated state into it. Points not contained by the served model definitions are skipped,
+// so that simulators work with any revision of the definitions.
+//
+//	s := sunspec.Config{Endpoint: ":502"}.Server()
+//	b := sim.NewBattery()
+//	b.Prepare(defs...)
+//	b.Attach(s)
+//	go sim.Run(ctx, s, time.Second, b)
+//	s.Serve(ctx, handler, defs...)
+//
+// Scenarios of environment events are played against the simulators by an Engine.
+// They are read from json files, other formats like YAML are not supported.
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// Simulator drives the point values of a served device.
+type Simulator interface {
+	// Prepare adapts the definitions of the simulated models before serving, e.g. setting the
+	// scale factors or the number of repeating groups. Definitions of other models are ignored.
+	Prepare(defs ...sunspec.Definition) error
+	// Attach prepares the server for the simulator, e.g. by registering write hooks.
+	// It must be called before the server is serving.
+	Attach(s *sunspec.Server)
+	// Step advances the simulation by dt.
+	// The setpoints are read from the device and the resulting state is published into it.
+	Step(d sunspec.Device, dt time.Duration) error
+}
+
+// Run steps the simulators every interval until the context is canceled.
+// Each step is applied exclusively by the server´s Update, so clients always read consistent values.
+// Steps before the server is serving are skipped.
+func Run(ctx cancel.Context, s *sunspec.Server, interval time.Duration, sims ...Simulator) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			err := s.Update(func(d sunspec.Device) error {
+				for _, sim := range sims {
+					if err := sim.Step(d, interval); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, sunspec.ErrNotServing) {
+				return err
+			}
+		}
+	}
+}
+
+// definition returns the model definition of the given id, nil if none is given.
+func definition(defs []sunspec.Definition, id uint16) *sunspec.ModelDef {
+	for _, def := range defs {
+		if def, ok := def.(*sunspec.ModelDef); ok && def.Id == id {
+			return def
+		}
+	}
+	return nil
+}
+
+// count sets the number of occurrences of the repeating group in the definition, given by its path of group names,
+// e.g. "Crv.Pt". Servers instantiate repeating groups by their count, which is often not given (0) by the definitions.
+func count(def *sunspec.ModelDef, path string, n int) error {
+	g := &def.Group
+next:
+	for _, name := range strings.Split(path, ".") {
+		for i := range g.Groups {
+			if g.Groups[i].Name == name {
+				g = &g.Groups[i]
+				continue next
+			}
+		}
+		return fmt.Errorf("sim: model %v has no group %q", def.Id, path)
+	}
+	g.Count = float64(n)
+	return nil
+}
+
+// scale sets the values of the named scale factors in the group definition and all its sub-groups.
+// Scale factors not contained by the definition are skipped.
+func scale(def *sunspec.GroupDef, factors map[string]int16) {
+	for i, p := range def.Points {
+		if sf, ok := factors[p.Name]; ok && p.Type == "sunssf" {
+			def.Points[i].Value = sf
+		}
+	}
+	for i := range def.Groups {
+		scale(&def.Groups[i], factors)
+	}
+}
+
+// ****************************************************************************
+
+// Point is a single point of a curve.
+type Point struct {
+	X, Y float64
+}
+
+// Curve is a piecewise linear function, given by its points in ascending order of X.
+type Curve []Point
+
+// At returns the linear interpolation of the curve at x.
+// Beyond the first and last point the curve is continued constantly.
+func (c Curve) At(x float64) float64 {
+	if len(c) == 0 {
+		return 0
+	}
+	i := sort.Search(len(c), func(i int) bool { return c[i].X >= x })
+	switch {
+	case i == 0:
+		return c[0].Y
+	case i == len(c):
+		return c[len(c)-1].Y
+	}
+	a, b := c[i-1], c[i]
+	return a.Y + (b.Y-a.Y)*(x-a.X)/(b.X-a.X)
+}
+
+// ****************************************************************************
+
+// set assigns the scaled value v to the named point of the group.
+// Points not contained by the group are skipped, values exceeding the point´s range are clamped
+// and accumulators roll over.
+func set(g sunspec.Group, name string, v float64) error {
+	if g == nil {
+		return nil
+	}
+	pt := g.Point(name)
+	switch p := pt.(type) {
+	case nil:
+		return nil
+	case interface{ SetValue(v float64) error }:
+		if err := p.SetValue(v); err != nil {
+			return p.SetValue(clamp(pt, v))
+		}
+		return nil
+	case sunspec.Enum16:
+		return p.Set(uint16(v))
+	case sunspec.Enum32:
+		return p.Set(uint32(v))
+	case sunspec.Acc16:
+		return p.Set(uint16(wrap(v, p.Factor(), 1<<16)))
+	case sunspec.Acc32:
+		return p.Set(uint32(wrap(v, p.Factor(), 1<<32)))
+	case sunspec.Acc64:
+		return p.Set(uint64(wrap(v, p.Factor(), 1<<64)))
+	case sunspec.Bitfield16:
+		return p.Set(uint16(v))
+	case sunspec.Bitfield32:
+		return p.Set(uint32(v))
+	case sunspec.Bitfield64:
+		return p.Set(uint64(v))
+	}
+	return fmt.Errorf("sim: point %q can not be set to a numeric value", name)
+}
+
+// clamp limits v to the range representable by the scaled point.
+func clamp(p sunspec.Point, v float64) float64 {
+	var min, max float64
+	var sf int16
+	switch p := p.(type) {
+	case sunspec.Int16:
+		min, max, sf = math.MinInt16+1, math.MaxInt16, p.Factor()
+	case sunspec.Int32:
+		min, max, sf = math.MinInt32+1, math.MaxInt32, p.Factor()
+	case sunspec.Uint16:
+		min, max, sf = 0, math.MaxUint16-1, p.Factor()
+	case sunspec.Uint32:
+		min, max, sf = 0, math.MaxUint32-1, p.Factor()
+	default:
+		return v
+	}
+	f := math.Pow10(int(sf))
+	return math.Max(min*f, math.Min(max*f, v))
+}
+
+// wrap returns the raw value of an accumulator with the scale factor sf holding the scaled value v.
+// The accumulator rolls over at its range n, negative values are counted backwards from it.
+func wrap(v float64, sf int16, n float64) float64 {
+	r := math.Mod(math.Floor(v*math.Pow10(-int(sf))), n)
+	if r < 0 {
+		r += n
+	}
+	// the float64 representation of the range´s maximum may round up to the range itself
+	return math.Min(r, math.Nextafter(n, 0))
+}
+
+// get returns the scaled value of the named point of the group.
+// If the point is not contained or not implemented ok is false.
+func get(g sunspec.Group, name string) (v float64, ok bool) {
+	if g == nil {
+		return 0, false
+	}
+	p := g.Point(name)
+	if p == nil {
+		return 0, false
+	}
+	v, err := sunspec.Scaled(p)
+	return v, err == nil
+}
+
+// bits returns the bitfield value with all given bits set.
+func bits(positions ...int) float64 {
+	var v uint32
+	for _, b := range positions {
+		v |= 1 << b
+	}
+	return float64(v)
+}
+
+// model returns the first model of the device identified by id as group.
+// If the device has no such model nil is returned.
+func model(d sunspec.Device, id uint16) sunspec.Group {
+	if m := d.Model(id); m != nil {
+		return m
+	}
+	return nil
+}
+
+// setter collects the first error of consecutive set calls.
+type setter struct {
+	err error
+}
+
+// set assigns the scaled value v to the named point of the group, see set.
+func (s *setter) set(g sunspec.Group, name string, v float64) {
+	if err := set(g, name, v); err != nil && s.err == nil {
+		s.err = err
+	}
+}
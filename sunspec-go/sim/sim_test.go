@@ -0,0 +1,202 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+// definitions reads the example definitions of the models.
+func definitions(t *testing.T, ids ...uint16) []sunspec.Definition {
+	t.Helper()
+	var defs []sunspec.Definition
+	for _, id := range ids {
+		b, err := os.ReadFile(fmt.Sprintf("../examples/basic/model%v.json", id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var def sunspec.ModelDef
+		if err := json.Unmarshal(b, &def); err != nil {
+			t.Fatal(err)
+		}
+		defs = append(defs, &def)
+	}
+	return defs
+}
+
+// instance instantiates the models prepared by the simulator in memory.
+func instance(t *testing.T, s Simulator, ids ...uint16) sunspec.Models {
+	t.Helper()
+	defs := definitions(t, ids...)
+	if err := s.Prepare(defs...); err != nil {
+		t.Fatal(err)
+	}
+	var d sunspec.Models
+	adr := uint16(40002)
+	for _, def := range defs {
+		m, err := def.Instance(adr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, adr = append(d, m), adr+m.Quantity()
+	}
+	return d
+}
+
+// step advances the simulator n times by dt.
+func step(t *testing.T, s Simulator, d sunspec.Device, n int, dt time.Duration) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := s.Step(d, dt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// value returns the published scaled value of the single point referenced by the path.
+func value(t *testing.T, d sunspec.Device, path string) float64 {
+	t.Helper()
+	v, err := d.Value(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// write assigns the scaled value to the point referenced by the path, like a client.
+func write(t *testing.T, d sunspec.Device, path string, v float64) {
+	t.Helper()
+	pts, err := d.Query(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pts {
+		if err := set(p.Origin(), p.Name(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// near verifies that the published value of the path is within tol of want.
+func near(t *testing.T, d sunspec.Device, path string, want, tol float64) {
+	t.Helper()
+	if v := value(t, d, path); math.Abs(v-want) > tol {
+		t.Errorf("expected %v to be %v ± %v, got %v", path, want, tol, v)
+	}
+}
+
+func TestCurve(t *testing.T) {
+	c := Curve{{0, 10}, {10, 20}, {20, 0}}
+	testCases := []struct {
+		curve Curve
+		x     float64
+		want  float64
+	}{
+		{curve: nil, x: 5, want: 0},
+		{curve: Curve{{3, 7}}, x: -5, want: 7},
+		{curve: Curve{{3, 7}}, x: 5, want: 7},
+		{curve: c, x: -1, want: 10},
+		{curve: c, x: 0, want: 10},
+		{curve: c, x: 2.5, want: 12.5},
+		{curve: c, x: 10, want: 20},
+		{curve: c, x: 15, want: 10},
+		{curve: c, x: 20, want: 0},
+		{curve: c, x: 100, want: 0},
+	}
+	for _, tc := range testCases {
+		if v := tc.curve.At(tc.x); v != tc.want {
+			t.Fatalf("curve %v at %v returned %v; want %v", tc.curve, tc.x, v, tc.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	testCases := []struct {
+		value float64
+		sf    int16
+		n     float64
+		want  float64
+	}{
+		{value: 1234, sf: 0, n: 1 << 16, want: 1234},
+		{value: 1234.9, sf: 0, n: 1 << 16, want: 1234},
+		{value: 12345, sf: 1, n: 1 << 16, want: 1234},
+		{value: 12.345, sf: -2, n: 1 << 16, want: 1234},
+		{value: 65536 + 10, sf: 0, n: 1 << 16, want: 10},
+		{value: -1, sf: 0, n: 1 << 16, want: 65535},
+		{value: 1<<32 + 5, sf: 0, n: 1 << 32, want: 5},
+		{value: -1, sf: 0, n: 1 << 64, want: math.Nextafter(1<<64, 0)},
+	}
+	for _, tc := range testCases {
+		if v := wrap(tc.value, tc.sf, tc.n); v != tc.want {
+			t.Fatalf("wrapping %v with sf %v at %v returned %v; want %v", tc.value, tc.sf, tc.n, v, tc.want)
+		}
+	}
+}
+
+// ranges is a model of scaled points of the numeric types.
+const ranges = `{"id": 64040, "group": {"name": "ranges", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64040},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "I16", "type": "int16", "size": 1, "sf": "SF"},
+	{"name": "U16", "type": "uint16", "size": 1},
+	{"name": "I32", "type": "int32", "size": 2, "sf": "SF"},
+	{"name": "U32", "type": "uint32", "size": 2, "sf": "SF"},
+	{"name": "E16", "type": "enum16", "size": 1},
+	{"name": "Acc", "type": "acc16", "size": 1},
+	{"name": "SF", "type": "sunssf", "size": 1, "value": -1}]}}`
+
+func TestClamp(t *testing.T) {
+	var def sunspec.ModelDef
+	if err := json.Unmarshal([]byte(ranges), &def); err != nil {
+		t.Fatal(err)
+	}
+	m, err := def.Instance(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name  string
+		value float64
+		want  float64
+	}{
+		{name: "I16", value: 123.4, want: 123.4},
+		{name: "I16", value: 5000, want: 3276.7},
+		{name: "I16", value: -5000, want: -3276.7},
+		{name: "U16", value: -5, want: 0},
+		{name: "U16", value: 70000, want: 65534},
+		{name: "I32", value: -1e10, want: -214748364.7},
+		{name: "U32", value: 1e10, want: 429496729.4},
+		{name: "E16", value: 1e10, want: 1e10},
+	}
+	for _, tc := range testCases {
+		if v := clamp(m.Point(tc.name), tc.value); math.Abs(v-tc.want) > 1e-6 {
+			t.Fatalf("clamping %v to %v returned %v; want %v", tc.value, tc.name, v, tc.want)
+		}
+	}
+
+	// values are clamped to the range and accumulators roll over when published
+	for _, tc := range []struct {
+		name  string
+		value float64
+		want  float64
+	}{
+		{name: "I16", value: 5000, want: 3276.7},
+		{name: "U16", value: -5, want: 0},
+		{name: "Acc", value: 65536 + 10, want: 10},
+	} {
+		if err := set(m, tc.name, tc.value); err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := get(m, tc.name); !ok || math.Abs(v-tc.want) > 1e-6 {
+			t.Fatalf("setting %v to %v published %v; want %v", tc.name, tc.value, v, tc.want)
+		}
+	}
+	if err := set(m, "Unknown", 1); err != nil {
+		t.Fatalf("setting an unknown point failed: %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package sunspec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Store persists the values served by a server, so that they survive restarts.
+// Values are the raw register contents of the points, identified by a key derived from the point´s path.
+type Store interface {
+	// Load returns all stored values.
+	Load() (map[string][]byte, error)
+	// Save stores the given values, replacing previous values of the same keys.
+	Save(values map[string][]byte) error
+}
+
+// keys returns the unique store keys of the points.
+// The key is the point´s path, suffixed by #n for the n-th duplicate of a path (e.g. repeated models).
+func keys(pts Points) map[Point]string {
+	col := make(map[Point]string, len(pts))
+	seen := make(map[string]int, len(pts))
+	for _, p := range pts {
+		key := Path(p)
+		if n := seen[key]; n > 0 {
+			col[p] = key + "#" + strconv.Itoa(n)
+		} else {
+			col[p] = key
+		}
+		seen[key]++
+	}
+	return col
+}
+
+// values returns the raw register contents of the given points by their store key.
+func values(pts Points, keys map[Point]string) (map[string][]byte, error) {
+	col := make(map[string][]byte, len(pts))
+	for _, p := range pts {
+		buf := make([]byte, 2*p.Quantity())
+		if err := p.encode(buf); err != nil {
+			return nil, err
+		}
+		col[keys[p]] = buf
+	}
+	return col, nil
+}
+
+// ****************************************************************************
+
+// MemoryStore keeps the values in memory only.
+// It is mainly intended for testing, or for sharing values between consecutive servers of a process.
+type MemoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns a new empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: make(map[string][]byte)}
+}
+
+// Load returns all stored values.
+func (s *MemoryStore) Load() (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	col := make(map[string][]byte, len(s.values))
+	for k, v := range s.values {
+		col[k] = append([]byte(nil), v...)
+	}
+	return col, nil
+}
+
+// Save stores the given values, replacing previous values of the same keys.
+func (s *MemoryStore) Save(values map[string][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, v := range values {
+		s.values[k] = append([]byte(nil), v...)
+	}
+	return nil
+}
+
+// ****************************************************************************
+
+// SnapshotStore keeps all values in a single json file.
+// The file is completely rewritten on every save, replacing the previous one atomically.
+// This suits devices with few or rarely written values.
+type SnapshotStore struct {
+	name string
+	mem  *MemoryStore
+	once sync.Once
+	err  error
+}
+
+var _ Store = (*SnapshotStore)(nil)
+
+// NewSnapshotStore returns a store persisting into the named file.
+// A missing file is created on the first save.
+func NewSnapshotStore(name string) *SnapshotStore {
+	return &SnapshotStore{name: name, mem: NewMemoryStore()}
+}
+
+// load reads the file into memory.
+func (s *SnapshotStore) load() error {
+	s.once.Do(func() {
+		b, err := os.ReadFile(s.name)
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		} else if err != nil {
+			s.err = err
+			return
+		}
+		var values map[string][]byte
+		if err := json.Unmarshal(b, &values); err != nil {
+			s.err = fmt.Errorf("sunspec: invalid snapshot %q: %w", s.name, err)
+			return
+		}
+		s.err = s.mem.Save(values)
+	})
+	return s.err
+}
+
+// Load returns all stored values.
+func (s *SnapshotStore) Load() (map[string][]byte, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s.mem.Load()
+}
+
+// Save stores the given values, replacing previous values of the same keys.
+func (s *SnapshotStore) Save(values map[string][]byte) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	for k, v := range values {
+		s.mem.values[k] = append([]byte(nil), v...)
+	}
+	b, err := json.MarshalIndent(s.mem.values, "", "\t")
+	if err != nil {
+		return err
+	}
+	return replace(s.name, b)
+}
+
+// replace atomically replaces the content of the named file.
+func replace(name string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// ****************************************************************************
+
+// KVStore is a simple key-value store on disk.
+// Saved values are appended to a log file, each line holding a key and its hex encoded value.
+// The log is compacted whenever it is loaded, keeping only the latest value of each key.
+// This suits devices with frequently written values, as a save only writes the changed values.
+type KVStore struct {
+	name string
+	mu   sync.Mutex
+}
+
+var _ Store = (*KVStore)(nil)
+
+// NewKVStore returns a store persisting into the named log file.
+// A missing file is created on the first save.
+func NewKVStore(name string) *KVStore {
+	return &KVStore{name: name}
+}
+
+// Load returns all stored values and compacts the log file.
+func (s *KVStore) Load() (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make(map[string][]byte)
+	f, err := os.Open(s.name)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("sunspec: invalid entry in %v:%v", s.name, n)
+		}
+		v, err := hex.DecodeString(line[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("sunspec: invalid entry in %v:%v: %w", s.name, n, err)
+		}
+		values[line[:i]] = v
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := replace(s.name, encodeKV(values)); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Save appends the given values to the log file.
+func (s *KVStore) Save(values map[string][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(encodeKV(values)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// encodeKV formats the values as entries of the log file.
+func encodeKV(values map[string][]byte) []byte {
+	var buf bytes.Buffer
+	for k, v := range values {
+		buf.WriteString(k)
+		buf.WriteByte(' ')
+		buf.WriteString(hex.EncodeToString(v))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,150 @@
+package sunspec_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoAethereal/cancel"
+	"github.com/TRICERA-energy/sunspec"
+)
+
+func TestStores(t *testing.T) {
+	dir := t.TempDir()
+	mem := sunspec.NewMemoryStore()
+	testCases := []struct {
+		name string
+		open func() sunspec.Store
+	}{
+		{name: "memory", open: func() sunspec.Store { return mem }},
+		{name: "snapshot", open: func() sunspec.Store { return sunspec.NewSnapshotStore(filepath.Join(dir, "snapshot.json")) }},
+		{name: "kv", open: func() sunspec.Store { return sunspec.NewKVStore(filepath.Join(dir, "values.kv")) }},
+	}
+
+	for _, tc := range testCases {
+		s := tc.open()
+		if values, err := s.Load(); err != nil || len(values) != 0 {
+			t.Fatalf("%v: expected an empty store, got %v (%v)", tc.name, values, err)
+		}
+		for _, values := range []map[string][]byte{
+			{"1.A": {0, 1}, "1.B": {0, 2}},
+			{"1.A": {0, 3}},
+			{"1.A": {0, 4}, "2.C": {0, 5, 0, 6}},
+		} {
+			if err := s.Save(values); err != nil {
+				t.Fatalf("%v: %v", tc.name, err)
+			}
+		}
+		want := map[string][]byte{"1.A": {0, 4}, "1.B": {0, 2}, "2.C": {0, 5, 0, 6}}
+		values, err := s.Load()
+		if err != nil {
+			t.Fatalf("%v: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(values, want) {
+			t.Fatalf("%v: loaded %v; want %v", tc.name, values, want)
+		}
+		// the loaded values are not shared with the store
+		values["1.A"][1] = 0xFF
+		// a reopened store loads the same values
+		if values, err := tc.open().Load(); err != nil || !reflect.DeepEqual(values, want) {
+			t.Fatalf("%v: reopened store loaded %v (%v); want %v", tc.name, values, err, want)
+		}
+	}
+
+	// the snapshot is replaced atomically, leaving no temporary files behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"snapshot.json", "values.kv"}) {
+		t.Fatalf("expected only the store files, got %v", names)
+	}
+	// the log of the kv store is compacted on load, keeping a single entry per key
+	b, err := os.ReadFile(filepath.Join(dir, "values.kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(b, []byte("\n")); n != 3 || !strings.Contains(string(b), "1.A 0004\n") {
+		t.Fatalf("expected the compacted log of 3 entries, got %q", b)
+	}
+
+	// corrupted files are refused
+	for _, s := range []sunspec.Store{sunspec.NewSnapshotStore(filepath.Join(dir, "values.kv")), sunspec.NewKVStore(filepath.Join(dir, "snapshot.json"))} {
+		if _, err := s.Load(); err == nil {
+			t.Fatalf("expected an error loading a corrupted store %T", s)
+		}
+	}
+}
+
+func TestStoreRestore(t *testing.T) {
+	def := definition(t, pair)
+	dir := t.TempDir()
+	for _, st := range []sunspec.Store{
+		sunspec.NewMemoryStore(),
+		sunspec.NewSnapshotStore(filepath.Join(dir, "snapshot.json")),
+		sunspec.NewKVStore(filepath.Join(dir, "values.kv")),
+	} {
+		// values written by a client and updated by the server are persisted
+		ctx := cancel.New()
+		endpoint, s := serve(t, ctx, sunspec.Config{Store: st}, def)
+		c := sunspec.Config{Endpoint: endpoint}.Client()
+		if err := c.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Scan(ctx, def); err != nil {
+			t.Fatal(err)
+		}
+		set(c, 42)
+		if _, err := c.Write(ctx, c.Model(64001).Group("sync")); err != nil {
+			t.Fatal(err)
+		}
+		c.Disconnect()
+		if err := s.Update(func(d sunspec.Device) error {
+			return d.Model(64001).Group("sync").Point("B").(sunspec.Uint16).Set(43)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		ctx.Cancel()
+
+		// the restarted server serves the persisted values
+		ctx = cancel.New()
+		_, s = serve(t, ctx, sunspec.Config{Store: st}, def)
+		if err := s.View(func(d sunspec.Device) error {
+			g := d.Model(64001).Group("sync")
+			if a, b := g.Point("A").(sunspec.Uint16).Get(), g.Point("B").(sunspec.Uint16).Get(); a != 42 || b != 43 {
+				t.Errorf("%T: expected the restored values 42 and 43, got %v and %v", st, a, b)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		ctx.Cancel()
+	}
+}
+
+func TestStoreMismatch(t *testing.T) {
+	def := definition(t, pair)
+	st := sunspec.NewMemoryStore()
+	if err := st.Save(map[string][]byte{"64001.sync.A": {0, 1, 0, 2}, "64001.sync.B": {0, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := cancel.New()
+	defer ctx.Cancel()
+	_, s := serve(t, ctx, sunspec.Config{Store: st}, def)
+	if err := s.View(func(d sunspec.Device) error {
+		g := d.Model(64001).Group("sync")
+		if a, b := g.Point("A").(sunspec.Uint16).Get(), g.Point("B").(sunspec.Uint16).Get(); a != 0 || b != 3 {
+			t.Errorf("expected the mismatching value to be ignored, got %v and %v", a, b)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,202 @@
+package sunspec
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Event notifies about the change of a point´s value.
+type Event struct {
+	// Path references the changed point, e.g. "803.string[2].StrSoC".
+	Path string
+	// Point is the changed point.
+	Point Point
+	// Old is the previously read value, nil for the first event of a point.
+	Old interface{}
+	// New is the current value.
+	New interface{}
+	// Time is the moment the change was observed.
+	Time time.Time
+}
+
+// Edge specifies which changes of bitfield states trigger an event.
+type Edge int
+
+const (
+	// AnyEdge triggers on any activated or deactivated state.
+	AnyEdge Edge = iota
+	// RisingEdge only triggers on newly activated states.
+	RisingEdge
+	// FallingEdge only triggers on newly deactivated states.
+	FallingEdge
+)
+
+// Subscription configures the events delivered for the subscribed points.
+type Subscription struct {
+	// Deadband is the minimum absolute change of a numeric value triggering an event.
+	// The change is calculated relative to the last reported value.
+	Deadband float64
+	// Edge filters the events of bitfield points.
+	Edge Edge
+	// States restricts the edge filter to the given bitfield states.
+	// If omitted all states are considered.
+	States []string
+}
+
+// subscription is an active subscription of a client.
+type subscription struct {
+	Subscription
+	points map[Point]bool
+	// last is the last read value of every point, deciding the edges of bitfields.
+	last map[Point]interface{}
+	// reported is the last reported value of every point, deciding the deadband of numeric values.
+	reported map[Point]interface{}
+	callback func(e Event)
+}
+
+// subscriptions is the collection of all active subscriptions.
+type subscriptions struct {
+	mu   sync.Mutex
+	subs map[*subscription]bool
+}
+
+// Subscribe calls back fn whenever a point referenced by the path changes its value after being read,
+// for instance by Read or the client´s scheduler. The path may contain wildcards, e.g. "803.string[*].StrSoC".
+// Numeric values are reported as scaled float64, bitfields as their active states ([]string)
+// and all others as their string representation. Unimplemented values are reported as nil.
+// The first read of a point always triggers an event.
+// The returned function cancels the subscription.
+func (c *Client) Subscribe(path string, opt Subscription, fn func(e Event)) (cancel func(), err error) {
+	if c.Device == nil {
+		return nil, fmt.Errorf("sunspec: device must be scanned before subscribing to %q", path)
+	}
+	pts, err := c.Query(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &subscription{
+		Subscription: opt,
+		points:       make(map[Point]bool, len(pts)),
+		last:         make(map[Point]interface{}, len(pts)),
+		reported:     make(map[Point]interface{}, len(pts)),
+		callback:     fn,
+	}
+	for _, p := range pts {
+		s.points[p] = true
+	}
+	c.subscriptions.mu.Lock()
+	if c.subscriptions.subs == nil {
+		c.subscriptions.subs = make(map[*subscription]bool)
+	}
+	c.subscriptions.subs[s] = true
+	c.subscriptions.mu.Unlock()
+	return func() {
+		c.subscriptions.mu.Lock()
+		delete(c.subscriptions.subs, s)
+		c.subscriptions.mu.Unlock()
+	}, nil
+}
+
+// notify delivers the events for all changed points to the subscribers.
+// The callbacks are called after releasing all locks, so they may view the client´s values.
+func (c *Client) notify(pts Points) {
+	c.values.RLock()
+	events := c.subscriptions.changes(pts)
+	c.values.RUnlock()
+	for _, d := range events {
+		d.fn(d.e)
+	}
+}
+
+// delivery is an event pending for its subscriber.
+type delivery struct {
+	fn func(e Event)
+	e  Event
+}
+
+// changes determines the events of all changed points.
+func (subs *subscriptions) changes(pts Points) (events []delivery) {
+	now := time.Now()
+	subs.mu.Lock()
+	for s := range subs.subs {
+		for _, p := range pts {
+			if !s.points[p] {
+				continue
+			}
+			v := value(p)
+			old, seen := s.last[p]
+			s.last[p] = v
+			if seen && !s.changed(old, s.reported[p], v) {
+				continue
+			}
+			s.reported[p] = v
+			events = append(events, delivery{s.callback, Event{Path: Path(p), Point: p, Old: old, New: v, Time: now}})
+		}
+	}
+	subs.mu.Unlock()
+	return events
+}
+
+// changed determines whether the change from the previously read value old to v triggers an event.
+// Numeric values are compared to the previously reported value, so changes within the deadband can not creep.
+func (s *subscription) changed(old, reported, v interface{}) bool {
+	switch v := v.(type) {
+	case float64:
+		o, ok := reported.(float64)
+		return !ok || v != o && math.Abs(v-o) >= s.Deadband
+	case []string:
+		o, ok := old.([]string)
+		if !ok {
+			return true
+		}
+		rising, falling := diff(v, o, s.States), diff(o, v, s.States)
+		switch s.Edge {
+		case RisingEdge:
+			return rising
+		case FallingEdge:
+			return falling
+		}
+		return rising || falling
+	}
+	return old != v
+}
+
+// diff specifies whether a contains any state not contained by b.
+// If filter is given only those states are considered.
+func diff(a, b, filter []string) bool {
+	contains := func(s []string, x string) bool {
+		for _, v := range s {
+			if v == x {
+				return true
+			}
+		}
+		return false
+	}
+	for _, x := range a {
+		if (len(filter) == 0 || contains(filter, x)) && !contains(b, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the comparable representation of a point´s value.
+func value(p Point) interface{} {
+	if !p.Valid() {
+		return nil
+	}
+	switch p := p.(type) {
+	case Bitfield16:
+		return append([]string{}, p.States()...)
+	case Bitfield32:
+		return append([]string{}, p.States()...)
+	case Bitfield64:
+		return append([]string{}, p.States()...)
+	}
+	if v, err := Scaled(p); err == nil {
+		return v
+	}
+	return fmt.Sprint(p)
+}
@@ -0,0 +1,73 @@
+package sunspec
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// alarms is a model holding a bitfield of alarm states and a scaled measurement.
+const alarms = `{"id": 64030, "group": {"name": "alarms", "type": "group", "points": [
+	{"name": "ID", "type": "uint16", "size": 1, "value": 64030},
+	{"name": "L", "type": "uint16", "size": 1},
+	{"name": "Alm", "type": "bitfield16", "size": 1, "symbols": [
+		{"name": "A", "value": 0}, {"name": "B", "value": 1}, {"name": "C", "value": 2}]},
+	{"name": "W", "type": "int16", "size": 1, "sf": "W_SF"},
+	{"name": "W_SF", "type": "sunssf", "size": 1, "value": -1}]}}`
+
+func TestSubscriptionChanges(t *testing.T) {
+	const (
+		a = 1 << iota
+		b
+		c
+	)
+	testCases := []struct {
+		name  string
+		point string
+		opt   Subscription
+		steps []float64
+		want  []bool
+	}{
+		{name: "any edge", point: "Alm", opt: Subscription{},
+			steps: []float64{a, a, a | b, a, a}, want: []bool{true, false, true, true, false}},
+		{name: "repeated rising edge", point: "Alm", opt: Subscription{Edge: RisingEdge},
+			steps: []float64{a, a | b, a, a | b}, want: []bool{true, true, false, true}},
+		{name: "repeated falling edge", point: "Alm", opt: Subscription{Edge: FallingEdge},
+			steps: []float64{a | b, a, a | b, a}, want: []bool{true, true, false, true}},
+		{name: "filtered states", point: "Alm", opt: Subscription{Edge: RisingEdge, States: []string{"C"}},
+			steps: []float64{0, a, a | c, a, a | b | c}, want: []bool{true, false, true, false, true}},
+		{name: "deadband", point: "W", opt: Subscription{Deadband: 1},
+			steps: []float64{10, 10.5, 10, 10.9, 11, 11.5, 12.1}, want: []bool{true, false, false, false, true, false, true}},
+	}
+
+	for _, tc := range testCases {
+		var def ModelDef
+		if err := json.Unmarshal([]byte(alarms), &def); err != nil {
+			t.Fatal(err)
+		}
+		m, err := def.Instance(0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := m.Point(tc.point)
+		s := &subscription{
+			Subscription: tc.opt,
+			points:       map[Point]bool{p: true},
+			last:         make(map[Point]interface{}),
+			reported:     make(map[Point]interface{}),
+		}
+		subs := subscriptions{subs: map[*subscription]bool{s: true}}
+		for i, v := range tc.steps {
+			switch p := p.(type) {
+			case Bitfield16:
+				p.Set(uint16(v))
+			case Int16:
+				if err := p.SetValue(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := len(subs.changes(Points{p})) == 1; got != tc.want[i] {
+				t.Fatalf("%v: step %v to %v triggered %v; want %v", tc.name, i, v, got, tc.want[i])
+			}
+		}
+	}
+}
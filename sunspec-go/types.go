@@ -47,6 +47,76 @@ func (s *scale) factor(p Point) int16 {
 	return 0
 }
 
+// unscale converts the scaled value v into the raw value of the point p by applying the inverse
+// of its scale factor. The result is rounded to the nearest integer and must be within [min, max],
+// while not colliding with the value nan, which signals an unimplemented point.
+func unscale(p interface {
+	Point
+	Scalable
+}, v float64, min, max, nan float64) (float64, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("sunspec: value %v for point %q is not a finite number", v, p.Name())
+	}
+	r := math.Round(v * math.Pow10(-int(p.Factor())))
+	switch {
+	case r == nan:
+		return 0, fmt.Errorf("sunspec: value %v for point %q collides with the not implemented value", v, p.Name())
+	case r < min || r > max:
+		return 0, fmt.Errorf("sunspec: value %v for point %q is out of boundary", v, p.Name())
+	}
+	return r, nil
+}
+
+// Scaled returns the numeric value of the point with its scale factor applied, if any.
+// An error is returned for non numeric points or if the value is not implemented by the device.
+func Scaled(p Point) (float64, error) {
+	if !p.Valid() {
+		return 0, fmt.Errorf("sunspec: point %q is not implemented", p.Name())
+	}
+	var v float64
+	switch p := p.(type) {
+	case Int16:
+		return p.Value(), nil
+	case Int32:
+		return p.Value(), nil
+	case Int64:
+		return p.Value(), nil
+	case Uint16:
+		return p.Value(), nil
+	case Uint32:
+		return p.Value(), nil
+	case Uint64:
+		return p.Value(), nil
+	case Acc16:
+		return float64(p.Get()) * math.Pow10(int(p.Factor())), nil
+	case Acc32:
+		return float64(p.Get()) * math.Pow10(int(p.Factor())), nil
+	case Acc64:
+		return float64(p.Get()) * math.Pow10(int(p.Factor())), nil
+	case Sunssf:
+		v = float64(p.Get())
+	case Count:
+		v = float64(p.Get())
+	case Enum16:
+		v = float64(p.Get())
+	case Enum32:
+		v = float64(p.Get())
+	case Bitfield16:
+		v = float64(p.Get())
+	case Bitfield32:
+		v = float64(p.Get())
+	case Bitfield64:
+		v = float64(p.Get())
+	case Float32:
+		v = float64(p.Get())
+	case Float64:
+		v = p.Get()
+	default:
+		return 0, fmt.Errorf("sunspec: point %q is not numeric", p.Name())
+	}
+	return v, nil
+}
+
 // ****************************************************************************
 
 // Int16 represents the sunspec type int16.
@@ -61,6 +131,8 @@ type Int16 interface {
 	Get() int16
 	// Value returns the scaled value as defined by the specification.
 	Value() float64
+	// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+	SetValue(v float64) error
 }
 
 type tInt16 struct {
@@ -106,6 +178,15 @@ func (t *tInt16) Factor() int16 { return t.factor(t) }
 // Value returns the scaled value as defined by the specification.
 func (t *tInt16) Value() float64 { return float64(t.Get()) * math.Pow10(int(t.Factor())) }
 
+// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+func (t *tInt16) SetValue(v float64) error {
+	r, err := unscale(t, v, math.MinInt16, math.MaxInt16, -0x8000)
+	if err != nil {
+		return err
+	}
+	return t.Set(int16(r))
+}
+
 // ****************************************************************************
 
 // Int32 represents the sunspec type int32.
@@ -120,6 +201,8 @@ type Int32 interface {
 	Get() int32
 	// Value returns the scaled value as defined by the specification.
 	Value() float64
+	// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+	SetValue(v float64) error
 }
 
 type tInt32 struct {
@@ -165,6 +248,15 @@ func (t *tInt32) Factor() int16 { return t.factor(t) }
 // Value returns the scaled value as defined by the specification.
 func (t *tInt32) Value() float64 { return float64(t.Get()) * math.Pow10(int(t.Factor())) }
 
+// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+func (t *tInt32) SetValue(v float64) error {
+	r, err := unscale(t, v, math.MinInt32, math.MaxInt32, -0x80000000)
+	if err != nil {
+		return err
+	}
+	return t.Set(int32(r))
+}
+
 // ****************************************************************************
 
 // Int64 represents the sunspec type int64.
@@ -179,6 +271,8 @@ type Int64 interface {
 	Get() int64
 	// Value returns the scaled value as defined by the specification.
 	Value() float64
+	// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+	SetValue(v float64) error
 }
 
 type tInt64 struct {
@@ -224,6 +318,15 @@ func (t *tInt64) Factor() int16 { return t.factor(t) }
 // Value returns the scaled value as defined by the specification.
 func (t *tInt64) Value() float64 { return float64(t.Get()) * math.Pow10(int(t.Factor())) }
 
+// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+func (t *tInt64) SetValue(v float64) error {
+	r, err := unscale(t, v, math.MinInt64, math.Nextafter(math.MaxInt64, 0), math.MinInt64)
+	if err != nil {
+		return err
+	}
+	return t.Set(int64(r))
+}
+
 // ****************************************************************************
 
 // Pad represents the sunspec type pad.
@@ -319,6 +422,8 @@ type Uint16 interface {
 	Get() uint16
 	// Value returns the scaled value as defined by the specification.
 	Value() float64
+	// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+	SetValue(v float64) error
 }
 
 type tUint16 struct {
@@ -364,6 +469,15 @@ func (t *tUint16) Factor() int16 { return t.factor(t) }
 // Value returns the scaled value as defined by the specification.
 func (t *tUint16) Value() float64 { return float64(t.Get()) * math.Pow10(int(t.Factor())) }
 
+// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+func (t *tUint16) SetValue(v float64) error {
+	r, err := unscale(t, v, 0, math.MaxUint16, math.MaxUint16)
+	if err != nil {
+		return err
+	}
+	return t.Set(uint16(r))
+}
+
 // ****************************************************************************
 
 // Uint32 represents the sunspec type uint32.
@@ -378,6 +492,8 @@ type Uint32 interface {
 	Get() uint32
 	// Value returns the scaled value as defined by the specification.
 	Value() float64
+	// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+	SetValue(v float64) error
 }
 
 type tUint32 struct {
@@ -423,6 +539,15 @@ func (t *tUint32) Factor() int16 { return t.factor(t) }
 // Value returns the scaled value as defined by the specification.
 func (t *tUint32) Value() float64 { return float64(t.Get()) * math.Pow10(int(t.Factor())) }
 
+// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+func (t *tUint32) SetValue(v float64) error {
+	r, err := unscale(t, v, 0, math.MaxUint32, math.MaxUint32)
+	if err != nil {
+		return err
+	}
+	return t.Set(uint32(r))
+}
+
 // ****************************************************************************
 
 // Uint64 represents the sunspec type uint64.
@@ -437,6 +562,8 @@ type Uint64 interface {
 	Get() uint64
 	// Value returns the scaled value as defined by the specification.
 	Value() float64
+	// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+	SetValue(v float64) error
 }
 
 type tUint64 struct {
@@ -482,6 +609,15 @@ func (t *tUint64) Factor() int16 { return t.factor(t) }
 // Value returns the scaled value as defined by the specification.
 func (t *tUint64) Value() float64 { return float64(t.Get()) * math.Pow10(int(t.Factor())) }
 
+// SetValue sets the point´s underlying value from a scaled value as defined by the specification.
+func (t *tUint64) SetValue(v float64) error {
+	r, err := unscale(t, v, 0, math.Nextafter(math.MaxUint64, 0), math.MaxUint64)
+	if err != nil {
+		return err
+	}
+	return t.Set(uint64(r))
+}
+
 // ****************************************************************************
 
 // Acc16 represents the sunspec type acc16.
@@ -770,8 +906,8 @@ func (t *tBitfield16) States() (s []string) {
 		return nil
 	}
 	for i, v := range t.Field() {
-		if v {
-			s = append(s, t.symbols[uint32(i)].Name())
+		if sym, ok := t.symbols[uint32(i)]; v && ok {
+			s = append(s, sym.Name())
 		}
 	}
 	return s
@@ -857,8 +993,8 @@ func (t *tBitfield32) States() (s []string) {
 		return nil
 	}
 	for i, v := range t.Field() {
-		if v {
-			s = append(s, t.symbols[uint32(i)].Name())
+		if sym, ok := t.symbols[uint32(i)]; v && ok {
+			s = append(s, sym.Name())
 		}
 	}
 	return s
@@ -944,8 +1080,8 @@ func (t *tBitfield64) States() (s []string) {
 		return nil
 	}
 	for i, v := range t.Field() {
-		if v {
-			s = append(s, t.symbols[uint32(i)].Name())
+		if sym, ok := t.symbols[uint32(i)]; v && ok {
+			s = append(s, sym.Name())
 		}
 	}
 	return s
@@ -1003,7 +1139,13 @@ func (t *tEnum16) Set(v uint16) error {
 func (t *tEnum16) Get() uint16 { return t.data }
 
 // State returns the currently active enumerated state.
-func (t *tEnum16) State() string { return t.symbols[uint32(t.Get())].Name() }
+// If the value has no associated symbol an empty string is returned.
+func (t *tEnum16) State() string {
+	if sym, ok := t.symbols[uint32(t.Get())]; ok {
+		return sym.Name()
+	}
+	return ""
+}
 
 // ****************************************************************************
 
@@ -1057,7 +1199,13 @@ func (t *tEnum32) Set(v uint32) error {
 func (t *tEnum32) Get() uint32 { return t.data }
 
 // State returns the currently active enumerated state.
-func (t *tEnum32) State() string { return t.symbols[t.Get()].Name() }
+// If the value has no associated symbol an empty string is returned.
+func (t *tEnum32) State() string {
+	if sym, ok := t.symbols[t.Get()]; ok {
+		return sym.Name()
+	}
+	return ""
+}
 
 // ****************************************************************************
 
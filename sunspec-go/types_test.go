@@ -0,0 +1,51 @@
+package sunspec_test
+
+import (
+	"testing"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+func TestSetValue(t *testing.T) {
+	testCases := []struct {
+		typ   string
+		sf    int16
+		value float64
+		want  float64
+		fail  bool
+	}{
+		{typ: "uint16", sf: -1, value: 48.5, want: 48.5},
+		{typ: "uint16", sf: -2, value: 48.5, want: 48.5},
+		{typ: "uint16", sf: 1, value: 48.5, want: 50},
+		{typ: "uint16", sf: -3, value: 0.0014, want: 0.001},
+		{typ: "uint16", sf: -1, value: 6553.5, fail: true},
+		{typ: "uint16", sf: -1, value: 7000, fail: true},
+		{typ: "uint16", sf: 0, value: -1, fail: true},
+		{typ: "int16", sf: -1, value: -48.5, want: -48.5},
+		{typ: "int16", sf: -1, value: -3276.8, fail: true},
+		{typ: "int16", sf: 0, value: 32768, fail: true},
+		{typ: "int32", sf: -2, value: -123456.78, want: -123456.78},
+		{typ: "uint32", sf: 0, value: 4294967295, fail: true},
+		{typ: "int64", sf: 0, value: -9223372036854775808, fail: true},
+		{typ: "uint64", sf: 3, value: 12000, want: 12000},
+		{typ: "uint64", sf: 0, value: 1e20, fail: true},
+	}
+
+	for _, tc := range testCases {
+		def := sunspec.PointDef{Name: "P", Type: tc.typ, ScaleFactor: tc.sf}
+		p := def.Instance(0, nil).(interface {
+			sunspec.Point
+			SetValue(v float64) error
+			Value() float64
+		})
+		err := p.SetValue(tc.value)
+		switch {
+		case tc.fail && err == nil:
+			t.Fatalf("%v with sf %v accepted value %v; want error", tc.typ, tc.sf, tc.value)
+		case !tc.fail && err != nil:
+			t.Fatalf("%v with sf %v refused value %v: %v", tc.typ, tc.sf, tc.value, err)
+		case !tc.fail && p.Value() != tc.want:
+			t.Fatalf("%v with sf %v encoded value %v; want %v; got %v", tc.typ, tc.sf, tc.value, tc.want, p.Value())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package sunspec
+
+import "fmt"
+
+// unit describes an engineering unit in relation to the base unit of its quantity:
+//
+//	BaseValue = Value * factor + offset
+type unit struct {
+	quantity string
+	factor   float64
+	offset   float64
+}
+
+// engineering contains all known engineering units.
+// Keys are case sensitive, as the prefix m (milli) and M (mega) are distinct.
+var engineering = map[string]unit{
+	"W":     {"power", 1, 0},
+	"kW":    {"power", 1e3, 0},
+	"MW":    {"power", 1e6, 0},
+	"VA":    {"apparent power", 1, 0},
+	"kVA":   {"apparent power", 1e3, 0},
+	"MVA":   {"apparent power", 1e6, 0},
+	"var":   {"reactive power", 1, 0},
+	"Var":   {"reactive power", 1, 0},
+	"kvar":  {"reactive power", 1e3, 0},
+	"Mvar":  {"reactive power", 1e6, 0},
+	"Wh":    {"energy", 1, 0},
+	"kWh":   {"energy", 1e3, 0},
+	"MWh":   {"energy", 1e6, 0},
+	"J":     {"energy", 1.0 / 3600, 0},
+	"kJ":    {"energy", 1e3 / 3600, 0},
+	"VAh":   {"apparent energy", 1, 0},
+	"kVAh":  {"apparent energy", 1e3, 0},
+	"varh":  {"reactive energy", 1, 0},
+	"kvarh": {"reactive energy", 1e3, 0},
+	"A":     {"current", 1, 0},
+	"mA":    {"current", 1e-3, 0},
+	"kA":    {"current", 1e3, 0},
+	"Ah":    {"charge", 1, 0},
+	"mAh":   {"charge", 1e-3, 0},
+	"V":     {"voltage", 1, 0},
+	"mV":    {"voltage", 1e-3, 0},
+	"kV":    {"voltage", 1e3, 0},
+	"K":     {"temperature", 1, 0},
+	"C":     {"temperature", 1, 273.15},
+	"F":     {"temperature", 5.0 / 9, 273.15 - 32*5.0/9},
+	"Hz":    {"frequency", 1, 0},
+	"kHz":   {"frequency", 1e3, 0},
+	"%":     {"ratio", 1e-2, 0},
+	"Pct":   {"ratio", 1e-2, 0},
+	"S":     {"conductance", 1, 0},
+	"ms":    {"time", 1e-3, 0},
+	"s":     {"time", 1, 0},
+	"Secs":  {"time", 1, 0},
+	"min":   {"time", 60, 0},
+	"h":     {"time", 3600, 0},
+}
+
+// Convert converts the value v given in the engineering unit from into the unit to.
+// For example Convert(1500, "W", "kW") returns 1.5.
+// An error is returned if either unit is unknown or both are measuring different quantities.
+func Convert(v float64, from, to string) (float64, error) {
+	f, ok := engineering[from]
+	if !ok {
+		return 0, fmt.Errorf("sunspec: unknown unit %q", from)
+	}
+	t, ok := engineering[to]
+	if !ok {
+		return 0, fmt.Errorf("sunspec: unknown unit %q", to)
+	}
+	if f.quantity != t.quantity {
+		return 0, fmt.Errorf("sunspec: can not convert %v (%v) into %v (%v)", from, f.quantity, to, t.quantity)
+	}
+	if from == to {
+		return v, nil
+	}
+	return (v*f.factor + f.offset - t.offset) / t.factor, nil
+}
+
+// ValueIn returns the scaled value of the point converted into the given engineering unit.
+// This allows presenting values of different devices consistently, regardless of their scale factors and units.
+func ValueIn(p Point, unit string) (float64, error) {
+	v, err := Scaled(p)
+	if err != nil {
+		return 0, err
+	}
+	if p.Unit() == "" {
+		return 0, fmt.Errorf("sunspec: point %q has no unit", p.Name())
+	}
+	return Convert(v, p.Unit(), unit)
+}
@@ -0,0 +1,85 @@
+package sunspec_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/TRICERA-energy/sunspec"
+)
+
+func TestConvert(t *testing.T) {
+	testCases := []struct {
+		value float64
+		from  string
+		to    string
+		want  float64
+		fail  bool
+	}{
+		{value: 1500, from: "W", to: "kW", want: 1.5},
+		{value: 1.5, from: "MW", to: "kW", want: 1500},
+		{value: 2, from: "kvar", to: "Var", want: 2000},
+		{value: 3600, from: "J", to: "Wh", want: 1},
+		{value: 250, from: "mA", to: "A", want: 0.25},
+		{value: 25, from: "C", to: "K", want: 298.15},
+		{value: 212, from: "F", to: "C", want: 100},
+		{value: -40, from: "C", to: "F", want: -40},
+		{value: 50, from: "%", to: "Pct", want: 50},
+		{value: 1.5, from: "h", to: "min", want: 90},
+		{value: 7, from: "F", to: "F", want: 7},
+		{value: 7, from: "Furlong", to: "Furlong", fail: true},
+		{value: 1, from: "W", to: "Wh", fail: true},
+		{value: 1, from: "w", to: "W", fail: true},
+		{value: 1, from: "W", to: "kw", fail: true},
+		{value: 1, from: "mV", to: "mA", fail: true},
+	}
+
+	for _, tc := range testCases {
+		v, err := sunspec.Convert(tc.value, tc.from, tc.to)
+		switch {
+		case tc.fail && err == nil:
+			t.Fatalf("converted %v %v into %v %v; want error", tc.value, tc.from, v, tc.to)
+		case !tc.fail && err != nil:
+			t.Fatalf("refused to convert %v %v into %v: %v", tc.value, tc.from, tc.to, err)
+		case !tc.fail && math.Abs(v-tc.want) > 1e-9:
+			t.Fatalf("converted %v %v into %v %v; want %v", tc.value, tc.from, v, tc.to, tc.want)
+		}
+	}
+}
+
+func TestValueIn(t *testing.T) {
+	mls := instances(t)
+	m := mls[0]
+	if err := m.Point("W").(interface{ SetValue(float64) error }).SetValue(1234.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Point("Hz").(interface{ SetValue(float64) error }).SetValue(50); err != nil {
+		t.Fatal(err)
+	}
+	mls[1].Point("Hz").(sunspec.Uint16).Set(0xFFFF)
+
+	testCases := []struct {
+		point sunspec.Point
+		unit  string
+		want  float64
+		fail  bool
+	}{
+		{point: m.Point("W"), unit: "W", want: 1234.5},
+		{point: m.Point("W"), unit: "kW", want: 1.2345},
+		{point: m.Point("Hz"), unit: "kHz", want: 0.05},
+		{point: m.Point("W"), unit: "A", fail: true},
+		{point: m.Group("info").Point("Tag"), unit: "W", fail: true},
+		{point: mls[1].Point("Hz"), unit: "Hz", fail: true},
+	}
+
+	for _, tc := range testCases {
+		v, err := sunspec.ValueIn(tc.point, tc.unit)
+		switch {
+		case tc.fail && err == nil:
+			t.Fatalf("%v in %v returned %v; want error", sunspec.Path(tc.point), tc.unit, v)
+		case !tc.fail && err != nil:
+			t.Fatalf("%v in %v failed: %v", sunspec.Path(tc.point), tc.unit, err)
+		case !tc.fail && math.Abs(v-tc.want) > 1e-9:
+			t.Fatalf("%v in %v returned %v; want %v", sunspec.Path(tc.point), tc.unit, v, tc.want)
+		}
+	}
+}